@@ -0,0 +1,14 @@
+_vars: {
+	API_PORT:          =~"^[0-9]+$"
+	DATABASE_PASSWORD: string
+	DATABASE_USERNAME: string
+}
+
+api: {
+	address: "0.0.0.0:" + _vars.API_PORT
+}
+
+database: {
+	password: _vars.DATABASE_PASSWORD
+	username: _vars.DATABASE_USERNAME
+}
@@ -1,25 +1,53 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/fewlinesco/k8s-cfgenerator/cmd/cfgenerator/internal"
-	"github.com/fewlinesco/k8s-cfgenerator/cmd/cfgenerator/internal/file"
-	"github.com/fewlinesco/k8s-cfgenerator/cmd/cfgenerator/internal/interpreter"
+	"github.com/fewlinesco/k8s-cfgenerator/internal"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/diff"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/manifest"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/schema"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/vault"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/watch"
+	"github.com/fewlinesco/k8s-cfgenerator/pkg/cfgenerator"
+)
+
+// Set at build time with `-ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."`
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
 )
 
 const usageFmt = `Synopsis
 
-	%[1]s [-interpreter=plain|jsonnet] [volume-paths ...]
+	%[1]s [-interpreter=auto|plain|html|jsonnet|starlark|cue|envsubst|jsonc] [flags ...] [volume-paths|NAME=file-path ...]
 
 Description
 
-	Reads a content (plain text or JSONNET) template and output the result
-	to a file (as a JSON or plain text).
+	Reads a content (plain text, JSONNET, Starlark, CUE or shell-style
+	substitutions) template and output the result
+	to a file (as a JSON, YAML, TOML or plain text).
 
 	It reads all files present in 'the volume-paths' folders and for each of
 	these files, sets the file name as variable name and the content of the
@@ -27,30 +55,1034 @@ Description
 
 Flags
 
+	-allow-empty-glob
+	   Ignores the volume-paths patterns which don't match any path instead
+	   of failing.
+	   (Default: false)
+
+	-allow-remote
+	   Allows -in to be an 'http://' or 'https://' URL, downloaded before
+	   evaluating the template. A response whose status isn't a 2xx is an
+	   error. With auto, the interpreter is detected from the path of the URL,
+	   e.g. 'https://example.com/config.jsonnet?ref=main' uses jsonnet.
+	   Remote inputs can't be used with -watch. Without the flag, a URL is an
+	   error so no template is fetched from the network by mistake.
+	   (Default: false)
+
+	-binary=raw|base64
+	   A file is considered binary when its content isn't valid UTF-8 or
+	   contains a null byte.
+
+	   When raw, loads the content of binary files as is.
+
+	   When base64, loads the content of binary files encoded in standard
+	   base64, without trimming it. JSONNET templates can decode it back with
+	   std.native('base64Decode')(std.extVar('NAME')).
+	   (Default: raw)
+
+	-bundle-extvar=<name>
+	   When the interpreter is jsonnet, registers an additional extVar named
+	   after the flag value, holding an object mapping every variable name to
+	   its value, e.g. with '-bundle-extvar=_vars':
+	   'local v = std.extVar('_vars'); v.DATABASE_URL'. The code variables
+	   are embedded as code. A variable with the same name is an error.
+
+	   The individual extVars are still set, unless -bundle-only is set.
+	   (Default: disabled)
+
+	-bundle-only
+	   When -bundle-extvar is set, only registers the bundle extVar and not
+	   one extVar per variable.
+	   (Default: false)
+
+	-check-syntax
+	   Parses the templates with the interpreter and exits, without loading
+	   any variable, evaluating the templates nor writing any output, e.g. for
+	   a pre-commit hook. An invalid template is an error reporting the
+	   position of the invalid token: 'line:column' for jsonnet, starlark,
+	   cue and jsonc, the line for plain and html whose included templates
+	   are parsed as well. The envsubst templates are always valid. Several
+	   -in are parsed concatenated with the plain, html and envsubst
+	   interpreters, one by one with -merge.
+	   (Default: false)
+
+	-checksum-out=<file>|-
+	   Writes the lowercase hexadecimal SHA256 of the generated content,
+	   followed by a newline, to the file or to STDOUT when using "-", e.g. to
+	   stamp it as an annotation of a Deployment so its pods restart when the
+	   configuration changes. The hash is computed over the exact bytes of
+	   the outputs, once formatted and compressed, so it matches the one
+	   given by 'sha256sum <output>'. When the outputs have different
+	   contents (-multi, -split-dir, -out formats...), it's the SHA256 of
+	   their 'sha256sum' listing: one '<SHA256>  <path>' line per output,
+	   sorted by path. With -if-changed, it's only written when it changes
+	   and it's left out of the exit code 4, which only reports the outputs.
+
+	-code-volume=<volume-path>
+	   When the interpreter is jsonnet, loads the files of the volume path
+	   like the volume-paths arguments but as JSONNET code instead of strings
+	   (e.g. a file containing '["a", "b"]' is an array returned by
+	   std.extVar). A file which isn't valid JSONNET is an error naming it.
+	   Other interpreters don't support it.
+
+	   Note that you can pass the flag several times.
+
+	-compact
+	   When the format is json, removes all the insignificant white spaces to
+	   output the JSON on a single line. Same as '-indent=0'.
+	   (Default: false)
+
+	-compress=none|gzip
+	   When gzip, compresses the generated content before writing it to each
+	   output, STDOUT included. The compressed content is written atomically
+	   like any content and -if-changed compares the compressed bytes, which
+	   are always the same for the same content.
+	   (Default: none)
+
+	-compress-level=<level>
+	   When the compression is gzip, sets the compression level from 0 (no
+	   compression) to 9 (best compression), or -1 for the default level.
+	   (Default: -1)
+
+	-default-interpreter=<name>
+	   When the interpreter is auto, the interpreter used when the template
+	   is read from STDIN or when its extension doesn't match any interpreter
+	   (e.g. '-default-interpreter=plain' for 'nginx.conf'). When empty, an
+	   unknown extension is an error. It has no effect on the other
+	   interpreter values.
+	   (Default: jsonnet)
+
+	-delim-left=<delimiter> -delim-right=<delimiter>
+	   When the interpreter is plain, replaces the '{{' and '}}' delimiters
+	   of the template actions, e.g. '-delim-left=[[ -delim-right=]]' to
+	   render '[[ .API_PORT ]]'. Both flags must be set together. They have no
+	   effect on the other interpreters.
+	   (Default: {{ and }})
+
+	-diff=<path>
+	   Generates the content and compares it to the file at the path instead
+	   of writing any output, e.g. to check in CI that a rendered file is up
+	   to date. When they differ, prints their unified diff on STDERR and
+	   exits with the code 5, silently with '-quiet'. The content is encoded
+	   like the one of the '-out' output, with its format and the format
+	   options. It can't be used with several -out, -out-if, -multi,
+	   -split-dir, -watch or -compress.
+	   (Default: disabled)
+
+	-downward=<path>
+	   Loads a Kubernetes Downward API labels or annotations file, where each
+	   line is 'key="value"', as one variable per label or annotation named
+	   after its key, e.g. 'app.kubernetes.io/name'. Values are unquoted
+	   following the escaping used by Kubernetes. The variables are handled
+	   like the files of the volume paths, read after them and after the
+	   -json-vars: defining the same variable twice is a conflict handled by
+	   '-on-conflict'.
+
+	   Note that you can pass the flag several times.
+
+	-dry-run
+	   Generates the content without writing any output file, exiting with
+	   an error when the generation fails. The outputs set to STDOUT ('-') are
+	   still written. For each output file, the number of bytes that would be
+	   written is reported to STDERR.
+	   (Default: false)
+
+	-dump-vars[=redacted]
+	   Loads the variables from all the sources, exactly like the generation
+	   would, and prints them on STDOUT as a JSON object mapping their names
+	   to their values instead of evaluating the template. The code variables
+	   are printed as their code. Nothing is written to the outputs.
+
+	   When redacted, every value which isn't empty is replaced by
+	   '<redacted>', to check which variables are set without leaking them.
+
+	   Note that the errors are always redacted: the values found in an error
+	   message, as is or quoted with Go or JSON escapes, are replaced by
+	   '<redacted>'. The values shorter than 4 characters aren't, they would
+	   hide unrelated parts of the message.
+	   (Default: disabled)
+
+	-encoding=utf-8|latin1|windows-1252
+	   The charset of the files of the volume paths (NAME=file-path
+	   included), code volumes, groups, layers and archives, decoded to UTF-8
+	   before they become variables, e.g. '-encoding=latin1' for legacy
+	   files with accented characters.
+
+	   When utf-8, loads the content of the files as is, without checking
+	   it's valid UTF-8.
+
+	   When latin1, decodes the content from ISO-8859-1.
+
+	   When windows-1252, decodes the content from Windows-1252, the superset
+	   of ISO-8859-1 with '€' as 0x80. A byte it doesn't define is an error
+	   naming the file.
+
+	   With '-binary=base64', the files containing a null byte are still
+	   loaded as binary files, without decoding. The -env-file and
+	   -json-vars files are always read as UTF-8.
+	   (Default: utf-8)
+
+	-env[=<prefix>]
+	   Loads the environment variables as variables as well. When a prefix is
+	   given, only the environment variables whose name starts with it are
+	   loaded (e.g. '-env=APP_' loads 'APP_PORT' as 'APP_PORT').
+
+	   The files of the volume paths and the env files take precedence: an
+	   environment variable with the same name as one of them is ignored.
+	   (Default: disabled)
+
+	-env-file=<path>
+	   Loads the 'KEY=VALUE' lines of a dotenv file as variables as well.
+	   Empty lines and lines starting with '#' are ignored and the 'export '
+	   prefix is allowed. Values can be double-quoted (with '\n', '\t', '\"'
+	   and '\\' escapes), single-quoted (kept as is) or unquoted (trimmed,
+	   ' #' starts a comment). A malformed line is an error giving its number.
+
+	   The files of the volume paths take precedence over the env files, and
+	   the env files over the environment variables ('-env'). When several
+	   env files define the same variable, the first one wins.
+
+	   Note that you can pass the flag several times.
+
+	-error-unused
+	   Fails when a loaded variable isn't referenced by the template, like
+	   -warn-unused, to catch stale secrets and misnamed files.
+	   (Default: false)
+
+	-exclude-file=<glob>
+	   Skips the files of the volume folders whose base name matches the
+	   glob, e.g. '-exclude-file=README*' ignores the documentation keys of
+	   a ConfigMap. It takes precedence over -include-file: a file matching
+	   both is skipped. See -include-file for the matching rules.
+
+	   Note that you can pass the flag several times.
+
+	-fail-on-empty[=blank]
+	   Fails with the exit code 3, before writing any output, when the
+	   generated content of an output is empty, e.g. a template evaluated
+	   to an empty string by mistake. The JSON or YAML content of the data
+	   interpreters (e.g. jsonnet) is empty as well when it's null or an
+	   empty string. With -multi or -split-dir, each file is checked.
+
+	   When blank, a content only made of white spaces is empty as well.
+	   (Default: disabled)
+
+	-format=json|yaml|toml|env|auto
+	   When json, outputs the content as produced by the interpreter.
+
+	   When yaml, parses the JSON produced by the interpreter and outputs it
+	   as YAML. Object keys are sorted so the output is stable.
+
+	   When toml, parses the JSON produced by the interpreter and outputs it
+	   as TOML: objects become tables and arrays of objects arrays of tables.
+	   The content must be an object and null values, which TOML can't
+	   represent, are an error giving their path.
+
+	   When env, parses the JSON produced by the interpreter and outputs one
+	   'KEY=VALUE' line per key, sorted, ready to be sourced by a shell or
+	   used as an env file. The content must be a flat object whose keys are
+	   valid variable names: strings are written as is, numbers and booleans
+	   as their JSON encoding, and nested objects, arrays and null values are
+	   an error naming their key. The values containing spaces or special
+	   characters are single-quoted.
+
+	   When auto, picks the format of each output from its extension, so the
+	   same content can be written as several formats: '.json' uses json,
+	   '.yaml' and '.yml' use yaml, '.toml' uses toml and '.env' uses env.
+	   STDOUT ('-') and the other extensions use json. With -multi, the
+	   format of each file is picked from its name. The content of the
+	   plain, html and envsubst interpreters is always written as is, and
+	   -parse-output can't be used.
+
+	   With the plain, html and envsubst interpreters, a rendered text which
+	   isn't JSON is parsed as the yaml or toml format and encoded again, so
+	   it's formatted canonically whatever the white spaces of the template.
+	   A text which can't be parsed is an error. A JSON text is converted
+	   like the content of the other interpreters, and -indent and -compact
+	   re-indent it with json.
+	   (Default: json)
+
+	-group=<name>=<volume-path>
+	   Loads the files of the volume path as a single variable holding an
+	   object whose keys are the variable names of the files, instead of one
+	   variable per file (e.g. '-group=database=/etc/db' gives access to
+	   std.extVar('database').host). The jsonnet interpreter gets the object
+	   as code, the other interpreters as a JSON encoded string.
+
+	   It follows the same rules as the volume-paths: the sub-folders are
+	   only loaded with -recursive, their files being flattened using the
+	   separator, and a group with the same name as another variable is a
+	   conflict handled by -on-conflict.
+
+	   Note that you can pass the flag several times.
+
+	-header[=<text>]
+	   Adds a header to the generated content, so it's obvious the file is
+	   generated and mustn't be edited by hand. The text defaults to
+	   'Generated by cfgenerator, do not edit' and each of its lines is
+	   written as a comment following '-header-style'. The header is added
+	   after the content is formatted and wrapped, before the compression.
+	   (Default: disabled)
+
+	-header-style=auto|hash|slash|key|none
+	   When auto, picks the comment syntax of the format of each output:
+	   hash for yaml, toml, env and the '-wrap' manifests. JSON has no
+	   comments and a text content (e.g. a plain template producing
+	   something else than JSON) has no known syntax, so no header is
+	   added to them: use another style explicitly. With -multi and
+	   -split-dir, the format of each file comes from its extension.
+
+	   When hash, writes each line of the text as a '# ' comment.
+
+	   When slash, writes each line of the text as a '// ' comment, e.g. for
+	   JSON with comments.
+
+	   When key, adds a '"_generated": true' key at the top of the JSON
+	   object instead of the text, following its indentation. A content
+	   which isn't a JSON object, or which already has the key, is an error.
+
+	   When none, doesn't add any header.
+	   (Default: auto)
+
+	-hidden
+	   Loads the files (and folders when recursive) starting with a '.' as
+	   well. Entries starting with '..' are always skipped as Kubernetes uses
+	   them to store the actual content of the ConfigMap and Secret volumes
+	   behind the '..data' symbolic link.
+	   (Default: false)
+
+	-if-changed
+	   Compares the content of each existing output file with the generated
+	   content and doesn't write it when they match, keeping its modification
+	   time. For each output file, a line saying whether it was "updated" or
+	   "unchanged" is written to STDERR.
+	   (Default: false)
+
 	-in=<template-path>|-
 	   A path to the template to use as input. When using "-" input is STDIN.
 	   (Default: -)
 
-	-interpreter=plain|jsonnet
+	   Note that you can pass the flag several times with the plain, html and
+	   envsubst interpreters: the templates are concatenated in the order
+	   they are given, e.g. '-in=prelude.tpl -in=service.tpl'. STDIN can be
+	   one of them. With auto, the interpreter is detected from the first
+	   template. Other interpreters, like jsonnet, only accept one template
+	   which can import the others, unless -merge is used.
+
+	-in-archive=<archive-path>|-
+	   Reads the template and the variables from a tar archive, compressed
+	   with gzip or not, instead of -in. When using "-" the archive is read
+	   from STDIN, so nothing needs to be written on disk.
+
+	   The regular file named 'template' at the root of the archive, with any
+	   extension (e.g. 'template.jsonnet', used to detect the interpreter
+	   with auto), is the template. Every other regular file defines a
+	   variable named after its base name, whatever its folder, like the
+	   files of the volume-paths (e.g. 'secrets/DATABASE_PASSWORD' defines
+	   'DATABASE_PASSWORD'). Hidden files are skipped unless -hidden is set.
+	   Two entries with the same base name are an error.
+	   (Default: disabled)
+
+	-include=<glob>
+	   When the interpreter is plain, parses the files matching the glob as
+	   additional templates available by name in the template, e.g.
+	   '{{ template "header" . }}' for '-include=partials/header.tpl'. The
+	   name of an included template is its file name without extension and
+	   two included files can't have the same name.
+
+	   Note that you can pass the flag several times.
+
+	-include-file=<glob>
+	   Only loads the files of the volume folders whose base name matches
+	   the glob, e.g. '-include-file=*.conf'. The glob is matched against
+	   the file name only, never its folder, following the Go path.Match
+	   syntax: '*' matches any sequence of characters, '?' a single one and
+	   '[a-z]' a character class. The sub-folders of a recursive volume are
+	   always walked, their files being filtered the same way. It applies to
+	   the folders of the volume paths, code volumes, layers and groups, a
+	   volume path given as a file being always loaded. When the flag isn't
+	   set, all the files are loaded.
+
+	   Note that you can pass the flag several times, a file matching any
+	   of the globs being loaded.
+
+	-indent=<spaces>|\t
+	   When the format is json, re-indents the JSON with the given number of
+	   spaces, or with tabs when '\t'. '0' outputs the JSON on a single line.
+	   It has no effect on plain text or YAML outputs.
+	   (Default: the indentation of the interpreter)
+
+	-inject-metadata
+	   Defines two additional variables describing the generation:
+	   '_generated_at', the current time formatted as RFC3339 in UTC (e.g.
+	   '2021-06-01T10:00:00Z'), and '_hostname', the hostname of the machine
+	   (e.g. the name of the pod), so std.extVar('_hostname') works like any
+	   other variable. Another source defining one of them is an error,
+	   whatever -on-conflict.
+
+	   Note that the timestamp changes at each run, so the content isn't
+	   reproducible anymore (e.g. -if-changed always writes the outputs).
+	   For deterministic builds, set the SOURCE_DATE_EPOCH environment
+	   variable to a number of seconds since the Unix epoch (e.g.
+	   'SOURCE_DATE_EPOCH=$(git log -1 --format=%%ct)'), used instead of the
+	   current time. Without the flag, the generation is hermetic.
+	   (Default: false)
+
+	-interpolate-vars
+	   Replaces the '${NAME}' references in the values of the loaded
+	   variables by the value of the NAME variable before evaluating the
+	   template, e.g. a DATABASE_URL file containing
+	   'postgres://${DATABASE_USERNAME}@db/app'. The referenced values are
+	   interpolated as well, up to 10 nested references, and variables
+	   referencing each other are an error naming them. References to
+	   undefined variables are kept as is and the -code-volume
+	   variables are left untouched.
+	   (Default: false)
+
+	-interpreter=auto|plain|html|jsonnet|starlark|cue|envsubst|jsonc
+	   When auto, detects the interpreter from the extension of the template
+	   path: '.jsonnet' and '.libsonnet' use jsonnet, '.tmpl', '.tpl' and
+	   '.txt' use plain, '.html' and '.gohtml' use html, '.star' uses
+	   starlark, '.cue' uses cue and '.jsonc' uses jsonc. Reading from STDIN
+	   or an unknown extension uses -default-interpreter.
+
 	   When plain, interprets the input as plain text and use gotpl as
 	   variable system.
+	   The variables are the fields of the dot, e.g. '{{ .API_PORT }}', which
+	   only works for the names made of letters, digits and '_'. Any name,
+	   like 'log-level' or 'app.kubernetes.io/name', can be read with the
+	   'var' function, e.g. '{{ var "log-level" }}', or from the map returned
+	   by the 'vars' function, e.g. '{{ index vars "log-level" }}' or
+	   '{{ range $name, $value := vars }}'.
+	   The 'var' function returns an empty string when the variable is
+	   missing, or fails with '-strict', so '{{ var "X" | default "y" }}'
+	   falls back to 'y'. Note that 'default' treats an empty value like a
+	   missing variable. They are available even with '-sprig=none'.
+
+	   When html, same as plain but uses html/template, which escapes each
+	   value according to where it's written in the HTML document: HTML
+	   entities in text and attributes (e.g. '<' becomes '&lt;'), percent
+	   encoding in URLs, JavaScript strings or JSON values in '<script>'
+	   (e.g. '{ name: {{ .NAME }} }' writes a quoted string, and '</script>'
+	   can't close the element) and CSS values in '<style>'. It's meant for
+	   configurations embedded in a page, e.g. the runtime configuration of a
+	   frontend. It accepts the same flags as plain ('-sprig', '-strict',
+	   '-include', '-delim-left' and '-delim-right').
 
 	   When jsonnet, interprets the input as JSONNET and use extVar as
 	   variable system.
+	   The following native functions are available with std.native:
+	   - base64Decode(s): decodes the standard base64 string s
+	   - base64Encode(s): encodes the string s in standard base64
+	   - regexMatch(pattern, s): whether s contains a match of the RE2
+	     pattern
+	   - regexReplace(pattern, s, repl): replaces the matches of the RE2
+	     pattern in s by repl, which can reference submatches with '$1'
+	   - extVarDefault(name, default): the extVar when it's defined, even
+	     with an empty value, default when it's missing
+	   - readVolumeFile(name): the content of the file of a -lazy-volume
+	   e.g. std.native('base64Decode')(std.extVar('CERTIFICATE')).
+
+	   When starlark, interprets the input as Starlark with the variables
+	   available in the 'vars' dict, e.g. 'vars["API_PORT"]'. The value of
+	   the 'config' global is the generated content, encoded as JSON. The
+	   template can't access the filesystem nor the network: 'load'
+	   statements are rejected and only the 'json' module is available.
+
+	   When cue, interprets the input as CUE with the variables unified in
+	   the '_vars' hidden field, e.g. '_vars.API_PORT'. The template can
+	   declare it to constrain the variables (e.g. '_vars: [string]: string')
+	   but not a package. The result is encoded as JSON, keeping the order in
+	   which the fields are declared, and must be concrete and satisfy all
+	   the constraints, otherwise the error lists all the failing fields.
+
+	   When envsubst, replaces the '$NAME' and '${NAME}' references by the
+	   value of the variables and outputs the text as is. '$$' is a literal
+	   '$'. A reference to a missing variable is kept as is, unless '-strict'
+	   is set.
+
+	   When jsonc, reads the input as JSON with comments: the '//' and
+	   '/* */' comments and the trailing commas are removed, even where JSON
+	   forbids them, while the same characters in string literals are kept.
+	   The '${NAME}' references are replaced by the value of the variables:
+	   in a string literal the value is escaped and written in the string,
+	   e.g. '"0.0.0.0:${API_PORT}"', elsewhere it's written as a JSON string,
+	   or as is for the non-string values of '-json-vars' and '-set-code',
+	   e.g. '"replicas": ${REPLICAS}'. '$${' is a literal '${'. A reference
+	   to a missing variable is an error and the result must be valid JSON.
 
 	   By default it is set to jsonnet
 
-	-out=<file>|-
+	-jpath=<folder>, -J=<folder>
+	   When the interpreter is jsonnet, adds a library search folder used to
+	   resolve the imports (e.g. "import 'lib/util.libsonnet'"). Like the
+	   jsonnet command line, the right-most folder wins when several of them
+	   contain the imported file. When the template is a file, its folder is
+	   searched first.
+
+	   Note that you can pass the flag several times.
+
+	-json-vars=<path>
+	   Loads each top-level key of a JSON object file as a variable. String
+	   values are loaded as is. Other values (objects, arrays, numbers...) are
+	   loaded as JSONNET code with the jsonnet interpreter, so
+	   std.extVar('REPLICAS') returns an object, and as their JSON encoding in
+	   a string otherwise. A file which isn't a JSON object is an error.
+
+	   The keys are handled like the files of the volume paths, read after
+	   them: defining the same variable as a file is a conflict handled by
+	   '-on-conflict'.
+
+	   Note that you can pass the flag several times.
+
+	-layer=<volume-path>
+	   Loads the files of the folder like a volume path, as a layer merged
+	   with the other layers in the order of the flags: a layer replaces the
+	   variables of the previous ones without any conflict, e.g.
+	   '-layer=/data/base -layer=/data/prod' loads base then overrides it
+	   with prod.
+
+	   The merged layers come before the volume paths and the other sources,
+	   which see them as a single volume: a variable defined by both a layer
+	   and a volume path (or an archive, a -json-vars...) is a conflict
+	   handled by '-on-conflict'. The -env-file and -env variables have a
+	   lower precedence than the layers and the -set and -set-code values a
+	   higher one, whatever '-on-conflict'.
+
+	   Note that you can pass the flag several times.
+
+	-lazy-volume=<volume-path>
+	   When the interpreter is jsonnet, makes the files of the folder
+	   readable on demand by the template with
+	   std.native('readVolumeFile')(name) instead of loading them as
+	   variables, so a volume holding many large files only reads the ones
+	   the template uses. The name is the path of the file relative to the
+	   folder (e.g. 'certs/ca.pem') and the content is returned as is,
+	   without trimming. When several folders are given, the first one
+	   containing the file wins. A name outside of the folders, symbolic
+	   links included, or not found in any of them is an error. Other
+	   interpreters don't support it.
+
+	   Note that you can pass the flag several times.
+
+	-list-interpreters
+	   Prints the names of the available interpreters, one per line and
+	   sorted alphabetically, then exits without reading any input.
+
+	-list-outputs
+	   Prints the paths of the files the invocation would write, one per
+	   line, then exits without writing anything: the -out files ('-' for
+	   STDOUT), the ones whose -out-if variables are set, and the
+	   -checksum-out file. The -out paths are static, so the template isn't
+	   evaluated, only the variables being loaded when -out-if is used. The
+	   names of the -multi and -split-dir files are defined by the content,
+	   so the template is evaluated to find them, failing like a usual
+	   generation would. It can't be used with -dump-vars, -diff or -watch.
+	   (Default: false)
+
+	-lock
+	   Acquires an exclusive advisory lock (flock) on '<file>.lock' before
+	   writing each output file, waiting for the other processes holding it,
+	   and releases it once all the outputs are written or when the command
+	   fails, so concurrent runs writing the same file (e.g. two sidecars)
+	   write one after the other. The output itself isn't locked as it's
+	   replaced atomically, and the lock file is kept as another process may
+	   be waiting for it. Only the processes using -lock are serialized.
+	   STDOUT isn't locked. On the systems without flock (e.g. Windows) it's
+	   an error.
+	   (Default: false)
+
+	-log-format=text|json
+	   When text, writes the messages to STDERR as is: the -verbose logs,
+	   the notices (e.g. the -if-changed and -warn-unused ones) and the
+	   errors.
+
+	   When json, writes each message to STDERR as a JSON object on its own
+	   line with the 'time', the 'level' ('debug' for the -verbose logs,
+	   'info' for the notices, 'error' for the errors) and the 'msg' keys,
+	   e.g. '{"time":"...","level":"debug","msg":"wrote output '-'"}', for
+	   log aggregators. STDOUT only receives the generated content and
+	   variable values are never logged either way.
+	   (Default: text)
+
+	-max-file-size=<bytes>
+	   The maximum size of each file loaded from the volume paths. A larger
+	   file is an error naming it, detected without reading it entirely so a
+	   huge file can't exhaust the memory. '0' disables the limit.
+	   (Default: 10485760, 10MiB)
+
+	-merge
+	   Evaluates each -in template on its own, with the same variables, and
+	   deep merges the objects they produce instead of concatenating them.
+	   The templates are merged in the order they are given so the last one
+	   wins, e.g. '-merge -in=base.jsonnet -in=production.jsonnet': the keys
+	   of nested objects are merged recursively and any other value, arrays
+	   included, is replaced by the one of the later template. Every template
+	   must produce an object. It can't be used with the plain, html and
+	   envsubst interpreters, nor with -multi.
+	   (Default: false)
+
+	-mkdir
+	   Creates the missing parent folders of the output files.
+	   (Default: false)
+
+	-mode=<octal-permissions>
+	   The permissions of the output files, e.g. '0600' for files containing
+	   secrets. It applies to all the output files but STDOUT.
+	   (Default: the permissions of the replaced file, or 0644 for a new file)
+
+	-multi=<folder>
+	   Expects the template to produce an object mapping file names to their
+	   content, like the '-m' mode of the jsonnet command line, and writes
+	   each entry to '<folder>/<file name>' instead of the '-out' paths, which
+	   can't be used together with it. File names can contain sub folders
+	   (created with '-mkdir') but can't go outside of the folder.
+
+	   A string value is written as is. Any other value is written using the
+	   '-format' and its options, e.g. '-format=yaml' to write each
+	   Kubernetes resource as YAML. The other output flags ('-dry-run',
+	   '-if-changed', '-mode') apply to each file.
+
+	-name-transform=none|snake|env
+	   When none, the variable names are the file names (joined by the
+	   separator when recursive).
+
+	   When snake, lower cases the names and replaces the characters other
+	   than letters, digits and '_' by '_' (e.g. 'log-level' and 'api.port'
+	   become 'log_level' and 'api_port'), so they can be used as fields by
+	   the plain templates.
+
+	   When env, same as snake but upper cases the names (e.g. 'LOG_LEVEL').
+
+	   It applies to the files of the volume paths, code volumes, groups and
+	   archives, not to the NAME=file-path arguments. Two files of the same
+	   volume whose names are the same once transformed (e.g. 'a.b' and
+	   'a-b') are an error.
+	   (Default: none)
+
+	-on-conflict=error|last|first
+	   When error, fails when the same variable name is defined by several
+	   files (e.g. the same file name in two volume paths). The error names
+	   the variable and both files.
+
+	   When last, the value of the last file wins.
+
+	   When first, the value of the first file wins.
+
+	   Volume paths are read in the order they are given.
+	   (Default: error)
+
+	-opt=KEY=VALUE
+	   Sets an option of the interpreter, on top of the ones of the other
+	   flags (e.g. '-opt=sprig=hermetic'). Lists are comma separated and
+	   are added to the ones of the other flags. The recognized keys are:
+
+	   plain, html: delim-left, delim-right, include, sprig, strict
+	   envsubst: strict
+	   jsonnet: bundle-extvar, bundle-only, jpath, trace
+
+	   They behave like the flags of the same name. The other interpreters
+	   don't accept any option, and an unknown key is an error.
+
+	   Note that you can pass the flag several times.
+
+	-out=[<format>:]<file>|-
 	   A path to where to generate the file. When using "-" output is STDOUT.
 	   (Default: -)
 
+	   The path can be prefixed by a format (json, yaml, toml, env or auto)
+	   which overrides -format for this output only, e.g.
+	   '-out=json:config.json -out=yaml:config.yaml' writes the same content
+	   in both formats. The outputs without a prefix use -format. It can't
+	   be used with the plain, html and envsubst interpreters.
+
 	   Note that you can pass the flag several times if the goal is to write
 	   the configuration in several locations. It can be useful to add an
 	   additional '-out=-' for debugging purpose for example.
 
+	   Files are written atomically: the content is written to a temporary
+	   file in the same folder which replaces the file, keeping its
+	   permissions, once all the outputs are written successfully. The
+	   temporary files are all closed with their permissions set before the
+	   first one is moved in place, so an output failing leaves all the
+	   files untouched. Only moving a file in place failing, which is rare
+	   in the same folder, leaves the previous outputs replaced.
+
+	   When the path is an existing named pipe (FIFO) or Unix socket, the
+	   content is streamed to it directly instead: there's no temporary
+	   file, opening a named pipe waits for its reader, -mode doesn't apply
+	   and -if-changed always writes it. A reader going away before the
+	   content is written fails with a broken pipe error.
+
+	   Before evaluating the template, the command checks each output and
+	   -checksum-out can be written, creating and removing a temporary file
+	   in its folder (or in its closest existing parent with -mkdir), and
+	   fails naming the first one which can't, unless -dry-run is set.
+
+	-out-if=<name>=<file>
+	   Only writes the -out file when the variable is defined with a
+	   non-empty value, e.g. '-out-if=TLS_CERTIFICATE=tls.json' skips
+	   'tls.json' when no certificate is mounted instead of writing a half
+	   configured file. A missing variable and a variable whose value is
+	   empty (e.g. an empty file) are both unmet. The variables are the ones
+	   received by the interpreter, whatever their source. The other outputs
+	   are written as usual and the skipped ones are reported with -verbose.
+	   It can't be used with -multi, -split-dir or -diff.
+
+	   Note that you can pass the flag several times, the file being written
+	   only when all its variables are set.
+
+	-parse=none|yaml|json
+	   When yaml, parses the files whose extension is '.yaml' or '.yml' as
+	   YAML, so the template can read their fields: the jsonnet interpreter
+	   loads them as JSONNET code (e.g. std.extVar('database.yaml').host)
+	   the plain and html ones as nested data (e.g. '.database.host' with
+	   '-strip-ext'), the other ones as their JSON encoding in a string.
+
+	   When json, does the same with the files whose extension is '.json'.
+
+	   The extension is matched on the file name, before '-strip-ext' drops
+	   it from the variable name. A file which doesn't parse is an error
+	   naming it, the other files are loaded as strings.
+
+	   It applies to the files of the volume paths, code volumes, groups and
+	   archives. In a group, a parsed file is its JSON encoding in a string.
+	   (Default: none)
+
+	-parse-output
+	   When the interpreter is plain, html or envsubst, parses the rendered
+	   text as the -format (json or yaml) and encodes it again instead of
+	   writing it as is. A rendered text which isn't valid, like a
+	   substituted value breaking the YAML, is an error. With -yaml-stream,
+	   all the YAML documents are parsed. It can't be used with -multi.
+	   (Default: false)
+
+	-positional-in
+	   When neither -in nor -in-archive is set, reads the template from the
+	   first volume path argument when it's an existing regular file whose
+	   extension is associated to an interpreter (see -interpreter=auto,
+	   e.g. '.jsonnet' or '.tpl'), so '%[1]s -positional-in
+	   config.jsonnet /data/secrets' is '%[1]s -in=config.jsonnet
+	   /data/secrets'. The other arguments are volume paths. Otherwise, like
+	   a file without a known extension, a folder, a NAME=file-path or a
+	   pattern argument, all the arguments are volume paths and the template
+	   is read from STDIN. -volumes entries are never read as the template.
+	   (Default: false)
+
+	-prefix=<prefix>=<volume-path>
+	   Adds the prefix and a '_' separator to the names of the variables of
+	   the volume path or code volume (e.g. '-prefix=DB=/etc/db' loads the
+	   '/etc/db/DATABASE_URL' file as 'DB_DATABASE_URL'), so volumes with the
+	   same file names can be loaded together. The prefixed names are still
+	   checked for conflicts handled by '-on-conflict'. The path is matched
+	   against the volume paths once the patterns are expanded, and a path
+	   which isn't a volume path is an error.
+
+	   Note that you can pass the flag several times.
+
+	-properties=<path>
+	   Loads a Java properties file as one variable per key, e.g. 'db.url'.
+	   Lines are 'key=value', 'key:value' or 'key value', blank lines and
+	   lines starting with '#' or '!' are ignored. A line ending with '\'
+	   continues on the next one, a continuation on the last line of the
+	   file being an error. Keys and values support the '\t', '\n', '\f',
+	   '\r' and '\uXXXX' escapes, any other escaped character standing for
+	   itself. A malformed line is an error giving its number. When the file
+	   defines a key several times, the last line wins. The variables are
+	   handled like the files of the volume paths, read after them and after
+	   the -downward files: defining the same variable twice is a conflict
+	   handled by '-on-conflict'.
+
+	   Note that you can pass the flag several times.
+
+	-quiet, -q
+	   Doesn't report anything on STDERR but the errors: the '-if-changed'
+	   and '-dry-run' reports and the '-warn-unused' warnings are dropped, so
+	   the exit code is the only signal, e.g.
+	   'if %[1]s -quiet -if-changed ...; then reload; fi'. It can't be used
+	   with -verbose.
+	   (Default: false)
+
+	-recursive
+	   Loads the files present in the sub folders of the volume paths as well.
+	   The variable name of a nested file is its path relative to the volume
+	   path, e.g. 'db/host' for '/data/config/db/host'.
+	   (Default: false)
+
+	-remote-timeout=<duration>
+	   The maximum duration of the download of a remote -in, e.g. '10s'. '0'
+	   disables the limit. It bounds the -vault requests as well. See
+	   -allow-remote.
+	   (Default: 30s)
+
+	-require=<name>
+	   Fails before evaluating the template when no source (volume paths,
+	   env files, environment...) defines the variable. The error lists all
+	   the missing variables at once. A variable defined with an empty value
+	   is provided.
+
+	   Note that you can pass the flag several times.
+
+	-schema=<path>
+	   Validates the JSON produced by the interpreter against the JSON Schema
+	   stored in the file before writing any output. When it doesn't match,
+	   the error lists each violation with the JSON pointer of the invalid
+	   value (e.g. '/api/port') and nothing is written. It can only be used
+	   with '-format=json'.
+
+	-separator=<separator>
+	   When recursive, the string used in place of the path separator to
+	   flatten the variable name of a nested file. With '-separator=.' the
+	   file '/data/config/db/host' is loaded as 'db.host'.
+	   (Default: /)
+
+	-set=NAME=VALUE, -set-code=NAME=VALUE
+	   Defines the variable NAME with the value, as a string with '-set' or
+	   as code evaluated by the interpreter with '-set-code' (e.g.
+	   '-set-code=replicas=3'), like the files of -code volumes. These
+	   variables have the highest precedence: they replace the ones of any
+	   other source (volumes, archives, -json-vars, -env, -inject-metadata,
+	   ...) whatever '-on-conflict', and the last flag setting a variable
+	   wins. A variable can't be set by both flags.
+
+	   Note that you can pass the flags several times.
+
+	-split-dir=<folder>
+	   Expects the template to produce an object and writes each top-level
+	   value to '<folder>/<key>.<extension>' instead of the '-out' paths,
+	   using the '-format' and its options, e.g. '-format=yaml' writes the
+	   'database' key to '<folder>/database.yaml'. Unlike -multi, string
+	   values are encoded like any other value and the file names come from
+	   the keys: the characters other than letters, digits, '-', '_' and '.'
+	   as well as a leading '.' are replaced by '_', and two keys giving the
+	   same file name are an error. The other output flags ('-dry-run',
+	   '-if-changed', '-mode', '-mkdir') apply to each file.
+
+	-sprig=full|hermetic|none
+	   When the interpreter is plain, the Sprig functions available in the
+	   template (e.g. '{{ .PASSWORD | b64enc }}').
+
+	   When full, all the Sprig functions are available.
+
+	   When hermetic, the functions whose result isn't reproducible are
+	   omitted: time ('now', 'ago'), randomness ('uuidv4', 'randAlphaNum',
+	   'bcrypt', 'genCA'...), environment ('env', 'expandenv') and network
+	   ('getHostByName').
+
+	   When none, no Sprig function is available.
+	   (Default: full)
+
+	-stdin-vars
+	   Reads STDIN as a JSON object whose top-level keys are loaded as
+	   variables, like a -json-vars file read after them, e.g.
+	   'vault-export | cfgenerator -stdin-vars -in=config.jsonnet'. As STDIN
+	   isn't the template anymore, -in must be a path. Volume files come
+	   first: defining the same variable as a file or a -json-vars key is a
+	   conflict handled by '-on-conflict', while the -env-file and -env
+	   variables have a lower precedence.
+	   (Default: false)
+
+	-stream
+	   When the interpreter is plain or html, writes the content to the
+	   '-out' outputs as the template is rendered instead of holding it in
+	   memory, for very large contents. The files are still written
+	   atomically, but the part of the content written to STDOUT or to a
+	   stream before an error stays there.
+
+	   The content is held in memory as usual, without any warning, when it
+	   has to be post-processed: with another interpreter, a '-format'
+	   other than json or auto, '-indent', '-compact', '-schema', '-merge',
+	   '-timeout', '-parse-output', '-out' formats or guards, '-if-changed',
+	   '-dry-run', '-diff', '-header', '-fail-on-empty', '-checksum-out',
+	   '-compress', '-wrap', '-warn-unused' or '-error-unused'. '-verbose'
+	   reports it.
+	   (Default: false)
+
+	-strict
+	   When the interpreter is plain or html, fails when the template
+	   references a variable that isn't defined instead of rendering
+	   '<no value>', or when 'var' reads one instead of returning an empty
+	   string. The error names the missing variable.
+
+	   When the interpreter is envsubst, fails when the template references
+	   variables that aren't defined instead of keeping the references. The
+	   error names all the missing variables.
+	   (Default: false)
+
+	-strip-ext
+	   Removes the extension of the file names before deriving the variable
+	   names (e.g. 'database.yaml' defines 'database'), before
+	   '-name-transform'. A name made of an extension only (e.g. '.env') is
+	   kept. Two files of the same volume whose names are the same once
+	   stripped (e.g. 'a.yaml' and 'a.json') are an error.
+
+	   It applies to the files of the volume paths, code volumes, groups and
+	   archives, not to the NAME=file-path arguments.
+	   (Default: false)
+
+	-timeout=<duration>
+	   Fails when the evaluation of the template takes longer than the
+	   duration (e.g. '-timeout=10s'), like an accidental infinite recursion
+	   would, instead of hanging forever. There's no limit when 0.
+	   (Default: 0)
+
+	-tla-str=NAME=VALUE, -tla-code=NAME=VALUE
+	   When the interpreter is jsonnet, passes a top-level argument to the
+	   template, as a string with '-tla-str' or as JSONNET code with
+	   '-tla-code' (e.g. '-tla-code=replicas=3'). It only makes sense when the
+	   top-level expression of the template is a function, e.g.
+	   'function(port) { address: "0.0.0.0:" + port }': any other template is
+	   an error. Volume files are still loaded as extVars.
+
+	   Note that you can pass the flags several times.
+
+	-trace
+	   When the interpreter is jsonnet, reports every frame of the evaluation
+	   stack of a runtime error, innermost first, one 'file:line:column'
+	   location per line naming its file: the file raising the error, then
+	   the ones importing it, up to the template named '<template>'.
+	   Otherwise, the error lists the frames as reported by jsonnet, the
+	   locations of the template having no file name. It's the 'trace'
+	   option of '-opt'.
+	   (Default: false)
+
+	-trim=space|newline|none
+	   When space, removes all the leading and trailing white spaces of the
+	   content of each loaded file.
+
+	   When newline, removes only the trailing new lines ('\n' or '\r\n') of
+	   the content of each loaded file.
+
+	   When none, keeps the content of each loaded file as is.
+
+	   A file containing only white spaces is still loaded, as an empty value
+	   when trimmed.
+	   (Default: space)
+
+	-vault=path=<secret-path>
+	   Reads the key/value pairs of the Vault secret at the path, e.g.
+	   'path=secret/data/app', and loads them as variables after the
+	   volumes, with the same conflict detection. The server and the token
+	   come from the VAULT_ADDR and VAULT_TOKEN environment variables, both
+	   required, and VAULT_NAMESPACE when set. The KV version 1 and 2
+	   engines are supported: with the latter the path includes the 'data'
+	   segment. Non-string values are loaded like the ones of -json-vars.
+	   The token is never logged. The requests are bounded by
+	   -remote-timeout.
+
+	   Note that you can pass the flag several times.
+
+	-verbose, -v
+	   Logs to STDERR the interpreter used, the templates read, the sources
+	   of variables scanned, the name and source of each variable loaded and
+	   the outputs written. Variable values are never logged.
+	   (Default: false)
+
+	-version
+	   Prints the version, the git commit and the build date of the binary
+	   then exits without reading any input.
+
+	-volumes=<volume-paths>
+	   A list of volume paths separated by the OS path list separator (':'
+	   on Unix, ';' on Windows), e.g. '-volumes=/data/config:/data/secrets',
+	   for the tools building the command from a single value like an
+	   environment variable. Each entry is read like a volume-paths argument,
+	   empty entries are ignored. The entries are loaded after the volume
+	   paths arguments, so with '-on-conflict=last' they win over them and
+	   with '-on-conflict=first' the arguments win.
+	   (Default: none)
+
+	-wait-for=<duration>
+	   Waits for at most the duration (e.g. '-wait-for=30s') for each volume
+	   path, code volume and group to exist and not be empty before reading
+	   it, retrying with an exponential backoff. This avoids failing when an
+	   init container is still populating a volume. A folder is empty when
+	   it has no entries, a file when its size is 0. Other errors, like a
+	   permission denied, fail right away. It's disabled when 0.
+	   (Default: 0)
+
+	-warn-unused
+	   Reports on STDERR the loaded variables the template doesn't reference.
+	   The jsonnet interpreter looks for the std.extVar('NAME') and
+	   std.native('extVarDefault')('NAME', ...) calls of the template (not
+	   of the imported files) and the plain and html interpreters for the
+	   '.NAME', '$.NAME', 'var "NAME"', 'index . "NAME"' and
+	   'index vars "NAME"' references, including the included templates.
+	   The envsubst and jsonc interpreters record the references they
+	   replace. Other interpreters and -multi don't support it.
+
+	   Note that the environment variables loaded by -env are reported as
+	   well, restrict them with a prefix.
+	   (Default: false)
+
+	-watch
+	   After the first generation, keeps running and generates the outputs
+	   again each time one of the files read by the generation changes: the
+	   template and its '-include' files, the volume paths, the other
+	   variable files (e.g. '-code-volume', '-json-vars', '-env-file' or
+	   '-properties') and the '-schema', until it receives SIGINT or SIGTERM. It's meant to run as a sidecar: updates of
+	   the ConfigMap and Secret volumes made by Kubernetes, which swap the
+	   '..data' symbolic link, are detected. A failing generation is reported
+	   to STDERR and the previous outputs are kept. It can't be used when
+	   reading the template from STDIN.
+	   (Default: false)
+
+	-watch-debounce=<duration>
+	   When watching, the time to wait after the last change before
+	   generating again, so a burst of changes generates only once, e.g.
+	   '2s'.
+	   (Default: 500ms)
+
+	-workers=<count>
+	   The maximum number of files of a volume path read concurrently, which
+	   speeds up volumes containing many files, especially on network-backed
+	   storage. The variables don't depend on the order the files are read.
+	   (Default: the number of CPUs usable by the process, GOMAXPROCS)
+
+	-wrap=secret|configmap
+	   Writes a Kubernetes manifest holding the generated content instead of
+	   the content itself, ready for 'kubectl apply -f -'. The content is
+	   still encoded with -format (or the format of the output) before being
+	   wrapped, and the manifest is always YAML.
+
+	   When secret, writes a 'v1' 'Secret' of type 'Opaque' whose data
+	   holds the content base64 encoded.
+
+	   When configmap, writes a 'v1' 'ConfigMap' whose data holds the
+	   content as is, or whose binaryData holds it base64 encoded when it
+	   isn't valid UTF-8.
+
+	   It requires -wrap-name and can't be used with -multi, -split-dir or
+	   -compress.
+	   (Default: none)
+
+	-wrap-key=<key>
+	   When -wrap is set, the data key holding the content, e.g.
+	   'config.json' to mount it with this file name. It can contain
+	   letters, digits, '-', '_' and '.'.
+	   (Default: config)
+
+	-wrap-name=<name>
+	   When -wrap is set, the name of the Secret or the ConfigMap, a DNS
+	   subdomain made of lowercase letters, digits, '-' and '.'.
+	   (Default: none)
+
+	-wrap-namespace=<namespace>
+	   When -wrap is set, the namespace of the Secret or the ConfigMap. The
+	   manifest has no namespace when empty, so the one of the kubectl
+	   context is used.
+	   (Default: none)
+
+	-yaml-stream
+	   When the format is yaml, outputs each element of the array produced
+	   by the interpreter as its own YAML document, separated by '---', like
+	   'jsonnet -y'. Object keys are sorted so the output is stable. Any
+	   other content than an array is an error.
+	   (Default: false)
+
 Arguments
 
-	[volume-paths ...]
+	[volume-paths|NAME=file-path ...]
 	   a list of folder or files.
 
 	   When file: the content of the file will be loaded and set in a JSONNET
@@ -58,7 +1090,41 @@ Arguments
 
 	   When folder: the content of each of the file of the folder will be
 	   loaded and set in a JSONNET extVar named with the file name.
-	   The script doesn't load files in sub folders.
+	   The script doesn't load files in sub folders unless '-recursive' is
+	   set. Symbolic links are followed so the atomic writer layout used by
+	   Kubernetes to mount ConfigMap and Secret volumes loads each key once.
+
+	   When NAME=file-path: the content of the file will be loaded and set in
+	   a JSONNET extVar named NAME instead of the file name. NAME must start
+	   with a letter or '_' and contain only letters, digits and '_'. Only the
+	   first '=' separates the name from the path, and an argument matching an
+	   existing path is always read as a plain path.
+
+	   When pattern: an argument containing '*', '?' or '[' which isn't an
+	   existing path is expanded to the paths it matches (e.g.
+	   '/data/*/shared'), each one loaded as a file or a folder as above. The
+	   syntax is the one of Go's filepath.Match, '**' matches a single path
+	   element like '*'. A pattern matching nothing is an error unless
+	   -allow-empty-glob is set.
+
+	   The paths of -volumes are appended to these arguments. With
+	   -positional-in, the first argument can be the template instead.
+
+Exit codes
+
+	0  the content has been generated and written, or -if-changed wrote at
+	   least one file
+	1  a source, the template or an output can't be read, evaluated or
+	   written
+	2  the flags can't be parsed, or can't be used together
+	3  the variables or the content are invalid: a -require variable is
+	   missing, the content doesn't match the -schema or can't be parsed by
+	   -parse-output, -error-unused found unused variables, or the content
+	   is empty with -fail-on-empty
+	4  -if-changed didn't write any file as they are all up to date, even
+	   when the content is printed to STDOUT ('-out=-'). With -watch, only
+	   the errors stop the command
+	5  the generated content differs from the -diff file
 
 Examples
 
@@ -97,64 +1163,1851 @@ func (s *stringsFlag) Set(value string) error {
 	return nil
 }
 
-func main() {
-	var cfg = struct {
-		InterpreterName string
-		In              string
-		Outs            stringsFlag
-	}{
-		InterpreterName: "jsonnet",
-		In:              "-",
+type config struct {
+	AllowEmptyGlob     bool
+	AllowRemote        bool
+	Binary             string
+	BundleExtVar       string
+	BundleOnly         bool
+	ChecksumOut        string
+	CheckSyntax        bool
+	CodeVolumes        stringsFlag
+	Compact            bool
+	Compress           string
+	CompressLevel      int
+	DefaultInterpreter string
+	DelimLeft          string
+	DelimRight         string
+	Diff               string
+	DownwardFiles      stringsFlag
+	PropertiesFiles    stringsFlag
+	Prefixes           stringsFlag
+	DryRun             bool
+	DumpVars           dumpVarsFlag
+	Encoding           string
+	Env                envFlag
+	EnvFiles           stringsFlag
+	ErrorUnused        bool
+	FailOnEmpty        failOnEmptyFlag
+	Format             string
+	Groups             stringsFlag
+	Header             headerFlag
+	HeaderStyle        string
+	Hidden             bool
+	IfChanged          bool
+	InArchive          string
+	Includes           stringsFlag
+	IncludeFiles       stringsFlag
+	ExcludeFiles       stringsFlag
+	Indent             string
+	InjectMetadata     bool
+	Ins                stringsFlag
+	InterpolateVars    bool
+	InterpreterName    string
+	JPaths             stringsFlag
+	JSONVars           stringsFlag
+	Layers             stringsFlag
+	LazyVolumes        stringsFlag
+	ListInterpreters   bool
+	ListOutputs        bool
+	Lock               bool
+	LogFormat          string
+	MaxFileSize        int64
+	Merge              bool
+	Mkdir              bool
+	Mode               string
+	Multi              string
+	NameTransform      string
+	OnConflict         string
+	Opts               stringsFlag
+	OutIfs             stringsFlag
+	Outs               stringsFlag
+	Parse              string
+	ParseOutput        bool
+	PositionalIn       bool
+	Quiet              bool
+	Recursive          bool
+	RemoteTimeout      time.Duration
+	Required           stringsFlag
+	Schema             string
+	Separator          string
+	SetCodes           stringsFlag
+	Sets               stringsFlag
+	SplitDir           string
+	Sprig              string
+	StdinVars          bool
+	Stream             bool
+	Strict             bool
+	StripExt           bool
+	Timeout            time.Duration
+	TLACodes           stringsFlag
+	TLAVars            stringsFlag
+	Trace              bool
+	Trim               string
+	VaultPaths         stringsFlag
+	Verbose            bool
+	Version            bool
+	VolumeList         string
+	Volumes            []string
+	WaitFor            time.Duration
+	WarnUnused         bool
+	Watch              bool
+	WatchDebounce      time.Duration
+	Workers            int
+	Wrap               string
+	WrapKey            string
+	WrapName           string
+	WrapNamespace      string
+	YAMLStream         bool
+
+	// logger writes the messages as JSON objects with -log-format=json, they are written as text
+	// when nil
+	logger *slog.Logger
+}
+
+// Exit codes of the command. They are part of its interface and mustn't change
+const (
+	exitOK = 0
+	// exitFailed reports an error reading the sources, evaluating the template or writing the
+	// outputs
+	exitFailed = 1
+	// exitUsage reports invalid flags or flag combinations, it's the code used by the flag package
+	exitUsage = 2
+	// exitInvalid reports variables or content not satisfying -require, -schema, -parse-output,
+	// -error-unused or -fail-on-empty
+	exitInvalid = 3
+	// exitUnchanged reports that -if-changed didn't write any file as they are all up to date
+	exitUnchanged = 4
+	// exitDiffers reports that the generated content differs from the -diff file
+	exitDiffers = 5
+)
+
+var (
+	// errUnchanged is returned by run when -if-changed didn't write any output
+	errUnchanged = errors.New("all the outputs are unchanged")
+	// errDiffers is returned by run when the generated content differs from the -diff file, the
+	// diff being already reported
+	errDiffers = errors.New("the generated content differs")
+)
+
+// exitError associates an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+// exitCode returns the exit code matching the error returned by run
+func exitCode(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return exitOK
+	case exitError:
+		return err.code
+	default:
+		if err == errUnchanged {
+			return exitUnchanged
+		}
+
+		if err == errDiffers {
+			return exitDiffers
+		}
+
+		return exitFailed
 	}
+}
 
-	flag.Usage = func() { fmt.Fprintf(flag.CommandLine.Output(), usageFmt, filepath.Base(os.Args[0])) }
-	flag.StringVar(&cfg.InterpreterName, "interpreter", cfg.InterpreterName, "")
-	flag.StringVar(&cfg.In, "in", cfg.In, "")
-	flag.Var(&cfg.Outs, "out", "")
+// Formats of the messages written to STDERR
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
 
-	flag.Parse()
+// newLogger returns the logger writing JSON objects to STDERR for -log-format=json, or nil for
+// text. The levels are lowercase, e.g. 'debug'
+func newLogger(logFormat string) (*slog.Logger, error) {
+	switch logFormat {
+	case logFormatText:
+		return nil, nil
+	case logFormatJSON:
+		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+				if attr.Key == slog.LevelKey && len(groups) == 0 {
+					attr.Value = slog.StringValue(strings.ToLower(attr.Value.String()))
+				}
 
-	if len(cfg.Outs) == 0 {
-		cfg.Outs = append(cfg.Outs, "-")
+				return attr
+			},
+		})
+
+		return slog.New(handler), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format '%s'", logFormat)
 	}
+}
 
-	if err := run(cfg.InterpreterName, cfg.In, cfg.Outs, flag.Args()); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+// logf reports a message on STDERR when the verbose mode is enabled
+func (c config) logf(format string, args ...interface{}) {
+	if c.Verbose {
+		c.log(slog.LevelDebug, fmt.Sprintf(format, args...))
 	}
 }
 
-func run(interpreterName string, inputPath string, outputPaths []string, volumes []string) error {
-	runtime, found := interpreter.Get(interpreterName)
-	if !found {
-		return fmt.Errorf("unsupported interpreter '%s'", interpreterName)
+// notef reports a message on STDERR unless the quiet mode is enabled
+func (c config) notef(format string, args ...interface{}) {
+	if !c.Quiet {
+		c.log(slog.LevelInfo, fmt.Sprintf(format, args...))
 	}
+}
 
-	input, err := file.OpenInput(inputPath)
-	if err != nil {
-		return fmt.Errorf("can't open input file '%s': %v", inputPath, err)
+// errorf reports an error on STDERR, even in quiet mode
+func (c config) errorf(format string, args ...interface{}) {
+	c.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (c config) log(level slog.Level, msg string) {
+	if c.logger == nil {
+		fmt.Fprintln(os.Stderr, msg)
+		return
 	}
-	defer input.Close()
 
-	content, err := internal.Generate(runtime, input, volumes)
-	if err != nil {
-		return fmt.Errorf("can't generate content: %v", err)
+	c.logger.Log(context.Background(), level, msg)
+}
+
+type envFlag struct {
+	Enabled bool
+	Prefix  string
+}
+
+func (e *envFlag) String() string {
+	if e == nil || !e.Enabled {
+		return ""
 	}
 
-	outputs := make([]*os.File, len(outputPaths))
-	for i, outputPath := range outputPaths {
-		output, err := file.OpenOutput(outputPath)
-		if err != nil {
-			return fmt.Errorf("can't open output file '%s': %v", outputPath, err)
-		}
-		defer output.Close()
+	return e.Prefix
+}
+
+func (e *envFlag) Set(value string) error {
+	switch value {
+	case "true":
+		e.Enabled, e.Prefix = true, ""
+	case "false":
+		e.Enabled, e.Prefix = false, ""
+	default:
+		e.Enabled, e.Prefix = true, value
+	}
 
-		outputs[i] = output
+	return nil
+}
+
+func (e *envFlag) IsBoolFlag() bool {
+	return true
+}
+
+type dumpVarsFlag struct {
+	Enabled  bool
+	Redacted bool
+}
+
+func (d *dumpVarsFlag) String() string {
+	if d == nil || !d.Enabled {
+		return ""
+	}
+
+	if d.Redacted {
+		return "redacted"
+	}
+
+	return "true"
+}
+
+func (d *dumpVarsFlag) Set(value string) error {
+	switch value {
+	case "true":
+		d.Enabled, d.Redacted = true, false
+	case "false":
+		d.Enabled, d.Redacted = false, false
+	case "redacted":
+		d.Enabled, d.Redacted = true, true
+	default:
+		return fmt.Errorf("expected redacted")
+	}
+
+	return nil
+}
+
+func (d *dumpVarsFlag) IsBoolFlag() bool {
+	return true
+}
+
+type failOnEmptyFlag struct {
+	Enabled bool
+	Blank   bool
+}
+
+func (f *failOnEmptyFlag) String() string {
+	if f == nil || !f.Enabled {
+		return ""
+	}
+
+	if f.Blank {
+		return "blank"
 	}
 
-	for i := range outputs {
-		fmt.Fprint(outputs[i], content)
+	return "true"
+}
+
+func (f *failOnEmptyFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.Enabled, f.Blank = true, false
+	case "false":
+		f.Enabled, f.Blank = false, false
+	case "blank":
+		f.Enabled, f.Blank = true, true
+	default:
+		return fmt.Errorf("expected blank")
 	}
 
 	return nil
 }
+
+func (f *failOnEmptyFlag) IsBoolFlag() bool {
+	return true
+}
+
+// defaultHeader is the text of the header added by -header without any value
+const defaultHeader = "Generated by cfgenerator, do not edit"
+
+type headerFlag struct {
+	Enabled bool
+	Text    string
+}
+
+func (h *headerFlag) String() string {
+	if h == nil || !h.Enabled {
+		return ""
+	}
+
+	return h.Text
+}
+
+func (h *headerFlag) Set(value string) error {
+	switch value {
+	case "true":
+		h.Enabled, h.Text = true, defaultHeader
+	case "false":
+		h.Enabled, h.Text = false, ""
+	default:
+		h.Enabled, h.Text = true, value
+	}
+
+	return nil
+}
+
+func (h *headerFlag) IsBoolFlag() bool {
+	return true
+}
+
+// isEmpty returns true when the content is empty, or only made of white spaces when blank. The
+// content of a data interpreter is also empty when it's a JSON or YAML null or empty string, like
+// a template evaluated to null or to ""
+func (f failOnEmptyFlag) isEmpty(generated generatedFile, data bool) bool {
+	content := generated.content
+	if content == "" || (f.Blank && strings.TrimSpace(content) == "") {
+		return true
+	}
+
+	if !data || (generated.format != "" && generated.format != format.JSON && generated.format != format.YAML) {
+		return false
+	}
+
+	decoded, err := format.Decode(content, generated.format, format.Options{})
+	if err != nil {
+		return false
+	}
+
+	switch strings.TrimSpace(decoded) {
+	case "null", `""`:
+		return true
+	default:
+		return false
+	}
+}
+
+func main() {
+	var cfg = config{
+		Binary:             string(volume.BinaryRaw),
+		Format:             string(format.JSON),
+		InterpreterName:    interpreter.Default,
+		OnConflict:         string(variable.ConflictError),
+		Separator:          volume.DefaultSeparator,
+		Sprig:              string(interpreter.SprigFull),
+		Trim:               string(volume.TrimSpace),
+		WatchDebounce:      watch.DefaultDebounce,
+		MaxFileSize:        volume.DefaultMaxFileSize,
+		Compress:           string(file.CompressionNone),
+		CompressLevel:      gzip.DefaultCompression,
+		NameTransform:      string(volume.NameNone),
+		Parse:              string(volume.FormatNone),
+		Encoding:           string(volume.EncodingUTF8),
+		HeaderStyle:        string(format.HeaderAuto),
+		DefaultInterpreter: interpreter.Default,
+		RemoteTimeout:      file.DefaultRemoteTimeout,
+		WrapKey:            manifest.DefaultKey,
+		LogFormat:          logFormatText,
+	}
+
+	flag.Usage = func() { fmt.Fprintf(flag.CommandLine.Output(), usageFmt, filepath.Base(os.Args[0])) }
+	flag.BoolVar(&cfg.AllowEmptyGlob, "allow-empty-glob", cfg.AllowEmptyGlob, "")
+	flag.StringVar(&cfg.Binary, "binary", cfg.Binary, "")
+	flag.StringVar(&cfg.BundleExtVar, "bundle-extvar", cfg.BundleExtVar, "")
+	flag.BoolVar(&cfg.AllowRemote, "allow-remote", cfg.AllowRemote, "")
+	flag.BoolVar(&cfg.BundleOnly, "bundle-only", cfg.BundleOnly, "")
+	flag.Var(&cfg.CodeVolumes, "code-volume", "")
+	flag.BoolVar(&cfg.CheckSyntax, "check-syntax", cfg.CheckSyntax, "")
+	flag.StringVar(&cfg.ChecksumOut, "checksum-out", cfg.ChecksumOut, "")
+	flag.BoolVar(&cfg.Compact, "compact", cfg.Compact, "")
+	flag.StringVar(&cfg.Compress, "compress", cfg.Compress, "")
+	flag.IntVar(&cfg.CompressLevel, "compress-level", cfg.CompressLevel, "")
+	flag.StringVar(&cfg.DefaultInterpreter, "default-interpreter", cfg.DefaultInterpreter, "")
+	flag.StringVar(&cfg.DelimLeft, "delim-left", cfg.DelimLeft, "")
+	flag.StringVar(&cfg.DelimRight, "delim-right", cfg.DelimRight, "")
+	flag.StringVar(&cfg.Diff, "diff", cfg.Diff, "")
+	flag.Var(&cfg.DownwardFiles, "downward", "")
+	flag.Var(&cfg.PropertiesFiles, "properties", "")
+	flag.Var(&cfg.Prefixes, "prefix", "")
+	flag.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "")
+	flag.Var(&cfg.DumpVars, "dump-vars", "")
+	flag.StringVar(&cfg.Encoding, "encoding", cfg.Encoding, "")
+	flag.Var(&cfg.Env, "env", "")
+	flag.Var(&cfg.EnvFiles, "env-file", "")
+	flag.BoolVar(&cfg.ErrorUnused, "error-unused", cfg.ErrorUnused, "")
+	flag.Var(&cfg.FailOnEmpty, "fail-on-empty", "")
+	flag.StringVar(&cfg.Format, "format", cfg.Format, "")
+	flag.Var(&cfg.Groups, "group", "")
+	flag.Var(&cfg.Header, "header", "")
+	flag.StringVar(&cfg.HeaderStyle, "header-style", cfg.HeaderStyle, "")
+	flag.BoolVar(&cfg.Hidden, "hidden", cfg.Hidden, "")
+	flag.BoolVar(&cfg.IfChanged, "if-changed", cfg.IfChanged, "")
+	flag.BoolVar(&cfg.InjectMetadata, "inject-metadata", cfg.InjectMetadata, "")
+	flag.BoolVar(&cfg.InterpolateVars, "interpolate-vars", cfg.InterpolateVars, "")
+	flag.StringVar(&cfg.InterpreterName, "interpreter", cfg.InterpreterName, "")
+	flag.Var(&cfg.Ins, "in", "")
+	flag.StringVar(&cfg.InArchive, "in-archive", cfg.InArchive, "")
+	flag.Var(&cfg.Includes, "include", "")
+	flag.Var(&cfg.IncludeFiles, "include-file", "")
+	flag.Var(&cfg.ExcludeFiles, "exclude-file", "")
+	flag.StringVar(&cfg.Indent, "indent", cfg.Indent, "")
+	flag.Var(&cfg.JSONVars, "json-vars", "")
+	flag.Var(&cfg.Layers, "layer", "")
+	flag.Var(&cfg.LazyVolumes, "lazy-volume", "")
+	flag.Var(&cfg.JPaths, "J", "")
+	flag.Var(&cfg.JPaths, "jpath", "")
+	flag.BoolVar(&cfg.ListInterpreters, "list-interpreters", cfg.ListInterpreters, "")
+	flag.BoolVar(&cfg.ListOutputs, "list-outputs", cfg.ListOutputs, "")
+	flag.BoolVar(&cfg.Lock, "lock", cfg.Lock, "")
+	flag.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "")
+	flag.Int64Var(&cfg.MaxFileSize, "max-file-size", cfg.MaxFileSize, "")
+	flag.BoolVar(&cfg.Mkdir, "mkdir", cfg.Mkdir, "")
+	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "")
+	flag.BoolVar(&cfg.Merge, "merge", cfg.Merge, "")
+	flag.StringVar(&cfg.Multi, "multi", cfg.Multi, "")
+	flag.StringVar(&cfg.NameTransform, "name-transform", cfg.NameTransform, "")
+	flag.StringVar(&cfg.OnConflict, "on-conflict", cfg.OnConflict, "")
+	flag.Var(&cfg.Opts, "opt", "")
+	flag.Var(&cfg.Outs, "out", "")
+	flag.Var(&cfg.OutIfs, "out-if", "")
+	flag.StringVar(&cfg.Parse, "parse", cfg.Parse, "")
+	flag.BoolVar(&cfg.ParseOutput, "parse-output", cfg.ParseOutput, "")
+	flag.BoolVar(&cfg.PositionalIn, "positional-in", cfg.PositionalIn, "")
+	flag.BoolVar(&cfg.Quiet, "quiet", cfg.Quiet, "")
+	flag.BoolVar(&cfg.Quiet, "q", cfg.Quiet, "")
+	flag.BoolVar(&cfg.Recursive, "recursive", cfg.Recursive, "")
+	flag.DurationVar(&cfg.RemoteTimeout, "remote-timeout", cfg.RemoteTimeout, "")
+	flag.Var(&cfg.Required, "require", "")
+	flag.StringVar(&cfg.Schema, "schema", cfg.Schema, "")
+	flag.StringVar(&cfg.Separator, "separator", cfg.Separator, "")
+	flag.Var(&cfg.Sets, "set", "")
+	flag.Var(&cfg.SetCodes, "set-code", "")
+	flag.StringVar(&cfg.Sprig, "sprig", cfg.Sprig, "")
+	flag.StringVar(&cfg.SplitDir, "split-dir", cfg.SplitDir, "")
+	flag.BoolVar(&cfg.StdinVars, "stdin-vars", cfg.StdinVars, "")
+	flag.BoolVar(&cfg.Stream, "stream", cfg.Stream, "")
+	flag.BoolVar(&cfg.Strict, "strict", cfg.Strict, "")
+	flag.BoolVar(&cfg.StripExt, "strip-ext", cfg.StripExt, "")
+	flag.Var(&cfg.TLACodes, "tla-code", "")
+	flag.Var(&cfg.TLAVars, "tla-str", "")
+	flag.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "")
+	flag.BoolVar(&cfg.Trace, "trace", cfg.Trace, "")
+	flag.StringVar(&cfg.Trim, "trim", cfg.Trim, "")
+	flag.BoolVar(&cfg.Verbose, "v", cfg.Verbose, "")
+	flag.Var(&cfg.VaultPaths, "vault", "")
+	flag.BoolVar(&cfg.Verbose, "verbose", cfg.Verbose, "")
+	flag.BoolVar(&cfg.Version, "version", cfg.Version, "")
+	flag.StringVar(&cfg.VolumeList, "volumes", cfg.VolumeList, "")
+	flag.BoolVar(&cfg.WarnUnused, "warn-unused", cfg.WarnUnused, "")
+	flag.BoolVar(&cfg.Watch, "watch", cfg.Watch, "")
+	flag.DurationVar(&cfg.WaitFor, "wait-for", cfg.WaitFor, "")
+	flag.DurationVar(&cfg.WatchDebounce, "watch-debounce", cfg.WatchDebounce, "")
+	flag.IntVar(&cfg.Workers, "workers", cfg.Workers, "")
+	flag.StringVar(&cfg.Wrap, "wrap", cfg.Wrap, "")
+	flag.StringVar(&cfg.WrapKey, "wrap-key", cfg.WrapKey, "")
+	flag.StringVar(&cfg.WrapName, "wrap-name", cfg.WrapName, "")
+	flag.StringVar(&cfg.WrapNamespace, "wrap-namespace", cfg.WrapNamespace, "")
+	flag.BoolVar(&cfg.YAMLStream, "yaml-stream", cfg.YAMLStream, "")
+
+	flag.Parse()
+
+	if cfg.Version {
+		fmt.Printf("%s version %s (commit: %s, built at: %s)\n", filepath.Base(os.Args[0]), version, commit, buildDate)
+		return
+	}
+
+	if cfg.ListInterpreters {
+		for _, name := range cfgenerator.Names() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	logger, err := newLogger(cfg.LogFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailed)
+	}
+
+	cfg.logger = logger
+
+	args := flag.Args()
+	if cfg.PositionalIn && len(cfg.Ins) == 0 && cfg.InArchive == "" && len(args) > 0 && isTemplateFile(args[0]) {
+		cfg.logf("reading template from the argument '%s'", args[0])
+
+		cfg.Ins, args = []string{args[0]}, args[1:]
+	}
+
+	if len(cfg.Ins) == 0 && cfg.InArchive == "" {
+		cfg.Ins = append(cfg.Ins, "-")
+	}
+
+	if len(cfg.Outs) == 0 && cfg.Multi == "" && cfg.SplitDir == "" {
+		cfg.Outs = append(cfg.Outs, "-")
+	}
+
+	cfg.Volumes = args
+	for _, volumePath := range filepath.SplitList(cfg.VolumeList) {
+		if volumePath != "" {
+			cfg.Volumes = append(cfg.Volumes, volumePath)
+		}
+	}
+
+	err = run(cfg)
+	if err != nil && err != errUnchanged && err != errDiffers {
+		cfg.errorf("%v", err)
+		os.Exit(exitCode(err))
+	}
+
+	if cfg.Watch {
+		if err := watchChanges(cfg); err != nil {
+			cfg.errorf("%v", err)
+			os.Exit(exitFailed)
+		}
+
+		return
+	}
+
+	os.Exit(exitCode(err))
+}
+
+func watchChanges(cfg config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	paths, err := watchedPaths(cfg)
+	if err != nil {
+		return err
+	}
+
+	return watch.Watch(ctx, paths, cfg.WatchDebounce, func() {
+		if err := run(cfg); err != nil && err != errUnchanged {
+			cfg.errorf("can't regenerate content: %v", err)
+		}
+	})
+}
+
+// watchedPaths returns all the files and folders read by a generation: the templates and their
+// includes, the variable sources and the schema
+func watchedPaths(cfg config) ([]string, error) {
+	paths := append([]string{}, cfg.Ins...)
+	if cfg.InArchive != "" {
+		paths = append(paths, cfg.InArchive)
+	}
+	for _, pattern := range cfg.Includes {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern '%s': %v", pattern, err)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	volumes, err := volume.ExpandGlobs(cfg.Volumes, cfg.AllowEmptyGlob)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, volumePath := range volumes {
+		if _, err := os.Stat(volumePath); err != nil {
+			if parts := strings.SplitN(volumePath, "=", 2); len(parts) == 2 {
+				volumePath = parts[1]
+			}
+		}
+
+		paths = append(paths, volumePath)
+	}
+
+	paths = append(paths, cfg.Layers...)
+	paths = append(paths, cfg.CodeVolumes...)
+	paths = append(paths, cfg.LazyVolumes...)
+	paths = append(paths, cfg.JSONVars...)
+	paths = append(paths, cfg.EnvFiles...)
+	paths = append(paths, cfg.DownwardFiles...)
+	paths = append(paths, cfg.PropertiesFiles...)
+	for _, group := range cfg.Groups {
+		if parts := strings.SplitN(group, "=", 2); len(parts) == 2 {
+			paths = append(paths, parts[1])
+		}
+	}
+
+	if cfg.Schema != "" {
+		paths = append(paths, cfg.Schema)
+	}
+
+	return paths, nil
+}
+
+func run(cfg config) error {
+	if cfg.Quiet && cfg.Verbose {
+		return exitError{code: exitUsage, err: fmt.Errorf("-quiet and -verbose can't be used together")}
+	}
+
+	if cfg.InArchive != "" && len(cfg.Ins) > 0 {
+		return exitError{code: exitUsage, err: fmt.Errorf("-in and -in-archive can't be used together")}
+	}
+
+	stdinCount := 0
+	for _, inputPath := range append(cfg.Ins, cfg.InArchive) {
+		if inputPath == "-" {
+			stdinCount++
+		}
+	}
+
+	if stdinCount > 1 {
+		return exitError{code: exitUsage, err: fmt.Errorf("-in=- can't be used several times")}
+	}
+
+	if cfg.StdinVars && stdinCount > 0 {
+		return exitError{code: exitUsage, err: fmt.Errorf("-stdin-vars can't be used when reading the template from STDIN, use -in=<template-path>")}
+	}
+
+	if cfg.Watch && (stdinCount > 0 || cfg.StdinVars) {
+		return exitError{code: exitUsage, err: fmt.Errorf("-watch can't be used when reading the template from STDIN")}
+	}
+
+	if cfg.Multi != "" && len(cfg.Outs) > 0 {
+		return exitError{code: exitUsage, err: fmt.Errorf("-multi and -out can't be used together")}
+	}
+
+	if cfg.SplitDir != "" && (cfg.Multi != "" || len(cfg.Outs) > 0) {
+		return exitError{code: exitUsage, err: fmt.Errorf("-split-dir can't be used with -multi or -out")}
+	}
+
+	for _, inputPath := range cfg.Ins {
+		if !file.IsRemote(inputPath) {
+			continue
+		}
+
+		if !cfg.AllowRemote {
+			return exitError{code: exitUsage, err: fmt.Errorf("can't read input '%s': remote inputs require -allow-remote", inputPath)}
+		}
+
+		if cfg.Watch {
+			return exitError{code: exitUsage, err: fmt.Errorf("-watch can't be used with a remote input")}
+		}
+	}
+
+	if cfg.Diff != "" {
+		if cfg.Multi != "" || cfg.SplitDir != "" || cfg.Watch || cfg.Compress != string(file.CompressionNone) {
+			return exitError{code: exitUsage, err: fmt.Errorf("-diff can't be used with -multi, -split-dir, -watch or -compress")}
+		}
+
+		if len(cfg.Outs) > 1 {
+			return exitError{code: exitUsage, err: fmt.Errorf("-diff can't be used with several -out")}
+		}
+
+		if len(cfg.OutIfs) > 0 {
+			return exitError{code: exitUsage, err: fmt.Errorf("-diff can't be used with -out-if")}
+		}
+	}
+
+	if cfg.ListOutputs && (cfg.DumpVars.Enabled || cfg.Diff != "" || cfg.Watch) {
+		return exitError{code: exitUsage, err: fmt.Errorf("-list-outputs can't be used with -dump-vars, -diff or -watch")}
+	}
+
+	if cfg.Wrap != "" {
+		if _, err := manifest.ParseKind(cfg.Wrap); err != nil {
+			return err
+		}
+
+		if cfg.Multi != "" || cfg.SplitDir != "" || cfg.Compress != string(file.CompressionNone) {
+			return exitError{code: exitUsage, err: fmt.Errorf("-wrap can't be used with -multi, -split-dir or -compress")}
+		}
+
+		if cfg.WrapName == "" {
+			return exitError{code: exitUsage, err: fmt.Errorf("-wrap requires -wrap-name")}
+		}
+
+		if err := wrapOptions(cfg).Validate(); err != nil {
+			return fmt.Errorf("invalid -wrap options: %v", err)
+		}
+	}
+
+	if cfg.WaitFor < 0 {
+		return fmt.Errorf("invalid wait duration '%s': expected a positive duration", cfg.WaitFor)
+	}
+
+	if cfg.RemoteTimeout < 0 {
+		return fmt.Errorf("invalid remote timeout '%s': expected a positive duration", cfg.RemoteTimeout)
+	}
+
+	volumeOptions, err := parseVolumeOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	var archive volume.Archive
+	templatePath := ""
+	if cfg.InArchive != "" {
+		archive, err = loadArchive(cfg.InArchive, volumeOptions)
+		if err != nil {
+			return err
+		}
+
+		templatePath = archive.TemplateName
+	} else {
+		templatePath = cfg.Ins[0]
+		if file.IsRemote(templatePath) {
+			templatePath = file.RemotePath(templatePath)
+		}
+	}
+
+	interpreterName := cfg.InterpreterName
+	if interpreterName == interpreter.Auto {
+		name, err := interpreter.DetectWithFallback(templatePath, cfg.DefaultInterpreter)
+		if err != nil {
+			return err
+		}
+
+		interpreterName = name
+	}
+
+	runtime, found := cfgenerator.Get(interpreterName)
+	if !found {
+		return fmt.Errorf("unsupported interpreter '%s'", interpreterName)
+	}
+
+	cfg.logf("using interpreter '%s'", interpreterName)
+
+	if cfg.Merge {
+		if len(cfg.Ins) < 2 {
+			return exitError{code: exitUsage, err: fmt.Errorf("-merge requires at least two -in")}
+		}
+
+		if cfg.Multi != "" {
+			return exitError{code: exitUsage, err: fmt.Errorf("-merge can't be used with -multi")}
+		}
+
+		if isTextInterpreter(runtime) {
+			return exitError{code: exitUsage, err: fmt.Errorf("-merge can't be used with the plain, html and envsubst interpreters")}
+		}
+	} else if len(cfg.Ins) > 1 && !isTextInterpreter(runtime) {
+		return exitError{code: exitUsage, err: fmt.Errorf("several -in can only be used with the plain, html and envsubst interpreters, or with -merge, not '%s'", interpreterName)}
+	}
+
+	if err := configure(runtime, cfg); err != nil {
+		return err
+	}
+
+	if err := applyOptions(runtime, interpreterName, cfg); err != nil {
+		return err
+	}
+
+	if cfg.CheckSyntax {
+		return checkSyntax(runtime, cfg, archive)
+	}
+
+	if cfg.ParseOutput {
+		if cfg.Format == string(format.Auto) {
+			return exitError{code: exitUsage, err: fmt.Errorf("-parse-output can't be used with -format=auto")}
+		}
+
+		if cfg.Multi != "" || cfg.SplitDir != "" {
+			return exitError{code: exitUsage, err: fmt.Errorf("-parse-output can't be used with -multi or -split-dir")}
+		}
+
+		if !isTextInterpreter(runtime) {
+			return exitError{code: exitUsage, err: fmt.Errorf("-parse-output can only be used with the plain, html and envsubst interpreters, not '%s'", interpreterName)}
+		}
+	}
+
+	if cfg.WarnUnused || cfg.ErrorUnused {
+		if cfg.Multi != "" || cfg.SplitDir != "" {
+			return exitError{code: exitUsage, err: fmt.Errorf("-warn-unused and -error-unused can't be used with -multi or -split-dir")}
+		}
+
+		if _, ok := runtime.(cfgenerator.TrackingInterpreter); !ok {
+			return exitError{code: exitUsage, err: fmt.Errorf("-warn-unused and -error-unused aren't supported by the '%s' interpreter", interpreterName)}
+		}
+	}
+
+	conflict, err := variable.ParseConflict(cfg.OnConflict)
+	if err != nil {
+		return err
+	}
+
+	outputFormat, err := format.Parse(cfg.Format)
+	if err != nil {
+		return err
+	}
+
+	headerStyle, err := format.ParseHeaderStyle(cfg.HeaderStyle)
+	if err != nil {
+		return err
+	}
+
+	for _, out := range cfg.Outs {
+		if _, qualifier := splitOutput(out); qualifier != "" && isTextInterpreter(runtime) {
+			return exitError{code: exitUsage, err: fmt.Errorf("can't write '%s': -out formats can't be used with the plain, html and envsubst interpreters", out)}
+		}
+	}
+
+	var contentSchema *schema.Schema
+	if cfg.Schema != "" {
+		if outputFormat != format.JSON && outputFormat != format.Auto {
+			return exitError{code: exitUsage, err: fmt.Errorf("-schema can only be used with -format=json or -format=auto")}
+		}
+
+		contentSchema, err = schema.Load(cfg.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid timeout '%s': expected a positive duration", cfg.Timeout)
+	}
+
+	groups, err := parseAssignments("group", cfg.Groups)
+	if err != nil {
+		return err
+	}
+
+	prefixes, err := parsePrefixes(cfg.Prefixes)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := parseOverrides(cfg)
+	if err != nil {
+		return err
+	}
+
+	guards, err := parseOutputGuards(cfg)
+	if err != nil {
+		return err
+	}
+
+	formatOptions := format.Options{
+		YAMLStream: cfg.YAMLStream,
+		Compact:    cfg.Compact,
+	}
+
+	if cfg.Indent != "" {
+		indent, compact, err := format.ParseIndent(cfg.Indent)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Compact && !compact {
+			return exitError{code: exitUsage, err: fmt.Errorf("-compact and -indent=%s can't be used together", cfg.Indent)}
+		}
+
+		formatOptions.Indent, formatOptions.Compact = indent, compact
+	}
+
+	compression, err := file.ParseCompression(cfg.Compress)
+	if err != nil {
+		return err
+	}
+
+	outputOptions := file.OutputOptions{
+		MkdirAll: cfg.Mkdir,
+		Lock:     cfg.Lock,
+	}
+
+	if cfg.Mode != "" {
+		mode, err := file.ParseMode(cfg.Mode)
+		if err != nil {
+			return err
+		}
+
+		outputOptions.Mode = mode
+	}
+
+	if !cfg.DryRun && cfg.Diff == "" && !cfg.ListOutputs {
+		// fails before the evaluation, which can be long, when an output can't be written
+		outputPaths := make([]string, 0, len(cfg.Outs)+1)
+		for _, out := range cfg.Outs {
+			outputPath, _ := splitOutput(out)
+			outputPaths = append(outputPaths, outputPath)
+		}
+
+		if cfg.ChecksumOut != "" {
+			outputPaths = append(outputPaths, cfg.ChecksumOut)
+		}
+
+		for _, outputPath := range outputPaths {
+			if err := file.CheckWritable(outputPath, outputOptions); err != nil {
+				return fmt.Errorf("can't write output file '%s': %v", outputPath, err)
+			}
+		}
+	}
+
+	var inputs []io.Reader
+	if cfg.InArchive != "" {
+		inputs = append(inputs, strings.NewReader(archive.Template))
+	}
+
+	for _, inputPath := range cfg.Ins {
+		cfg.logf("reading template '%s'", inputPath)
+
+		input, err := openInput(inputPath, cfg)
+		if err != nil {
+			return fmt.Errorf("can't open input file '%s': %v", inputPath, err)
+		}
+		defer input.Close()
+
+		inputs = append(inputs, input)
+	}
+	input := io.MultiReader(inputs...)
+
+	var overlays []io.Reader
+	if cfg.Merge {
+		input, overlays = inputs[0], inputs[1:]
+	}
+
+	variables := archive.Variables
+	if len(cfg.VaultPaths) > 0 {
+		vaultVariables, err := loadVault(cfg)
+		if err != nil {
+			return err
+		}
+
+		variables = append(variables, vaultVariables...)
+	}
+
+	opts := cfgenerator.Options{
+		Volume:          volumeOptions,
+		AllowEmptyGlob:  cfg.AllowEmptyGlob,
+		Variables:       variables,
+		CodeVolumes:     cfg.CodeVolumes,
+		Conflict:        conflict,
+		Layers:          cfg.Layers,
+		Env:             cfg.Env.Enabled,
+		EnvPrefix:       cfg.Env.Prefix,
+		EnvFiles:        cfg.EnvFiles,
+		DownwardFiles:   cfg.DownwardFiles,
+		PropertiesFiles: cfg.PropertiesFiles,
+		Groups:          groups,
+		Prefixes:        prefixes,
+		InjectMetadata:  cfg.InjectMetadata,
+		InterpolateVars: cfg.InterpolateVars,
+		JSONVars:        cfg.JSONVars,
+		Overlays:        overlays,
+		Overrides:       overrides,
+		Required:        cfg.Required,
+		Schema:          contentSchema,
+		Format:          outputFormat,
+		FormatOptions:   formatOptions,
+		ParseOutput:     cfg.ParseOutput,
+		ReformatText:    isTextInterpreter(runtime),
+		Timeout:         cfg.Timeout,
+		WaitFor:         cfg.WaitFor,
+	}
+
+	if cfg.StdinVars {
+		opts.JSONVarsInput = os.Stdin
+	}
+
+	if cfg.Verbose {
+		opts.Logf = cfg.logf
+	}
+
+	if cfg.DumpVars.Enabled {
+		return dumpVars(runtime, cfg, opts)
+	}
+
+	if cfg.ListOutputs {
+		return listOutputs(runtime, input, cfg, opts, guards)
+	}
+
+	if cfg.Stream {
+		if canStream(cfg, guards) && cfgenerator.CanStream(runtime, opts) {
+			return stream(runtime, input, cfg, opts, outputOptions)
+		}
+
+		cfg.logf("can't stream the content with these flags, holding it in memory")
+	}
+
+	files, err := generate(runtime, input, cfg, opts, guards)
+	if err != nil {
+		if cfgenerator.IsValidationError(err) {
+			return exitError{code: exitInvalid, err: fmt.Errorf("can't generate content: %v", err)}
+		}
+
+		return fmt.Errorf("can't generate content: %v", err)
+	}
+
+	if cfg.FailOnEmpty.Enabled {
+		for _, generated := range files {
+			if cfg.FailOnEmpty.isEmpty(generated, !isTextInterpreter(runtime)) {
+				return exitError{code: exitInvalid, err: fmt.Errorf("can't generate content: the content of '%s' is empty", generated.path)}
+			}
+		}
+	}
+
+	if cfg.Header.Enabled {
+		for i, generated := range files {
+			files[i].content, err = format.AddHeader(generated.content, cfg.Header.Text, generated.format, headerStyle)
+			if err != nil {
+				return fmt.Errorf("can't add header to '%s': %v", generated.path, err)
+			}
+		}
+	}
+
+	if cfg.Diff != "" {
+		return diffContent(cfg, files[0].content)
+	}
+
+	var outputs []*file.Output
+	checksummed := make(map[string]string, len(files))
+	for _, generated := range files {
+		outputPath := generated.path
+
+		content, err := file.Compress(generated.content, compression, cfg.CompressLevel)
+		if err != nil {
+			return fmt.Errorf("can't compress content of '%s': %v", outputPath, err)
+		}
+
+		checksummed[outputPath] = content
+
+		if cfg.DryRun && outputPath != "-" {
+			cfg.notef("would write %d bytes to '%s'", len(content), outputPath)
+			continue
+		}
+
+		if cfg.IfChanged && outputPath != "-" {
+			unchanged, err := file.HasContent(outputPath, content)
+			if err != nil {
+				return fmt.Errorf("can't read output file '%s': %v", outputPath, err)
+			}
+
+			if unchanged {
+				cfg.notef("'%s' unchanged", outputPath)
+				continue
+			}
+		}
+
+		output, err := file.OpenOutput(outputPath, outputOptions)
+		if err != nil {
+			return fmt.Errorf("can't open output file '%s': %v", outputPath, err)
+		}
+		defer output.Close()
+
+		if _, err := fmt.Fprint(output, content); err != nil {
+			return fmt.Errorf("can't write output file '%s': %v", output.Path(), err)
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	// The checksum follows the outputs, so it doesn't tell whether they are up to date
+	var checksumOutput *file.Output
+	if cfg.ChecksumOut != "" {
+		checksum := file.Checksum(checksummed) + "\n"
+
+		unchanged := false
+		if cfg.IfChanged && cfg.ChecksumOut != "-" {
+			unchanged, err = file.HasContent(cfg.ChecksumOut, checksum)
+			if err != nil {
+				return fmt.Errorf("can't read checksum file '%s': %v", cfg.ChecksumOut, err)
+			}
+		}
+
+		if cfg.DryRun && cfg.ChecksumOut != "-" {
+			cfg.notef("would write %d bytes to '%s'", len(checksum), cfg.ChecksumOut)
+		} else if unchanged {
+			cfg.logf("checksum file '%s' unchanged", cfg.ChecksumOut)
+		} else {
+			checksumOutput, err = file.OpenOutput(cfg.ChecksumOut, outputOptions)
+			if err != nil {
+				return fmt.Errorf("can't open checksum file '%s': %v", cfg.ChecksumOut, err)
+			}
+			defer checksumOutput.Close()
+
+			if _, err := fmt.Fprint(checksumOutput, checksum); err != nil {
+				return fmt.Errorf("can't write checksum file '%s': %v", checksumOutput.Path(), err)
+			}
+
+			outputs = append(outputs, checksumOutput)
+		}
+	}
+
+	if err := file.CommitAll(outputs); err != nil {
+		return err
+	}
+
+	// STDOUT is always written, so only the files tell whether the outputs are up to date
+	writtenFiles := 0
+	for _, output := range outputs {
+		cfg.logf("wrote output '%s'", output.Path())
+
+		if output.Path() != "-" && output != checksumOutput {
+			writtenFiles++
+
+			if cfg.IfChanged {
+				cfg.notef("'%s' updated", output.Path())
+			}
+		}
+	}
+
+	if cfg.IfChanged && !cfg.DryRun && writtenFiles == 0 {
+		return errUnchanged
+	}
+
+	return nil
+}
+
+// canStream returns whether the flags allow writing the content to the outputs as it's generated,
+// without processing it once generated
+func canStream(cfg config, guards map[string][]string) bool {
+	if cfg.Multi != "" || cfg.SplitDir != "" || len(guards) > 0 || cfg.IfChanged || cfg.DryRun || cfg.Diff != "" {
+		return false
+	}
+
+	if cfg.Header.Enabled || cfg.FailOnEmpty.Enabled || cfg.ChecksumOut != "" || cfg.Wrap != "" || cfg.WarnUnused || cfg.ErrorUnused {
+		return false
+	}
+
+	if cfg.Compress != string(file.CompressionNone) {
+		return false
+	}
+
+	for _, out := range cfg.Outs {
+		if _, outputFormat := splitOutput(out); outputFormat != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stream writes the content to all the outputs as the template is evaluated, the files being
+// committed once the content is complete
+func stream(runtime cfgenerator.Interpreter, input io.Reader, cfg config, opts cfgenerator.Options, outputOptions file.OutputOptions) error {
+	outputs := make([]*file.Output, 0, len(cfg.Outs))
+	writers := make([]io.Writer, 0, len(cfg.Outs))
+	for _, outputPath := range cfg.Outs {
+		output, err := file.OpenOutput(outputPath, outputOptions)
+		if err != nil {
+			return fmt.Errorf("can't open output file '%s': %v", outputPath, err)
+		}
+		defer output.Close()
+
+		outputs = append(outputs, output)
+		writers = append(writers, output)
+	}
+
+	cfg.logf("streaming the content to %d outputs", len(outputs))
+
+	if _, err := cfgenerator.GenerateTo(runtime, input, cfg.Volumes, io.MultiWriter(writers...), opts); err != nil {
+		if cfgenerator.IsValidationError(err) {
+			return exitError{code: exitInvalid, err: fmt.Errorf("can't generate content: %v", err)}
+		}
+
+		return fmt.Errorf("can't generate content: %v", err)
+	}
+
+	if err := file.CommitAll(outputs); err != nil {
+		return err
+	}
+
+	for _, output := range outputs {
+		cfg.logf("wrote output '%s'", output.Path())
+	}
+
+	return nil
+}
+
+// diffContent compares the generated content to the -diff file, reporting their unified diff when
+// they differ
+func diffContent(cfg config, content string) error {
+	existing, err := ioutil.ReadFile(cfg.Diff)
+	if err != nil {
+		return fmt.Errorf("can't read diff file '%s': %v", cfg.Diff, err)
+	}
+
+	if string(existing) == content {
+		cfg.logf("'%s' matches the generated content", cfg.Diff)
+		return nil
+	}
+
+	cfg.notef("%s", strings.TrimSuffix(diff.Unified(cfg.Diff, "generated", string(existing), content), "\n"))
+
+	return errDiffers
+}
+
+func parseVolumeOptions(cfg config) (volume.Options, error) {
+	if cfg.Separator == "" {
+		return volume.Options{}, fmt.Errorf("separator can't be empty")
+	}
+
+	trim, err := volume.ParseTrim(cfg.Trim)
+	if err != nil {
+		return volume.Options{}, err
+	}
+
+	if cfg.MaxFileSize < 0 {
+		return volume.Options{}, fmt.Errorf("invalid max file size '%d': expected a positive number of bytes", cfg.MaxFileSize)
+	}
+
+	if cfg.Workers < 0 {
+		return volume.Options{}, fmt.Errorf("invalid workers '%d': expected a positive number", cfg.Workers)
+	}
+
+	binary, err := volume.ParseBinary(cfg.Binary)
+	if err != nil {
+		return volume.Options{}, err
+	}
+
+	nameTransform, err := volume.ParseNameTransform(cfg.NameTransform)
+	if err != nil {
+		return volume.Options{}, err
+	}
+
+	parse, err := volume.ParseFormat(cfg.Parse)
+	if err != nil {
+		return volume.Options{}, err
+	}
+
+	encoding, err := volume.ParseEncoding(cfg.Encoding)
+	if err != nil {
+		return volume.Options{}, err
+	}
+
+	opts := volume.Options{
+		Recursive:     cfg.Recursive,
+		Separator:     cfg.Separator,
+		Hidden:        cfg.Hidden,
+		Trim:          trim,
+		Binary:        binary,
+		Workers:       cfg.Workers,
+		MaxFileSize:   cfg.MaxFileSize,
+		NameTransform: nameTransform,
+		StripExt:      cfg.StripExt,
+		Parse:         parse,
+		Encoding:      encoding,
+		IncludeFiles:  cfg.IncludeFiles,
+		ExcludeFiles:  cfg.ExcludeFiles,
+	}
+
+	if err := opts.ValidateFilePatterns(); err != nil {
+		return volume.Options{}, err
+	}
+
+	return opts, nil
+}
+
+func loadArchive(archivePath string, opts volume.Options) (volume.Archive, error) {
+	input, err := file.OpenInput(archivePath)
+	if err != nil {
+		return volume.Archive{}, fmt.Errorf("can't open input archive '%s': %v", archivePath, err)
+	}
+	defer input.Close()
+
+	archive, err := volume.LoadArchive(input, opts)
+	if err != nil {
+		return volume.Archive{}, fmt.Errorf("can't load input archive '%s': %v", archivePath, err)
+	}
+
+	return archive, nil
+}
+
+// wrapOptions returns the metadata of the -wrap manifest
+func wrapOptions(cfg config) manifest.Options {
+	return manifest.Options{Name: cfg.WrapName, Namespace: cfg.WrapNamespace, Key: cfg.WrapKey}
+}
+
+// checkSyntax parses the templates with the interpreter, without evaluating them
+func checkSyntax(runtime cfgenerator.Interpreter, cfg config, archive volume.Archive) error {
+	checker, ok := runtime.(cfgenerator.SyntaxInterpreter)
+	if !ok {
+		return exitError{code: exitUsage, err: fmt.Errorf("-check-syntax isn't supported by the interpreter")}
+	}
+
+	var names, templates []string
+	if cfg.InArchive != "" {
+		names = append(names, archive.TemplateName)
+		templates = append(templates, archive.Template)
+	}
+
+	for _, inputPath := range cfg.Ins {
+		cfg.logf("reading template '%s'", inputPath)
+
+		input, err := openInput(inputPath, cfg)
+		if err != nil {
+			return fmt.Errorf("can't open input file '%s': %v", inputPath, err)
+		}
+
+		content, err := ioutil.ReadAll(input)
+		input.Close()
+		if err != nil {
+			return fmt.Errorf("can't read input file '%s': %v", inputPath, err)
+		}
+
+		names = append(names, inputPath)
+		templates = append(templates, string(content))
+	}
+
+	if !cfg.Merge {
+		names = []string{strings.Join(names, "', '")}
+		templates = []string{strings.Join(templates, "")}
+	}
+
+	for i, tpl := range templates {
+		if err := checker.CheckSyntax(tpl); err != nil {
+			return fmt.Errorf("invalid syntax in '%s': %v", names[i], err)
+		}
+
+		cfg.logf("syntax of '%s' is valid", names[i])
+	}
+
+	return nil
+}
+
+// loadVault reads the variables of the -vault secrets, using the server and the token of the
+// environment
+func loadVault(cfg config) ([]variable.Variable, error) {
+	client, err := vault.NewClientFromEnv(os.Environ(), cfg.RemoteTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to Vault: %v", err)
+	}
+
+	var variables []variable.Variable
+	for _, value := range cfg.VaultPaths {
+		secretPath := strings.TrimPrefix(value, "path=")
+		if secretPath == value {
+			return nil, fmt.Errorf("invalid Vault secret '%s': expected path=<secret-path>", value)
+		}
+
+		cfg.logf("reading Vault secret '%s'", secretPath)
+
+		secretVariables, err := client.Read(secretPath)
+		if err != nil {
+			return nil, fmt.Errorf("can't read Vault secret: %v", err)
+		}
+
+		variables = append(variables, secretVariables...)
+	}
+
+	return variables, nil
+}
+
+// dumpVars prints on STDOUT the variables the interpreter would receive as a JSON object
+func dumpVars(runtime cfgenerator.Interpreter, cfg config, opts cfgenerator.Options) error {
+	variables, err := internal.LoadVariables(runtime, cfg.Volumes, opts)
+	if err != nil {
+		return fmt.Errorf("can't load variables: %v", err)
+	}
+
+	values := make(map[string]string, len(variables))
+	for _, v := range variables {
+		values[v.Name] = v.Value
+		if cfg.DumpVars.Redacted && v.Value != "" {
+			values[v.Name] = internal.RedactedValue
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "   ")
+
+	if err := encoder.Encode(values); err != nil {
+		return fmt.Errorf("can't write variables: %v", err)
+	}
+
+	return nil
+}
+
+// listOutputs prints the paths of the files written by the invocation, evaluating the template only
+// when their names depend on the content
+func listOutputs(runtime cfgenerator.Interpreter, input io.Reader, cfg config, opts cfgenerator.Options, guards map[string][]string) error {
+	var paths []string
+
+	switch {
+	case cfg.Multi != "" || cfg.SplitDir != "":
+		files, err := generate(runtime, input, cfg, opts, guards)
+		if err != nil {
+			return fmt.Errorf("can't generate content: %v", err)
+		}
+
+		for _, generated := range files {
+			paths = append(paths, generated.path)
+		}
+	case len(guards) > 0:
+		variables, err := internal.LoadVariables(runtime, cfg.Volumes, opts)
+		if err != nil {
+			return fmt.Errorf("can't load variables: %v", err)
+		}
+
+		values := make(map[string]string, len(variables))
+		for _, v := range variables {
+			values[v.Name] = v.Value
+		}
+
+		for _, out := range cfg.Outs {
+			outputPath, _ := splitOutput(out)
+			if name, skipped := unmetGuard(guards[outputPath], values); skipped {
+				cfg.logf("skipping output '%s': variable '%s' is missing or empty", outputPath, name)
+				continue
+			}
+
+			paths = append(paths, outputPath)
+		}
+	default:
+		for _, out := range cfg.Outs {
+			outputPath, _ := splitOutput(out)
+			paths = append(paths, outputPath)
+		}
+	}
+
+	if cfg.ChecksumOut != "" {
+		paths = append(paths, cfg.ChecksumOut)
+	}
+
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+
+	return nil
+}
+
+type generatedFile struct {
+	path    string
+	content string
+	// format is the format of the content, the one of its extension for the files of -multi and
+	// -split-dir
+	format format.Format
+}
+
+func generate(runtime cfgenerator.Interpreter, input io.Reader, cfg config, opts cfgenerator.Options, guards map[string][]string) ([]generatedFile, error) {
+	if cfg.SplitDir != "" {
+		contents, err := cfgenerator.GenerateSplit(runtime, input, cfg.Volumes, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return folderFiles(cfg.SplitDir, contents), nil
+	}
+
+	if cfg.Multi == "" {
+		// the content is encoded for each output when their formats can differ
+		perOutput := opts.Format == format.Auto
+		for _, out := range cfg.Outs {
+			if _, outputFormat := splitOutput(out); outputFormat != "" {
+				perOutput = true
+			}
+		}
+
+		generateOpts := opts
+		if perOutput {
+			generateOpts.Format = format.Auto
+		}
+
+		result, err := cfgenerator.GenerateResult(runtime, input, cfg.Volumes, generateOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkUnused(cfg, result.UnusedVars); err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]string, len(result.Variables))
+		for _, v := range result.Variables {
+			values[v.Name] = v.Value
+		}
+
+		files := make([]generatedFile, 0, len(cfg.Outs))
+		for _, out := range cfg.Outs {
+			outputPath, outputFormat := splitOutput(out)
+
+			if name, skipped := unmetGuard(guards[outputPath], values); skipped {
+				cfg.logf("skipping output '%s': variable '%s' is missing or empty", outputPath, name)
+				continue
+			}
+
+			content, contentFormat := result.Content, format.Format(result.Format)
+			if perOutput && !isTextInterpreter(runtime) {
+				if outputFormat == "" {
+					outputFormat = opts.Format
+				}
+
+				if outputFormat == format.Auto {
+					outputFormat = format.ForPath(outputPath)
+				}
+
+				content, err = format.Convert(content, outputFormat, opts.FormatOptions)
+				if err != nil {
+					return nil, fmt.Errorf("can't format content of '%s': %v", outputPath, err)
+				}
+
+				contentFormat = outputFormat
+			}
+
+			if cfg.Wrap != "" {
+				content, err = manifest.Wrap(content, manifest.Kind(cfg.Wrap), wrapOptions(cfg))
+				if err != nil {
+					return nil, err
+				}
+
+				contentFormat = format.YAML
+			}
+
+			files = append(files, generatedFile{path: outputPath, content: content, format: contentFormat})
+		}
+
+		return files, nil
+	}
+
+	contents, err := cfgenerator.GenerateMulti(runtime, input, cfg.Volumes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return folderFiles(cfg.Multi, contents), nil
+}
+
+// parseOutputGuards returns the names of the variables guarding each output path of -out-if
+func parseOutputGuards(cfg config) (map[string][]string, error) {
+	if len(cfg.OutIfs) > 0 && (cfg.Multi != "" || cfg.SplitDir != "") {
+		return nil, exitError{code: exitUsage, err: fmt.Errorf("-out-if can't be used with -multi or -split-dir")}
+	}
+
+	outputPaths := make(map[string]bool, len(cfg.Outs))
+	for _, out := range cfg.Outs {
+		outputPath, _ := splitOutput(out)
+		outputPaths[outputPath] = true
+	}
+
+	guards := make(map[string][]string, len(cfg.OutIfs))
+	for _, value := range cfg.OutIfs {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid -out-if '%s': expected NAME=FILE", value)
+		}
+
+		if !outputPaths[parts[1]] {
+			return nil, fmt.Errorf("invalid -out-if '%s': '%s' isn't an -out path", value, parts[1])
+		}
+
+		guards[parts[1]] = append(guards[parts[1]], parts[0])
+	}
+
+	return guards, nil
+}
+
+// unmetGuard returns the first guard variable which is missing or empty, and whether there's one
+func unmetGuard(names []string, values map[string]string) (string, bool) {
+	for _, name := range names {
+		if values[name] == "" {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// splitOutput returns the path of an -out value and the format qualifying it, e.g. 'yaml' for
+// 'yaml:config.yaml', or an empty format when the value is a bare path
+func splitOutput(out string) (string, format.Format) {
+	parts := strings.SplitN(out, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return out, ""
+	}
+
+	outputFormat, err := format.Parse(parts[0])
+	if err != nil {
+		return out, ""
+	}
+
+	return parts[1], outputFormat
+}
+
+// folderFiles returns the files to write in the folder, sorted by name
+func folderFiles(folder string, contents map[string]string) []generatedFile {
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]generatedFile, 0, len(names))
+	for _, name := range names {
+		files = append(files, generatedFile{path: filepath.Join(folder, filepath.FromSlash(name)), content: contents[name], format: format.ForPath(name)})
+	}
+
+	return files
+}
+
+// checkUnused reports the variables the template doesn't use as requested by -warn-unused and
+// -error-unused
+func checkUnused(cfg config, unused []string) error {
+	if len(unused) == 0 || !(cfg.WarnUnused || cfg.ErrorUnused) {
+		return nil
+	}
+
+	names := make([]string, 0, len(unused))
+	for _, name := range unused {
+		names = append(names, fmt.Sprintf("'%s'", name))
+	}
+
+	if cfg.ErrorUnused {
+		return cfgenerator.ValidationError{Err: fmt.Errorf("unused variables: %s", strings.Join(names, ", "))}
+	}
+
+	cfg.notef("unused variables: %s", strings.Join(names, ", "))
+
+	return nil
+}
+
+// isTemplateFile returns whether the path is a regular file whose extension is associated to an
+// interpreter, for -positional-in
+func isTemplateFile(path string) bool {
+	if _, err := interpreter.Detect(path); err != nil {
+		return false
+	}
+
+	stat, err := os.Stat(path)
+
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// openInput opens the template, downloading it when the path is an HTTP or HTTPS URL
+func openInput(path string, cfg config) (io.ReadCloser, error) {
+	if file.IsRemote(path) {
+		return file.OpenRemote(path, cfg.RemoteTimeout)
+	}
+
+	return file.OpenInput(path)
+}
+
+// isTextInterpreter returns whether the interpreter reads plain text, so several templates can be
+// concatenated
+func isTextInterpreter(runtime cfgenerator.Interpreter) bool {
+	text, ok := runtime.(cfgenerator.TextInterpreter)
+
+	return ok && text.IsText()
+}
+
+// plainOptions returns the options of the plain and html interpreters
+func plainOptions(cfg config) (interpreter.PlainOptions, error) {
+	sprig, err := interpreter.ParseSprig(cfg.Sprig)
+	if err != nil {
+		return interpreter.PlainOptions{}, err
+	}
+
+	if (cfg.DelimLeft == "") != (cfg.DelimRight == "") {
+		return interpreter.PlainOptions{}, exitError{code: exitUsage, err: fmt.Errorf("-delim-left and -delim-right must be set together and can't be empty")}
+	}
+
+	return interpreter.PlainOptions{
+		Sprig:      sprig,
+		LeftDelim:  cfg.DelimLeft,
+		RightDelim: cfg.DelimRight,
+		Strict:     cfg.Strict,
+		Includes:   cfg.Includes,
+	}, nil
+}
+
+func configure(runtime cfgenerator.Interpreter, cfg config) error {
+	switch runtime := runtime.(type) {
+	case *interpreter.Plain:
+		options, err := plainOptions(cfg)
+		if err != nil {
+			return err
+		}
+
+		runtime.Configure(options)
+	case *interpreter.HTML:
+		options, err := plainOptions(cfg)
+		if err != nil {
+			return err
+		}
+
+		runtime.Configure(options)
+	case *interpreter.Envsubst:
+		runtime.Configure(interpreter.EnvsubstOptions{Strict: cfg.Strict})
+	case *interpreter.Jsonnet:
+		jpaths := append([]string{}, cfg.JPaths...)
+		if len(cfg.Ins) > 0 && cfg.Ins[0] != "-" && !file.IsRemote(cfg.Ins[0]) {
+			jpaths = append(jpaths, filepath.Dir(cfg.Ins[0]))
+		}
+
+		tlaVars, err := parseAssignments("tla-str", cfg.TLAVars)
+		if err != nil {
+			return err
+		}
+
+		tlaCodes, err := parseAssignments("tla-code", cfg.TLACodes)
+		if err != nil {
+			return err
+		}
+
+		if cfg.BundleOnly && cfg.BundleExtVar == "" {
+			return exitError{code: exitUsage, err: fmt.Errorf("-bundle-only requires -bundle-extvar")}
+		}
+
+		runtime.Configure(interpreter.JsonnetOptions{
+			JPaths:       jpaths,
+			TLAVars:      tlaVars,
+			TLACodes:     tlaCodes,
+			BundleExtVar: cfg.BundleExtVar,
+			BundleOnly:   cfg.BundleOnly,
+			LazyVolumes:  cfg.LazyVolumes,
+			Trace:        cfg.Trace,
+		})
+	}
+
+	if _, isJsonnet := runtime.(*interpreter.Jsonnet); !isJsonnet && len(cfg.LazyVolumes) > 0 {
+		return exitError{code: exitUsage, err: fmt.Errorf("-lazy-volume can only be used with the jsonnet interpreter")}
+	}
+
+	if _, isJsonnet := runtime.(*interpreter.Jsonnet); !isJsonnet && cfg.Trace {
+		return exitError{code: exitUsage, err: fmt.Errorf("-trace can only be used with the jsonnet interpreter")}
+	}
+
+	return nil
+}
+
+// applyOptions routes the -opt flags to the interpreter
+func applyOptions(runtime cfgenerator.Interpreter, interpreterName string, cfg config) error {
+	if len(cfg.Opts) == 0 {
+		return nil
+	}
+
+	options := make(map[string]string)
+	for _, opt := range cfg.Opts {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid -opt '%s': expected KEY=VALUE", opt)
+		}
+
+		if _, found := options[parts[0]]; found {
+			return fmt.Errorf("invalid -opt '%s': option '%s' is already set", opt, parts[0])
+		}
+
+		options[parts[0]] = parts[1]
+	}
+
+	optionsRuntime, ok := runtime.(cfgenerator.OptionsInterpreter)
+	if !ok {
+		return fmt.Errorf("the '%s' interpreter doesn't accept any -opt", interpreterName)
+	}
+
+	if err := optionsRuntime.SetOptions(options); err != nil {
+		return fmt.Errorf("invalid -opt for the '%s' interpreter: %v", interpreterName, err)
+	}
+
+	return nil
+}
+
+// parsePrefixes maps the volume paths of the -prefix flags to their prefix
+func parsePrefixes(values []string) (map[string]string, error) {
+	prefixes := make(map[string]string, len(values))
+
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid -prefix '%s': expected PREFIX=VOLUME-PATH", value)
+		}
+
+		if existing, found := prefixes[parts[1]]; found {
+			return nil, fmt.Errorf("invalid -prefix '%s': the volume path is already prefixed with '%s'", value, existing)
+		}
+
+		prefixes[parts[1]] = parts[0]
+	}
+
+	return prefixes, nil
+}
+
+// parseOverrides builds the variables of the -set and -set-code flags, in order
+func parseOverrides(cfg config) ([]variable.Variable, error) {
+	var overrides []variable.Variable
+	codes := make(map[string]bool)
+
+	for _, flags := range []struct {
+		name   string
+		values []string
+		code   bool
+	}{
+		{name: "set", values: cfg.Sets},
+		{name: "set-code", values: cfg.SetCodes, code: true},
+	} {
+		for _, value := range flags.values {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid -%s '%s': expected NAME=VALUE", flags.name, value)
+			}
+
+			if code, found := codes[parts[0]]; found && code != flags.code {
+				return nil, fmt.Errorf("variable '%s' can't be set by both -set and -set-code", parts[0])
+			}
+
+			codes[parts[0]] = flags.code
+			overrides = append(overrides, variable.Variable{Name: parts[0], Value: parts[1], Source: "-" + flags.name, Code: flags.code})
+		}
+	}
+
+	return overrides, nil
+}
+
+func parseAssignments(flagName string, values []string) (map[string]string, error) {
+	assignments := make(map[string]string, len(values))
+
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid -%s '%s': expected NAME=VALUE", flagName, value)
+		}
+
+		assignments[parts[0]] = parts[1]
+	}
+
+	return assignments, nil
+}
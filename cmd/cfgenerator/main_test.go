@@ -0,0 +1,797 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+	"github.com/fewlinesco/k8s-cfgenerator/pkg/cfgenerator"
+)
+
+// runMainEnv is set when the test binary is started by runCommand to run the command itself
+const runMainEnv = "CFGENERATOR_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(exitOK)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command in a subprocess and returns its exit code, STDOUT and STDERR
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), stdout.String(), stderr.String()
+	}
+
+	if err != nil {
+		t.Fatalf("can't run command: %v", err)
+	}
+
+	return exitOK, stdout.String(), stderr.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	root := t.TempDir()
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	template := `{ port: std.extVar('API_PORT') }`
+
+	schema := filepath.Join(root, "schema.json")
+	if err := ioutil.WriteFile(schema, []byte(`{"required": ["host"]}`), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	output := filepath.Join(root, "config.json")
+
+	tcs := []struct {
+		Name           string
+		Args           []string
+		Template       string
+		ExpectedCode   int
+		ExpectedStderr string
+	}{
+		{
+			Name:         "success",
+			Args:         []string{"-out", output, volume},
+			Template:     template,
+			ExpectedCode: exitOK,
+		},
+		{
+			Name:           "unchanged",
+			Args:           []string{"-if-changed", "-out", output, volume},
+			Template:       template,
+			ExpectedCode:   exitUnchanged,
+			ExpectedStderr: "'" + output + "' unchanged\n",
+		},
+		{
+			Name:         "unchanged quiet",
+			Args:         []string{"-quiet", "-if-changed", "-out", output, volume},
+			Template:     template,
+			ExpectedCode: exitUnchanged,
+		},
+		{
+			Name:           "changed",
+			Args:           []string{"-if-changed", "-out", output, volume},
+			Template:       `{ port: std.parseInt(std.extVar('API_PORT')) }`,
+			ExpectedCode:   exitOK,
+			ExpectedStderr: "'" + output + "' updated\n",
+		},
+		{
+			Name:           "unchanged with stdout",
+			Args:           []string{"-if-changed", "-out=-", "-out", output, volume},
+			Template:       `{ port: std.parseInt(std.extVar('API_PORT')) }`,
+			ExpectedCode:   exitUnchanged,
+			ExpectedStderr: "'" + output + "' unchanged\n",
+		},
+		{
+			Name:           "required",
+			Args:           []string{"-require", "DATABASE_PASSWORD", volume},
+			Template:       template,
+			ExpectedCode:   exitInvalid,
+			ExpectedStderr: "can't generate content: missing required variables: 'DATABASE_PASSWORD'\n",
+		},
+		{
+			Name:         "schema",
+			Args:         []string{"-schema", schema, volume},
+			Template:     template,
+			ExpectedCode: exitInvalid,
+		},
+		{
+			Name:           "error unused",
+			Args:           []string{"-error-unused", volume},
+			Template:       template,
+			ExpectedCode:   exitInvalid,
+			ExpectedStderr: "can't generate content: unused variables: 'DATABASE_USERNAME'\n",
+		},
+		{
+			Name:         "warn unused quiet",
+			Args:         []string{"-warn-unused", "-q", volume},
+			Template:     template,
+			ExpectedCode: exitOK,
+		},
+		{
+			Name:         "evaluation error",
+			Args:         []string{volume},
+			Template:     `{ port: std.extVar('MISSING') }`,
+			ExpectedCode: exitFailed,
+		},
+		{
+			Name:         "invalid flag",
+			Args:         []string{"-unknown-flag"},
+			ExpectedCode: exitUsage,
+		},
+		{
+			Name:           "quiet and verbose",
+			Args:           []string{"-quiet", "-verbose", volume},
+			Template:       template,
+			ExpectedCode:   exitUsage,
+			ExpectedStderr: "-quiet and -verbose can't be used together\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			code, _, stderr := runCommand(t, tc.Template, tc.Args...)
+			if tc.ExpectedCode != code {
+				t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\nstderr:\n%s\n", tc.ExpectedCode, code, stderr)
+			}
+
+			if tc.ExpectedStderr != "" && tc.ExpectedStderr != stderr {
+				t.Fatalf("invalid stderr\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedStderr, stderr)
+			}
+
+			if tc.ExpectedStderr == "" && code != exitFailed && code != exitUsage && code != exitInvalid && stderr != "" {
+				t.Fatalf("unexpected stderr:\n'%s'\n", stderr)
+			}
+		})
+	}
+}
+
+func TestChecksumIfChanged(t *testing.T) {
+	root := t.TempDir()
+	output, checksum := filepath.Join(root, "config.json"), filepath.Join(root, "checksum")
+	args := []string{"-if-changed", "-checksum-out=" + checksum, "-out=" + output, filepath.Join("examples", "plain", "volumes", "config")}
+	template := `{ port: std.extVar('API_PORT') }`
+
+	if code, _, stderr := runCommand(t, template, args...); code != exitOK {
+		t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\nstderr:\n%s\n", exitOK, code, stderr)
+	}
+
+	code, _, stderr := runCommand(t, template, args...)
+	if code != exitUnchanged {
+		t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\nstderr:\n%s\n", exitUnchanged, code, stderr)
+	}
+
+	if expected := "'" + output + "' unchanged\n"; stderr != expected {
+		t.Fatalf("invalid stderr\nexpected:\n'%s'\nactual:\n'%s'\n", expected, stderr)
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	missingVolume := filepath.Join(t.TempDir(), "missing")
+
+	code, stdout, stderr := runCommand(t, `{ port: std.extVar('API_PORT') }`, "-check-syntax", missingVolume)
+	if code != exitOK || stdout != "" || stderr != "" {
+		t.Fatalf("invalid result\nexit code: %d\nstdout:\n'%s'\nstderr:\n'%s'\n", code, stdout, stderr)
+	}
+
+	code, _, stderr = runCommand(t, "{\n  port: ,\n}", "-check-syntax", missingVolume)
+	if expected := "invalid syntax in '-': can't parse jsonnet template: 2:9-10 Unexpected: (\",\", \",\") while parsing terminal\n"; code != exitFailed || expected != stderr {
+		t.Fatalf("invalid result\nexpected:\n'%s'\nactual:\n'%s'\nexit code: %d\n", expected, stderr, code)
+	}
+}
+
+func TestVolumeList(t *testing.T) {
+	override := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(override, "API_PORT"), []byte("8080"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	volumes := strings.Join([]string{"", override, ""}, string(os.PathListSeparator))
+	template := `{ port: std.extVar('API_PORT'), user: std.extVar('DATABASE_USERNAME') }`
+
+	code, stdout, stderr := runCommand(t, template, "-on-conflict=last", "-volumes", volumes, filepath.Join("examples", "plain", "volumes", "config"))
+	if expected := "{\n   \"port\": \"8080\",\n   \"user\": \"myapp\"\n}\n"; code != exitOK || expected != stdout {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\nstderr:\n%s\n", expected, stdout, stderr)
+	}
+}
+
+func TestPositionalIn(t *testing.T) {
+	root := t.TempDir()
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+
+	template := filepath.Join(root, "config.jsonnet")
+	if err := ioutil.WriteFile(template, []byte(`{ port: std.extVar('API_PORT') }`), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name     string
+		Args     []string
+		Stdin    string
+		Expected string
+	}{
+		{
+			Name:     "template argument",
+			Args:     []string{"-positional-in", template, volume},
+			Expected: "{\n   \"port\": \"1337\"\n}\n",
+		},
+		{
+			Name:     "volume argument",
+			Args:     []string{"-positional-in", volume},
+			Stdin:    `{ user: std.extVar('DATABASE_USERNAME') }`,
+			Expected: "{\n   \"user\": \"myapp\"\n}\n",
+		},
+		{
+			Name:     "in flag",
+			Args:     []string{"-positional-in", "-in", "-", filepath.Join(volume, "DATABASE_USERNAME")},
+			Stdin:    `{ user: std.extVar('DATABASE_USERNAME') }`,
+			Expected: "{\n   \"user\": \"myapp\"\n}\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, tc.Stdin, tc.Args...)
+			if code != exitOK || tc.Expected != stdout {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\nstderr:\n%s\n", tc.Expected, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+
+	code, stdout, stderr := runCommand(t, `{ port: std.extVar('API_PORT') }`, "-log-format=json", "-verbose", "-require=MISSING", volume)
+	if code != exitInvalid || stdout != "" {
+		t.Fatalf("invalid result\nexit code: %d\nstdout:\n'%s'\nstderr:\n'%s'\n", code, stdout, stderr)
+	}
+
+	var levels []string
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var entry struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON line '%s': %v", line, err)
+		}
+
+		if entry.Time == "" || entry.Msg == "" {
+			t.Fatalf("incomplete log line: '%s'", line)
+		}
+
+		levels = append(levels, entry.Level)
+	}
+
+	if len(levels) < 2 || levels[0] != "debug" || levels[len(levels)-1] != "error" {
+		t.Fatalf("invalid levels: %v\nstderr:\n%s\n", levels, stderr)
+	}
+
+	if strings.Contains(stderr, "1337") {
+		t.Fatalf("the logs leak a variable value:\n%s\n", stderr)
+	}
+}
+
+func TestOpt(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+
+	code, stdout, stderr := runCommand(t, "[[ .API_PORT ]]", "-interpreter=plain", "-opt=delim-left=[[", "-opt=delim-right=]]", volume)
+	if expected := "1337"; code != exitOK || expected != stdout {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\nstderr:\n%s\n", expected, stdout, stderr)
+	}
+
+	code, _, stderr = runCommand(t, "{}", "-interpreter=cue", "-opt=strict=true", volume)
+	if expected := "the 'cue' interpreter doesn't accept any -opt\n"; code == exitOK || expected != stderr {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, stderr)
+	}
+}
+
+func TestFailOnEmpty(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	out := filepath.Join(t.TempDir(), "config.txt")
+
+	tcs := []struct {
+		Name           string
+		Interpreter    string
+		Template       string
+		Flag           string
+		Format         string
+		ExpectedCode   int
+		ExpectedStderr string
+	}{
+		{Name: "empty", Template: `{{ "" }}`, Flag: "-fail-on-empty", ExpectedCode: exitInvalid, ExpectedStderr: fmt.Sprintf("can't generate content: the content of '%s' is empty\n", out)},
+		{Name: "white spaces", Template: " \n", Flag: "-fail-on-empty", ExpectedCode: exitOK},
+		{Name: "blank", Template: " \n", Flag: "-fail-on-empty=blank", ExpectedCode: exitInvalid, ExpectedStderr: fmt.Sprintf("can't generate content: the content of '%s' is empty\n", out)},
+		{Name: "content", Template: "{{ .API_PORT }}", Flag: "-fail-on-empty=blank", ExpectedCode: exitOK},
+		{Name: "text null", Template: "null", Flag: "-fail-on-empty", ExpectedCode: exitOK},
+		{Name: "jsonnet empty string", Interpreter: "jsonnet", Template: `""`, Flag: "-fail-on-empty", ExpectedCode: exitInvalid, ExpectedStderr: fmt.Sprintf("can't generate content: the content of '%s' is empty\n", out)},
+		{Name: "jsonnet null", Interpreter: "jsonnet", Template: "null", Flag: "-fail-on-empty=blank", ExpectedCode: exitInvalid, ExpectedStderr: fmt.Sprintf("can't generate content: the content of '%s' is empty\n", out)},
+		{Name: "jsonnet null yaml", Interpreter: "jsonnet", Template: "null", Flag: "-fail-on-empty", Format: "yaml", ExpectedCode: exitInvalid, ExpectedStderr: fmt.Sprintf("can't generate content: the content of '%s' is empty\n", out)},
+		{Name: "jsonnet object", Interpreter: "jsonnet", Template: "{}", Flag: "-fail-on-empty", ExpectedCode: exitOK},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Remove(out)
+
+			interpreterName, outputFormat := tc.Interpreter, tc.Format
+			if interpreterName == "" {
+				interpreterName = "plain"
+			}
+
+			if outputFormat == "" {
+				outputFormat = "json"
+			}
+
+			code, _, stderr := runCommand(t, tc.Template, "-interpreter="+interpreterName, "-format="+outputFormat, tc.Flag, "-out="+out, volume)
+			if code != tc.ExpectedCode || stderr != tc.ExpectedStderr {
+				t.Fatalf("invalid result\nexpected:\n%d '%s'\nactual:\n%d '%s'\n", tc.ExpectedCode, tc.ExpectedStderr, code, stderr)
+			}
+
+			if _, err := os.Stat(out); (err == nil) != (tc.ExpectedCode == exitOK) {
+				t.Fatalf("invalid output file: %v", err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	rendered := filepath.Join(t.TempDir(), "config.txt")
+
+	if err := ioutil.WriteFile(rendered, []byte("port: 1337\nuser: myapp\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name           string
+		Template       string
+		Flags          []string
+		ExpectedCode   int
+		ExpectedStderr string
+	}{
+		{Name: "matching", Template: "port: {{ .API_PORT }}\nuser: {{ .DATABASE_USERNAME }}\n", ExpectedCode: exitOK},
+		{
+			Name:           "differing",
+			Template:       "port: {{ .API_PORT }}\nuser: admin\n",
+			ExpectedCode:   exitDiffers,
+			ExpectedStderr: fmt.Sprintf("--- %s\n+++ generated\n@@ -1,2 +1,2 @@\n port: 1337\n-user: myapp\n+user: admin\n", rendered),
+		},
+		{Name: "quiet", Template: "port: 80\n", Flags: []string{"-quiet"}, ExpectedCode: exitDiffers},
+		{Name: "several outputs", Template: "port: 80\n", Flags: []string{"-out=a", "-out=b"}, ExpectedCode: exitUsage, ExpectedStderr: "-diff can't be used with several -out\n"},
+		{Name: "guarded output", Template: "port: 80\n", Flags: []string{"-out-if=MISSING=out.txt", "-out=out.txt"}, ExpectedCode: exitUsage, ExpectedStderr: "-diff can't be used with -out-if\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			args := append([]string{"-interpreter=plain", "-diff=" + rendered}, tc.Flags...)
+
+			code, stdout, stderr := runCommand(t, tc.Template, append(args, volume)...)
+			if code != tc.ExpectedCode || stderr != tc.ExpectedStderr {
+				t.Fatalf("invalid result\nexpected:\n%d '%s'\nactual:\n%d '%s'\n", tc.ExpectedCode, tc.ExpectedStderr, code, stderr)
+			}
+
+			if stdout != "" {
+				t.Fatalf("invalid output\nexpected:\n''\nactual:\n'%s'\n", stdout)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+
+	tcs := []struct {
+		Name           string
+		Flags          []string
+		ExpectedCode   int
+		ExpectedStdout string
+		ExpectedStderr string
+	}{
+		{Name: "yaml", Flags: []string{"-header", "-format=yaml"}, ExpectedStdout: "# Generated by cfgenerator, do not edit\nport: 1337\n"},
+		{Name: "text", Flags: []string{"-header=Rendered from config.tpl", "-format=toml", "-header-style=slash"}, ExpectedStdout: "// Rendered from config.tpl\nport = 1337\n"},
+		{Name: "json", Flags: []string{"-header"}, ExpectedStdout: "{\"port\": 1337}\n"},
+		{Name: "json key", Flags: []string{"-header", "-header-style=key"}, ExpectedStdout: "{\"_generated\":true,\"port\": 1337}\n"},
+		{Name: "invalid style", Flags: []string{"-header", "-header-style=xml"}, ExpectedCode: exitFailed, ExpectedStderr: "unsupported header style 'xml'\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			args := append([]string{"-interpreter=plain"}, tc.Flags...)
+
+			code, stdout, stderr := runCommand(t, "{\"port\": {{ .API_PORT }}}\n", append(args, volume)...)
+			if code != tc.ExpectedCode || stdout != tc.ExpectedStdout || stderr != tc.ExpectedStderr {
+				t.Fatalf("invalid result\nexpected:\n%d '%s' '%s'\nactual:\n%d '%s' '%s'\n", tc.ExpectedCode, tc.ExpectedStdout, tc.ExpectedStderr, code, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first.txt"), filepath.Join(root, "second.txt")
+
+	tcs := []struct {
+		Name           string
+		Template       string
+		Flags          []string
+		ExpectedCode   int
+		ExpectedOutput string
+	}{
+		{Name: "streamed", Template: "port {{ .API_PORT }}\n", ExpectedOutput: "port 1337\n"},
+		{Name: "buffered", Template: "port {{ .API_PORT }}\n", Flags: []string{"-header", "-header-style=hash"}, ExpectedOutput: "# Generated by cfgenerator, do not edit\nport 1337\n"},
+		{Name: "error", Template: "port {{ .API_PORT }}\n{{ fail \"boom\" }}", ExpectedCode: exitFailed},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Remove(first)
+			os.Remove(second)
+
+			args := append([]string{"-interpreter=plain", "-stream", "-out=" + first, "-out=" + second}, tc.Flags...)
+
+			code, _, stderr := runCommand(t, tc.Template, append(args, volume)...)
+			if code != tc.ExpectedCode {
+				t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", tc.ExpectedCode, code, stderr)
+			}
+
+			for _, out := range []string{first, second} {
+				content, err := ioutil.ReadFile(out)
+				if tc.ExpectedCode != exitOK {
+					if !os.IsNotExist(err) {
+						t.Fatalf("the incomplete output '%s' has been committed: %v", out, err)
+					}
+
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("can't read output: %v", err)
+				}
+
+				if string(content) != tc.ExpectedOutput {
+					t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedOutput, content)
+				}
+			}
+		})
+	}
+}
+
+func TestListOutputs(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	root := t.TempDir()
+	first, second, checksum := filepath.Join(root, "first.json"), filepath.Join(root, "second.json"), filepath.Join(root, "checksum")
+
+	tcs := []struct {
+		Name           string
+		Template       string
+		Flags          []string
+		ExpectedCode   int
+		ExpectedOutput string
+	}{
+		{
+			Name:           "static",
+			Template:       `{ port: std.extVar('UNDEFINED') }`,
+			Flags:          []string{"-out=" + first, "-out=yaml:" + second, "-checksum-out=" + checksum},
+			ExpectedOutput: first + "\n" + second + "\n" + checksum + "\n",
+		},
+		{
+			Name:           "stdout",
+			Template:       `{}`,
+			ExpectedOutput: "-\n",
+		},
+		{
+			Name:           "guards",
+			Template:       `{}`,
+			Flags:          []string{"-out=" + first, "-out=" + second, "-out-if=API_PORT=" + first, "-out-if=UNDEFINED=" + second},
+			ExpectedOutput: first + "\n",
+		},
+		{
+			Name:           "multi",
+			Template:       `{ 'api.json': { port: std.extVar('API_PORT') }, 'db/user.json': std.extVar('DATABASE_USERNAME') }`,
+			Flags:          []string{"-multi=" + root},
+			ExpectedOutput: filepath.Join(root, "api.json") + "\n" + filepath.Join(root, "db", "user.json") + "\n",
+		},
+		{
+			Name:         "multi error",
+			Template:     `{ 'api.json': std.extVar('UNDEFINED') }`,
+			Flags:        []string{"-multi=" + root},
+			ExpectedCode: exitFailed,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			args := append([]string{"-list-outputs"}, tc.Flags...)
+
+			code, stdout, stderr := runCommand(t, tc.Template, append(args, volume)...)
+			if code != tc.ExpectedCode {
+				t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", tc.ExpectedCode, code, stderr)
+			}
+
+			if stdout != tc.ExpectedOutput {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedOutput, stdout)
+			}
+
+			entries, err := ioutil.ReadDir(root)
+			if err != nil {
+				t.Fatalf("can't read directory: %v", err)
+			}
+
+			if len(entries) > 0 {
+				t.Fatalf("an output has been written: '%s'", entries[0].Name())
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	root := t.TempDir()
+	existing, created := filepath.Join(root, "existing.json"), filepath.Join(root, "created.json")
+
+	if err := ioutil.WriteFile(existing, []byte("previous content"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	code, stdout, stderr := runCommand(t, `{ port: std.extVar('API_PORT') }`, "-dry-run", "-out="+existing, "-out=-", "-out="+created, volume)
+	if code != exitOK {
+		t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", exitOK, code, stderr)
+	}
+
+	if expected := "{\n   \"port\": \"1337\"\n}\n"; stdout != expected {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, stdout)
+	}
+
+	expectedStderr := fmt.Sprintf("would write %[1]d bytes to '%[2]s'\nwould write %[1]d bytes to '%[3]s'\n", len(stdout), existing, created)
+	if stderr != expectedStderr {
+		t.Fatalf("invalid stderr\nexpected:\n'%s'\nactual:\n'%s'\n", expectedStderr, stderr)
+	}
+
+	if content, err := ioutil.ReadFile(existing); err != nil || string(content) != "previous content" {
+		t.Fatalf("the existing output has been modified: '%s' %v", content, err)
+	}
+
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("the output has been created: %v", err)
+	}
+
+	if code, _, _ := runCommand(t, `{ port: std.extVar('MISSING') }`, "-dry-run", "-out="+created, volume); code != exitFailed {
+		t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n", exitFailed, code)
+	}
+}
+
+func TestSeveralInputs(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first.tpl"), filepath.Join(root, "second.tpl")
+
+	if err := ioutil.WriteFile(first, []byte("port {{ .API_PORT }}\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	if err := ioutil.WriteFile(second, []byte("user {{ .DATABASE_USERNAME }}\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name           string
+		Args           []string
+		Stdin          string
+		ExpectedCode   int
+		ExpectedOutput string
+		ExpectedStderr string
+	}{
+		{
+			Name:           "files",
+			Args:           []string{"-interpreter=plain", "-in=" + second, "-in=" + first},
+			ExpectedOutput: "user myapp\nport 1337\n",
+		},
+		{
+			Name:           "files and stdin",
+			Args:           []string{"-interpreter=plain", "-in=" + first, "-in=-", "-in=" + second},
+			Stdin:          "from stdin {{ .API_PORT }}\n",
+			ExpectedOutput: "port 1337\nfrom stdin 1337\nuser myapp\n",
+		},
+		{
+			Name:           "jsonnet",
+			Args:           []string{"-interpreter=jsonnet", "-in=" + first, "-in=" + second},
+			ExpectedCode:   exitUsage,
+			ExpectedStderr: "several -in can only be used with the plain, html and envsubst interpreters, or with -merge, not 'jsonnet'\n",
+		},
+		{
+			Name:           "stdin twice",
+			Args:           []string{"-interpreter=plain", "-in=-", "-in=" + first, "-in=-"},
+			ExpectedCode:   exitUsage,
+			ExpectedStderr: "-in=- can't be used several times\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, tc.Stdin, append(tc.Args, volume)...)
+			if code != tc.ExpectedCode {
+				t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", tc.ExpectedCode, code, stderr)
+			}
+
+			if stdout != tc.ExpectedOutput {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedOutput, stdout)
+			}
+
+			if stderr != tc.ExpectedStderr {
+				t.Fatalf("invalid stderr\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestWatchedPaths(t *testing.T) {
+	root := t.TempDir()
+	header, footer := filepath.Join(root, "header.tpl"), filepath.Join(root, "footer.tpl")
+	for _, path := range []string{header, footer} {
+		if err := ioutil.WriteFile(path, []byte(""), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	cfg := config{
+		Ins:             []string{"config.tpl"},
+		Includes:        []string{filepath.Join(root, "*.tpl")},
+		Volumes:         []string{filepath.Join("examples", "plain", "volumes", "config")},
+		EnvFiles:        []string{"app.env"},
+		PropertiesFiles: []string{"app.properties"},
+		Schema:          "schema.json",
+	}
+
+	paths, err := watchedPaths(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"config.tpl", footer, header, filepath.Join("examples", "plain", "volumes", "config"), "app.env", "app.properties", "schema.json"}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Fatalf("invalid paths\nexpected:\n%v\nactual:\n%v\n", expected, paths)
+	}
+}
+
+// textInterpreter is a third-party interpreter producing plain text
+type textInterpreter struct{}
+
+func (textInterpreter) AddVar(name string, value string)    {}
+func (textInterpreter) Evaluate(tpl string) (string, error) { return tpl, nil }
+func (textInterpreter) IsText() bool                        { return true }
+
+func TestIsTextInterpreter(t *testing.T) {
+	tcs := []struct {
+		Name     string
+		Runtime  cfgenerator.Interpreter
+		Expected bool
+	}{
+		{Name: "plain", Runtime: interpreter.NewPlain(), Expected: true},
+		{Name: "html", Runtime: interpreter.NewHTML(), Expected: true},
+		{Name: "envsubst", Runtime: interpreter.NewEnvsubst(), Expected: true},
+		{Name: "jsonnet", Runtime: interpreter.NewJsonnet(), Expected: false},
+		{Name: "third-party", Runtime: textInterpreter{}, Expected: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			if actual := isTextInterpreter(tc.Runtime); actual != tc.Expected {
+				t.Fatalf("invalid result\nexpected:\n%t\nactual:\n%t\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestSplitOutput(t *testing.T) {
+	tcs := []struct {
+		Name           string
+		Out            string
+		ExpectedPath   string
+		ExpectedFormat format.Format
+	}{
+		{Name: "bare path", Out: "config.json", ExpectedPath: "config.json"},
+		{Name: "stdout", Out: "-", ExpectedPath: "-"},
+		{Name: "json", Out: "json:config.json", ExpectedPath: "config.json", ExpectedFormat: format.JSON},
+		{Name: "yaml", Out: "yaml:/etc/app/config.yaml", ExpectedPath: "/etc/app/config.yaml", ExpectedFormat: format.YAML},
+		{Name: "yaml stdout", Out: "yaml:-", ExpectedPath: "-", ExpectedFormat: format.YAML},
+		{Name: "windows path", Out: `C:\app\config.json`, ExpectedPath: `C:\app\config.json`},
+		{Name: "qualified windows path", Out: `toml:C:\app\config.toml`, ExpectedPath: `C:\app\config.toml`, ExpectedFormat: format.TOML},
+		{Name: "unknown prefix", Out: "xml:config.xml", ExpectedPath: "xml:config.xml"},
+		{Name: "empty path", Out: "json:", ExpectedPath: "json:"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			path, outputFormat := splitOutput(tc.Out)
+			if path != tc.ExpectedPath || outputFormat != tc.ExpectedFormat {
+				t.Fatalf("invalid output\nexpected:\n'%s' '%s'\nactual:\n'%s' '%s'\n", tc.ExpectedPath, tc.ExpectedFormat, path, outputFormat)
+			}
+		})
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	volume := filepath.Join("examples", "plain", "volumes", "config")
+	root := t.TempDir()
+	jsonOut, yamlOut, bareOut := filepath.Join(root, "a.json"), filepath.Join(root, "b.yaml"), filepath.Join(root, "c.conf")
+
+	code, _, stderr := runCommand(t, `{ port: std.extVar('API_PORT') }`, "-format=toml", "-out=json:"+jsonOut, "-out=yaml:"+yamlOut, "-out="+bareOut, volume)
+	if code != exitOK {
+		t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", exitOK, code, stderr)
+	}
+
+	for path, expected := range map[string]string{
+		jsonOut: "{\n   \"port\": \"1337\"\n}\n",
+		yamlOut: "port: \"1337\"\n",
+		bareOut: "port = '1337'\n",
+	} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("can't read output: %v", err)
+		}
+
+		if string(content) != expected {
+			t.Fatalf("invalid content of '%s'\nexpected:\n'%s'\nactual:\n'%s'\n", path, expected, content)
+		}
+	}
+
+	code, _, stderr = runCommand(t, "port {{ .API_PORT }}", "-interpreter=plain", "-out=yaml:"+yamlOut, volume)
+	if expected := "can't write 'yaml:" + yamlOut + "': -out formats can't be used with the plain, html and envsubst interpreters\n"; code != exitUsage || stderr != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%d %s\n", expected, code, stderr)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	expected := fmt.Sprintf("%s version dev (commit: unknown, built at: unknown)\n", filepath.Base(os.Args[0]))
+
+	tcs := []struct {
+		Name string
+		Args []string
+	}{
+		{Name: "alone", Args: []string{"-version"}},
+		{Name: "other flags", Args: []string{"-quiet", "-verbose", "-in=/nonexistent/template.jsonnet", "-out=/nonexistent/config.json", "-version", "/nonexistent/volume"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, "", tc.Args...)
+			if code != exitOK {
+				t.Fatalf("invalid exit code\nexpected:\n%d\nactual:\n%d\n%s", exitOK, code, stderr)
+			}
+
+			if stdout != expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, stdout)
+			}
+
+			if stderr != "" {
+				t.Fatalf("invalid stderr\nexpected:\n''\nactual:\n'%s'\n", stderr)
+			}
+		})
+	}
+}
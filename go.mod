@@ -1,9 +1,38 @@
 module github.com/fewlinesco/k8s-cfgenerator
 
-go 1.14
+go 1.21
 
 require (
+	cuelang.org/go v0.4.3
+	github.com/Masterminds/sprig/v3 v3.2.3
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/google/go-jsonnet v0.15.0
+	github.com/pelletier/go-toml/v2 v2.1.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.1.1
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b
+	golang.org/x/text v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 	honnef.co/go/tools v0.0.1-2020.1.3
 )
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/Masterminds/goutils v1.1.1 // indirect
+	github.com/Masterminds/semver/v3 v3.2.0 // indirect
+	github.com/cockroachdb/apd/v2 v2.0.1 // indirect
+	github.com/google/uuid v1.2.0 // indirect
+	github.com/huandu/xstrings v1.3.3 // indirect
+	github.com/imdario/mergo v0.3.11 // indirect
+	github.com/mitchellh/copystructure v1.0.0 // indirect
+	github.com/mitchellh/reflectwalk v1.0.0 // indirect
+	github.com/mpvl/unique v0.0.0-20150818121801-cbe035fff7de // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/shopspring/decimal v1.2.0 // indirect
+	github.com/spf13/cast v1.3.1 // indirect
+	golang.org/x/crypto v0.3.0 // indirect
+	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
+	golang.org/x/net v0.2.0 // indirect
+	golang.org/x/sys v0.2.0 // indirect
+	golang.org/x/tools v0.1.12 // indirect
+)
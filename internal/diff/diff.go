@@ -0,0 +1,176 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines written around each change of a unified diff
+const Context = 3
+
+type operation struct {
+	kind byte
+	line string
+}
+
+// Unified returns the unified diff turning the old content into the new one, naming them after
+// oldName and newName in the header, or an empty string when they're the same. Like diff -u, the
+// lines not ending with a new line are followed by a "\ No newline at end of file" marker
+func Unified(oldName string, newName string, oldContent string, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	ops := edits(splitLines(oldContent), splitLines(newContent))
+
+	// oldLines[i] and newLines[i] are the numbers of old and new lines before the operation i
+	oldLines := make([]int, len(ops)+1)
+	newLines := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLines[i+1], newLines[i+1] = oldLines[i], newLines[i]
+		if op.kind != '+' {
+			oldLines[i+1]++
+		}
+
+		if op.kind != '-' {
+			newLines[i+1]++
+		}
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// the hunk goes on while the unchanged lines between two changes fit in their contexts
+		end := i + 1
+		for j := end; j < len(ops) && j-end <= 2*Context; j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			}
+		}
+
+		start := max(0, i-Context)
+		stop := min(len(ops), end+Context)
+
+		fmt.Fprintf(
+			&buf,
+			"@@ -%s +%s @@\n",
+			hunkRange(oldLines[start], oldLines[stop]-oldLines[start]),
+			hunkRange(newLines[start], newLines[stop]-newLines[start]),
+		)
+
+		for _, op := range ops[start:stop] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = stop
+	}
+
+	return buf.String()
+}
+
+// hunkRange formats the range of lines of a hunk starting after the given number of lines, the
+// count being omitted when it's 1
+func hunkRange(before int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+// splitLines splits the content after each new line
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// edits returns the shortest list of operations turning a into b, using the Myers algorithm: the
+// furthest reaching path of each diagonal k is kept for each number of changes d, then the path
+// is walked back from the end
+func edits(a []string, b []string) []operation {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+
+		found := false
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			break
+		}
+	}
+
+	var reversed []operation
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		previousK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			previousK = k + 1
+		}
+
+		previousX := v[offset+previousK]
+		previousY := previousX - previousK
+
+		for x > previousX && y > previousY {
+			reversed = append(reversed, operation{kind: ' ', line: a[x-1]})
+			x, y = x-1, y-1
+		}
+
+		if d > 0 {
+			if x == previousX {
+				reversed = append(reversed, operation{kind: '+', line: b[y-1]})
+			} else {
+				reversed = append(reversed, operation{kind: '-', line: a[x-1]})
+			}
+		}
+
+		x, y = previousX, previousY
+	}
+
+	ops := make([]operation, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+
+	return ops
+}
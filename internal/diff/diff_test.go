@@ -0,0 +1,57 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/diff"
+)
+
+func TestUnified(t *testing.T) {
+	tcs := []struct {
+		Name     string
+		Old      string
+		New      string
+		Expected string
+	}{
+		{Name: "same", Old: "a\nb\n", New: "a\nb\n", Expected: ""},
+		{
+			Name:     "changed line",
+			Old:      "a\nb\nc\n",
+			New:      "a\nB\nc\n",
+			Expected: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			Name:     "empty old",
+			Old:      "",
+			New:      "a\n",
+			Expected: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			Name:     "missing new line",
+			Old:      "a\nb\n",
+			New:      "a\nb",
+			Expected: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			Name:     "separate hunks",
+			Old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			New:      "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			Expected: "--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			Name:     "merged hunks",
+			Old:      "1\n2\n3\n4\n5\n6\n7\n8\n",
+			New:      "0\n1\n2\n3\n4\n5\n6\n8\n",
+			Expected: "--- old\n+++ new\n@@ -1,8 +1,8 @@\n+0\n 1\n 2\n 3\n 4\n 5\n 6\n-7\n 8\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual := diff.Unified("old", "new", tc.Old, tc.New)
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
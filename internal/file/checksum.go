@@ -0,0 +1,47 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Checksum returns the lowercase hexadecimal SHA256 of the contents, indexed by output path. When
+// every output has the same content, it's the SHA256 of this content, so it matches the one given
+// by `sha256sum <output>`. Otherwise, it's the SHA256 of the `sha256sum` listing of the outputs:
+// one `<SHA256>  <path>` line per output, sorted by path
+func Checksum(contents map[string]string) string {
+	paths := make([]string, 0, len(contents))
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if len(paths) == 0 {
+		return sum("")
+	}
+
+	identical := true
+	for _, path := range paths {
+		identical = identical && contents[path] == contents[paths[0]]
+	}
+
+	if identical {
+		return sum(contents[paths[0]])
+	}
+
+	var listing strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&listing, "%s  %s\n", sum(contents[path]), path)
+	}
+
+	return sum(listing.String())
+}
+
+func sum(content string) string {
+	hash := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,40 @@
+package file_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func TestChecksum(t *testing.T) {
+	tcs := []struct {
+		Name     string
+		Contents map[string]string
+		Expected string
+	}{
+		{
+			Name:     "single output",
+			Contents: map[string]string{"config.json": "{}\n"},
+			Expected: "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356",
+		},
+		{
+			Name:     "identical outputs",
+			Contents: map[string]string{"-": "{}\n", "config.json": "{}\n"},
+			Expected: "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356",
+		},
+		{
+			// printf 'ca3d...  a.json\nc5c2...  b.yaml\n' | sha256sum
+			Name:     "different outputs",
+			Contents: map[string]string{"b.yaml": "{}\n\n", "a.json": "{}\n"},
+			Expected: "a5a10793ce244ff5b254289b027a90bbc24f742ed96db1394498cbab738be28b",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			if actual := file.Checksum(tc.Contents); tc.Expected != actual {
+				t.Fatalf("invalid checksum\nexpected:\n%s\nactual:\n%s\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// Compression represents how an output content is compressed
+type Compression string
+
+const (
+	// CompressionNone writes the content as is. It's the default behavior
+	CompressionNone Compression = "none"
+	// CompressionGzip compresses the content with gzip
+	CompressionGzip Compression = "gzip"
+)
+
+// ParseCompression returns the Compression matching the given name
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(name); compression {
+	case CompressionNone, CompressionGzip:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unsupported compression '%s'", name)
+	}
+}
+
+// Compress returns the compressed content. The level is only used by gzip, from gzip.NoCompression
+// to gzip.BestCompression or gzip.DefaultCompression. The gzip header doesn't contain any name or
+// modification time so the same content is always compressed the same way
+func Compress(content string, compression Compression, level int) (string, error) {
+	switch compression {
+	case "", CompressionNone:
+		return content, nil
+	case CompressionGzip:
+		if level < gzip.DefaultCompression || level > gzip.BestCompression {
+			return "", fmt.Errorf("invalid compression level '%d': expected a number between %d and %d, or %d for the default level", level, gzip.NoCompression, gzip.BestCompression, gzip.DefaultCompression)
+		}
+
+		var buf bytes.Buffer
+
+		writer, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return "", fmt.Errorf("can't compress content: %v", err)
+		}
+
+		if _, err := writer.Write([]byte(content)); err != nil {
+			return "", fmt.Errorf("can't compress content: %v", err)
+		}
+
+		// Close flushes the pending data and writes the gzip footer, without it the stream is truncated
+		if err := writer.Close(); err != nil {
+			return "", fmt.Errorf("can't compress content: %v", err)
+		}
+
+		return buf.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported compression '%s'", compression)
+	}
+}
@@ -0,0 +1,54 @@
+package file_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func TestCompressGzip(t *testing.T) {
+	content := strings.Repeat("{\n   \"port\": 1337\n}\n", 100)
+
+	for _, level := range []int{gzip.DefaultCompression, gzip.BestSpeed, gzip.BestCompression} {
+		compressed, err := file.Compress(content, file.CompressionGzip, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		again, err := file.Compress(content, file.CompressionGzip, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if compressed != again {
+			t.Fatalf("compression isn't deterministic at level %d", level)
+		}
+
+		reader, err := gzip.NewReader(bytes.NewBufferString(compressed))
+		if err != nil {
+			t.Fatalf("can't read gzip content: %v", err)
+		}
+
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("can't read gzip content: %v", err)
+		}
+
+		if content != string(decompressed) {
+			t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", content, decompressed)
+		}
+	}
+}
+
+func TestCompressInvalidLevel(t *testing.T) {
+	_, err := file.Compress("{}", file.CompressionGzip, 10)
+
+	expected := "invalid compression level '10': expected a number between 0 and 9, or -1 for the default level"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+}
@@ -0,0 +1,342 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// OpenInput opens the file for reading and ensures it's not empty when it's a regular file, and
+// not a directory. If path is `-` it reads from STDIN, which can be a pipe whose size isn't known
+func OpenInput(path string) (*os.File, error) {
+	var input *os.File
+
+	switch path {
+	case "-":
+		input = os.Stdin
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("can't open file")
+		}
+
+		input = f
+	}
+
+	stat, err := input.Stat()
+	if err != nil {
+		return input, fmt.Errorf("can't read from file: %v", err)
+	}
+
+	if stat.IsDir() {
+		input.Close()
+
+		return nil, fmt.Errorf("expected a file but got a directory")
+	}
+
+	if stat.Mode().IsRegular() && stat.Size() <= 0 {
+		return input, fmt.Errorf("empty file")
+	}
+
+	return input, nil
+}
+
+// DefaultMode is the permission of a created output file
+const DefaultMode os.FileMode = 0644
+
+// OutputOptions represents the settings used to open an output file
+type OutputOptions struct {
+	// MkdirAll creates the missing parent folders of the file
+	MkdirAll bool
+	// Mode defines the permissions of the file. When zero, the permissions of the replaced file
+	// are kept, or DefaultMode is used for a new file
+	Mode os.FileMode
+	// Lock acquires an exclusive advisory lock (flock) on the LockSuffix file next to the output
+	// before writing it, waiting for the other processes holding it, and releases it when the
+	// output is closed. It's an error on the systems not supporting flock
+	Lock bool
+}
+
+// LockSuffix is appended to the path of an output to get the path of its lock file. The output
+// itself can't be locked as it's replaced when committed
+const LockSuffix = ".lock"
+
+// ParseMode returns the permissions matching an octal string like `0600`
+func ParseMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid mode '%s': expected octal permissions between 0000 and 0777 (e.g. 0600)", value)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// Output represents a file written atomically: the content is written to a temporary file
+// in the same folder and only moved in place when committed
+type Output struct {
+	path   string
+	file   *os.File
+	temp   bool
+	mode   os.FileMode
+	lock   *os.File
+	stream io.WriteCloser
+	// prepared tells the temporary file is closed with its permissions set, only waiting to be
+	// moved in place
+	prepared bool
+}
+
+// OpenOutput opens the file for writing.
+// If path is `-` it writes to STDOUT directly, otherwise it writes to a temporary file until
+// the output is committed. An existing named pipe or Unix socket (see IsStream) is written
+// directly as well, without any temporary file: opening a named pipe waits for its reader and the
+// mode is ignored
+func OpenOutput(path string, opts OutputOptions) (*Output, error) {
+	switch path {
+	case "-":
+		return &Output{path: path, file: os.Stdout}, nil
+	default:
+		mode := opts.Mode
+		if mode == 0 {
+			mode = DefaultMode
+			if stat, err := os.Stat(path); err == nil {
+				mode = stat.Mode().Perm()
+			}
+		}
+
+		if opts.MkdirAll {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, fmt.Errorf("can't create parent folder: %v", err)
+			}
+		}
+
+		var lockFile *os.File
+		if opts.Lock {
+			var err error
+			if lockFile, err = acquireLock(path); err != nil {
+				return nil, err
+			}
+		}
+
+		if stat, err := os.Stat(path); err == nil && isStreamMode(stat.Mode()) {
+			stream, err := openStream(path, stat.Mode())
+			if err != nil {
+				releaseLock(lockFile)
+				return nil, err
+			}
+
+			return &Output{path: path, stream: stream, lock: lockFile}, nil
+		}
+
+		f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+		if err != nil {
+			releaseLock(lockFile)
+			return nil, fmt.Errorf("can't open file: %v", err)
+		}
+
+		return &Output{path: path, file: f, temp: true, mode: mode, lock: lockFile}, nil
+	}
+}
+
+// CheckWritable ensures an output can be opened by OpenOutput with the options, without writing
+// it: the file isn't a folder and a temporary file can be created in its folder, or in its closest
+// existing parent folder when the missing ones are created by OutputOptions.MkdirAll. STDOUT (`-`)
+// and the streams are always writable
+func CheckWritable(path string, opts OutputOptions) error {
+	if path == "-" || IsStream(path) {
+		return nil
+	}
+
+	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+		return fmt.Errorf("the path is a folder")
+	}
+
+	folder := filepath.Dir(path)
+	for {
+		stat, err := os.Stat(folder)
+		if err == nil {
+			if !stat.IsDir() {
+				return fmt.Errorf("'%s' isn't a folder", folder)
+			}
+
+			break
+		}
+
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("can't read folder '%s': %v", folder, err)
+		}
+
+		if !opts.MkdirAll {
+			return fmt.Errorf("folder '%s' doesn't exist", folder)
+		}
+
+		parent := filepath.Dir(folder)
+		if parent == folder {
+			return fmt.Errorf("folder '%s' doesn't exist", folder)
+		}
+
+		folder = parent
+	}
+
+	f, err := ioutil.TempFile(folder, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("can't write to folder '%s': %v", folder, err)
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// HasContent returns true when the file exists and its content is exactly the given content. It's
+// always false for a stream, which isn't read
+func HasContent(path string, content string) (bool, error) {
+	if IsStream(path) {
+		return false, nil
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("can't read file: %v", err)
+	}
+
+	return string(existing) == content, nil
+}
+
+// Path returns the path of the output, `-` for STDOUT
+func (o *Output) Path() string {
+	return o.path
+}
+
+// Write writes the content to the output
+func (o *Output) Write(p []byte) (int, error) {
+	if o.stream != nil {
+		n, err := o.stream.Write(p)
+
+		return n, streamError(err)
+	}
+
+	return o.file.Write(p)
+}
+
+// Prepare sets the permissions of the temporary file and closes it, and ensures the path isn't a
+// folder, so committing the output can only fail moving the file in place
+func (o *Output) Prepare() error {
+	if !o.temp || o.prepared {
+		return nil
+	}
+
+	if err := o.file.Chmod(o.mode); err != nil {
+		return fmt.Errorf("can't set file permissions: %v", err)
+	}
+
+	if err := o.file.Close(); err != nil {
+		return fmt.Errorf("can't write file: %v", err)
+	}
+
+	o.prepared = true
+
+	if stat, err := os.Stat(o.path); err == nil && stat.IsDir() {
+		return fmt.Errorf("can't move file in place: the path is a folder")
+	}
+
+	return nil
+}
+
+// Commit moves the written content in place, keeping the permissions of the file it replaces
+func (o *Output) Commit() error {
+	if !o.temp {
+		return nil
+	}
+
+	if err := o.Prepare(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(o.file.Name(), o.path); err != nil {
+		os.Remove(o.file.Name())
+		return fmt.Errorf("can't move file in place: %v", err)
+	}
+
+	o.temp = false
+
+	return nil
+}
+
+// CommitAll prepares all the outputs before committing any of them, so an output failing to be
+// prepared leaves all the files untouched. The outputs are then moved in place in order: a move
+// failing leaves the previous outputs committed
+func CommitAll(outputs []*Output) error {
+	for _, output := range outputs {
+		if err := output.Prepare(); err != nil {
+			return fmt.Errorf("can't write output file '%s': %v", output.Path(), err)
+		}
+	}
+
+	for _, output := range outputs {
+		if err := output.Commit(); err != nil {
+			return fmt.Errorf("can't write output file '%s': %v", output.Path(), err)
+		}
+	}
+
+	return nil
+}
+
+// Close discards the written content when the output hasn't been committed and releases its lock.
+// A stream is closed, signaling the end of the content to its reader
+func (o *Output) Close() error {
+	defer func() {
+		releaseLock(o.lock)
+		o.lock = nil
+	}()
+
+	if o.stream != nil {
+		err := o.stream.Close()
+		o.stream = nil
+
+		return err
+	}
+
+	if !o.temp {
+		return nil
+	}
+
+	if !o.prepared {
+		o.file.Close()
+	}
+	o.temp = false
+
+	return os.Remove(o.file.Name())
+}
+
+// acquireLock opens the lock file of the output and locks it
+func acquireLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+LockSuffix, os.O_RDWR|os.O_CREATE, DefaultMode)
+	if err != nil {
+		return nil, fmt.Errorf("can't open lock file: %v", err)
+	}
+
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("can't lock file: %v", err)
+	}
+
+	return f, nil
+}
+
+// releaseLock unlocks and closes the lock file, if any. The lock file isn't removed as another
+// process may be waiting for it
+func releaseLock(f *os.File) {
+	if f == nil {
+		return
+	}
+
+	unlock(f)
+	f.Close()
+}
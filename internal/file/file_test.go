@@ -0,0 +1,333 @@
+package file_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func readFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("can't read file: %v", err)
+	}
+
+	return string(content)
+}
+
+func writeOutput(t *testing.T, path string, content string, opts file.OutputOptions) *file.Output {
+	output, err := file.OpenOutput(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := output.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	return output
+}
+
+func TestOutputAtomic(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "config.json")
+	if err := ioutil.WriteFile(path, []byte("previous content"), 0600); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	discarded := writeOutput(t, path, "discarded", file.OutputOptions{})
+	if err := discarded.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := "previous content", readFile(t, path); expected != actual {
+		t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+
+	committed := writeOutput(t, path, "new", file.OutputOptions{})
+	defer committed.Close()
+
+	if expected, actual := "previous content", readFile(t, path); expected != actual {
+		t.Fatalf("content changed before commit\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+
+	if err := committed.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := "new", readFile(t, path); expected != actual {
+		t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := os.FileMode(0600), stat.Mode().Perm(); expected != actual {
+		t.Fatalf("invalid permissions\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestOutputMkdirAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generated", "config.json")
+
+	if _, err := file.OpenOutput(path, file.OutputOptions{}); err == nil {
+		t.Fatalf("expected an error when the parent folder is missing")
+	}
+
+	output := writeOutput(t, path, "content", file.OutputOptions{MkdirAll: true})
+	defer output.Close()
+
+	if err := output.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := "content", readFile(t, path); expected != actual {
+		t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+}
+
+func TestOutputMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte("previous content"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	mode, err := file.ParseMode("0600")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := writeOutput(t, path, "secret", file.OutputOptions{Mode: mode})
+	defer output.Close()
+
+	if err := output.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := os.FileMode(0600), stat.Mode().Perm(); expected != actual {
+		t.Fatalf("invalid permissions\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+	}
+}
+
+func TestParseModeInvalid(t *testing.T) {
+	for _, value := range []string{"", "0800", "rw-------", "01777"} {
+		t.Run(value, func(t *testing.T) {
+			if _, err := file.ParseMode(value); err == nil {
+				t.Fatalf("expected an error for '%s'", value)
+			}
+		})
+	}
+}
+
+func TestHasContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	tcs := []struct {
+		Name     string
+		Existing *string
+		Content  string
+		Expected bool
+	}{
+		{Name: "missing", Existing: nil, Content: "content", Expected: false},
+		{Name: "same", Existing: stringPtr("content"), Content: "content", Expected: true},
+		{Name: "different", Existing: stringPtr("content"), Content: "content\n", Expected: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Remove(path)
+			if tc.Existing != nil {
+				if err := ioutil.WriteFile(path, []byte(*tc.Existing), 0644); err != nil {
+					t.Fatalf("can't write file: %v", err)
+				}
+			}
+
+			actual, err := file.HasContent(path, tc.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != actual {
+				t.Fatalf("invalid result\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestCheckWritable(t *testing.T) {
+	root := t.TempDir()
+
+	parentFile := filepath.Join(root, "parent")
+	if err := ioutil.WriteFile(parentFile, []byte("content"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name          string
+		Path          string
+		Options       file.OutputOptions
+		ExpectedError string
+	}{
+		{Name: "stdout", Path: "-"},
+		{Name: "new file", Path: filepath.Join(root, "config.json")},
+		{Name: "existing file", Path: parentFile},
+		{Name: "missing folder with mkdir", Path: filepath.Join(root, "a", "b", "config.json"), Options: file.OutputOptions{MkdirAll: true}},
+		{
+			Name:          "missing folder",
+			Path:          filepath.Join(root, "a", "config.json"),
+			ExpectedError: "folder '" + filepath.Join(root, "a") + "' doesn't exist",
+		},
+		{
+			Name:          "folder",
+			Path:          root,
+			ExpectedError: "the path is a folder",
+		},
+		{
+			Name:          "file parent",
+			Path:          filepath.Join(parentFile, "config.json"),
+			Options:       file.OutputOptions{MkdirAll: true},
+			ExpectedError: "'" + parentFile + "' isn't a folder",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := file.CheckWritable(tc.Path, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatalf("can't read folder: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("the check left files behind: %d entries", len(entries))
+	}
+}
+
+func TestOpenInput(t *testing.T) {
+	root := t.TempDir()
+
+	empty := filepath.Join(root, "empty.jsonnet")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	template := filepath.Join(root, "config.jsonnet")
+	if err := ioutil.WriteFile(template, []byte("{}"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name          string
+		Path          string
+		ExpectedError string
+	}{
+		{Name: "file", Path: template},
+		{Name: "directory", Path: root, ExpectedError: "expected a file but got a directory"},
+		{Name: "empty", Path: empty, ExpectedError: "empty file"},
+		{Name: "missing", Path: filepath.Join(root, "missing.jsonnet"), ExpectedError: "can't open file"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			input, err := file.OpenInput(tc.Path)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+
+			if expected, actual := "{}", readFile(t, input.Name()); expected != actual {
+				t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+			}
+		})
+	}
+}
+
+func TestCommitAll(t *testing.T) {
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first.json"), filepath.Join(root, "second.json")
+	if err := ioutil.WriteFile(first, []byte("previous content"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	firstOutput := writeOutput(t, first, "new", file.OutputOptions{})
+	defer firstOutput.Close()
+
+	secondOutput := writeOutput(t, second, "new", file.OutputOptions{})
+	defer secondOutput.Close()
+
+	// the second output can't be committed anymore
+	if err := os.MkdirAll(filepath.Join(second, "nested"), 0755); err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+
+	err := file.CommitAll([]*file.Output{firstOutput, secondOutput})
+	if expected := "can't write output file '" + second + "': can't move file in place: the path is a folder"; err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+
+	if expected, actual := "previous content", readFile(t, first); expected != actual {
+		t.Fatalf("the first output has been committed\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+
+	if err := firstOutput.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secondOutput.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
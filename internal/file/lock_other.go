@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockUnsupported = errors.New("file locking isn't supported on this system")
+
+// lock fails as flock isn't available on the system
+func lock(f *os.File) error {
+	return errLockUnsupported
+}
+
+// unlock fails as flock isn't available on the system
+func unlock(f *os.File) error {
+	return errLockUnsupported
+}
@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package file_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func TestOutputLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	first := writeOutput(t, path, "first", file.OutputOptions{Lock: true})
+
+	acquired := make(chan *file.Output)
+	go func() {
+		output, err := file.OpenOutput(path, file.OutputOptions{Lock: true})
+		if err != nil {
+			t.Error(err)
+		}
+
+		acquired <- output
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("lock acquired while another output holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case second := <-acquired:
+		if second == nil {
+			t.FailNow()
+		}
+
+		second.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("lock not acquired once released")
+	}
+
+	if expected, actual := "first", readFile(t, path); expected != actual {
+		t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+}
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultRemoteTimeout bounds the download of a remote input, including the reading of its body
+const DefaultRemoteTimeout = 30 * time.Second
+
+// IsRemote returns whether the path is an HTTP or HTTPS URL
+func IsRemote(path string) bool {
+	lower := strings.ToLower(path)
+
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// RemotePath returns the path part of the URL, without the query nor the fragment, e.g. to detect
+// the interpreter of a remote template from its extension
+func RemotePath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return parsed.Path
+}
+
+// OpenRemote downloads the content of the HTTP or HTTPS URL and ensures it's not empty. The whole
+// download must complete within the timeout, there's no limit when 0. A response whose status
+// isn't a 2xx is an error
+func OpenRemote(rawURL string, timeout time.Duration) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: timeout}
+
+	response, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't download file: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("can't download file: unexpected HTTP status '%s'", response.Status)
+	}
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can't download file: %v", err)
+	}
+
+	if len(content) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
@@ -0,0 +1,104 @@
+package file_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func TestOpenRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config.jsonnet":
+			fmt.Fprint(w, `{ port: std.extVar("API_PORT") }`)
+		case "/empty.jsonnet":
+		case "/slow.jsonnet":
+			time.Sleep(time.Second)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tcs := []struct {
+		Name          string
+		Path          string
+		Timeout       time.Duration
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "ok",
+			Path:     "/config.jsonnet?ref=main",
+			Expected: `{ port: std.extVar("API_PORT") }`,
+		},
+		{
+			Name:          "not found",
+			Path:          "/missing.jsonnet",
+			ExpectedError: "can't download file: unexpected HTTP status '404 Not Found'",
+		},
+		{
+			Name:          "empty",
+			Path:          "/empty.jsonnet",
+			ExpectedError: "empty file",
+		},
+		{
+			Name:          "timeout",
+			Path:          "/slow.jsonnet",
+			Timeout:       50 * time.Millisecond,
+			ExpectedError: "can't download file: ",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			input, err := file.OpenRemote(server.URL+tc.Path, tc.Timeout)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+
+			content, err := ioutil.ReadAll(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != string(content) {
+				t.Fatalf("invalid content\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, string(content))
+			}
+		})
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"https://example.com/templates/config.jsonnet?ref=main#L1": "/templates/config.jsonnet",
+		"HTTP://example.com/config.star":                           "/config.star",
+	} {
+		if !file.IsRemote(path) {
+			t.Fatalf("'%s' should be remote", path)
+		}
+
+		if actual := file.RemotePath(path); expected != actual {
+			t.Fatalf("invalid path\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+		}
+	}
+
+	if file.IsRemote("templates/http.jsonnet") {
+		t.Fatalf("local path shouldn't be remote")
+	}
+}
@@ -0,0 +1,51 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// IsStream returns true when the path is an existing named pipe (FIFO) or Unix socket. OpenOutput
+// writes to them directly, as a stream, instead of atomically replacing them
+func IsStream(path string) bool {
+	stat, err := os.Stat(path)
+
+	return err == nil && isStreamMode(stat.Mode())
+}
+
+func isStreamMode(mode os.FileMode) bool {
+	return mode&(os.ModeNamedPipe|os.ModeSocket) != 0
+}
+
+// openStream connects to the Unix socket or opens the named pipe for writing. Opening a named pipe
+// blocks until a reader opens it as well
+func openStream(path string, mode os.FileMode) (io.WriteCloser, error) {
+	if mode&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("can't connect to socket: %v", err)
+		}
+
+		return conn, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("can't open named pipe: %v", err)
+	}
+
+	return f, nil
+}
+
+// streamError explains the errors of a reader which went away while the content was written
+func streamError(err error) error {
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("the reader went away before the content was written (broken pipe)")
+	}
+
+	return err
+}
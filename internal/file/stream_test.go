@@ -0,0 +1,120 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package file_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/file"
+)
+
+func TestOutputNamedPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatalf("can't create named pipe: %v", err)
+	}
+
+	if !file.IsStream(path) {
+		t.Fatal("expected the named pipe to be a stream")
+	}
+
+	if err := file.CheckWritable(path, file.OutputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if unchanged, err := file.HasContent(path, ""); err != nil || unchanged {
+		t.Fatalf("invalid content check: %v, %v", unchanged, err)
+	}
+
+	received := make(chan string)
+	go func() {
+		content, _ := ioutil.ReadFile(path)
+		received <- string(content)
+	}()
+
+	output := writeOutput(t, path, "content", file.OutputOptions{})
+	if err := output.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := <-received; actual != "content" {
+		t.Fatalf("invalid content\nexpected:\n'content'\nactual:\n'%s'\n", actual)
+	}
+
+	if stat, err := os.Stat(path); err != nil || stat.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("the named pipe has been replaced: %v", err)
+	}
+}
+
+func TestOutputNamedPipeBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatalf("can't create named pipe: %v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		if f, err := os.Open(path); err == nil {
+			f.Close()
+		}
+		close(closed)
+	}()
+
+	output, err := file.OpenOutput(path, file.OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	<-closed
+
+	_, err = output.Write([]byte("content"))
+	if expected := "the reader went away before the content was written (broken pipe)"; err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+}
+
+func TestOutputSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("can't listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+
+		content, _ := ioutil.ReadAll(conn)
+		received <- string(content)
+	}()
+
+	output := writeOutput(t, path, "content", file.OutputOptions{})
+	if err := output.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := <-received; actual != "content" {
+		t.Fatalf("invalid content\nexpected:\n'content'\nactual:\n'%s'\n", actual)
+	}
+}
@@ -0,0 +1,457 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format represents the encoding of the generated content
+type Format string
+
+const (
+	// JSON keeps the content as produced by the interpreter. It's the default behavior
+	JSON Format = "json"
+	// YAML converts the JSON content produced by the interpreter to YAML
+	YAML Format = "yaml"
+	// TOML converts the JSON content produced by the interpreter to TOML. The content must be an
+	// object without null values
+	TOML Format = "toml"
+	// Env converts the JSON content produced by the interpreter to `KEY=VALUE` lines, ready to be
+	// sourced by a shell. The content must be a flat object whose keys are valid variable names
+	Env Format = "env"
+	// Auto picks the format of each output from its extension with ForPath. Converting a content
+	// to Auto keeps it as produced by the interpreter, like JSON
+	Auto Format = "auto"
+)
+
+// extensions maps the output file extensions to the format used by Auto
+var extensions = map[string]Format{
+	".json": JSON,
+	".yaml": YAML,
+	".yml":  YAML,
+	".toml": TOML,
+	".env":  Env,
+}
+
+// ForPath returns the format matching the extension of an output path, or JSON when the extension
+// is unknown or when the path is `-` (STDOUT)
+func ForPath(path string) Format {
+	if format, found := extensions[strings.ToLower(filepath.Ext(path))]; found {
+		return format
+	}
+
+	return JSON
+}
+
+// Extension returns the file extension of the format, e.g. ".yaml". Auto uses the extension of
+// JSON, as it keeps the content as is
+func Extension(f Format) string {
+	switch f {
+	case YAML, TOML, Env:
+		return "." + string(f)
+	default:
+		return ".json"
+	}
+}
+
+// Parse returns the Format matching the given name
+func Parse(name string) (Format, error) {
+	switch format := Format(name); format {
+	case JSON, YAML, TOML, Env, Auto:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s'", name)
+	}
+}
+
+// Options represents the settings used to encode the content
+type Options struct {
+	// YAMLStream encodes each element of the top-level array as its own YAML document. Any other
+	// top-level value is an error
+	YAMLStream bool
+	// Indent re-indents the JSON content with the given string. The indentation produced by the
+	// interpreter is kept when empty
+	Indent string
+	// Compact removes all the insignificant white spaces of the JSON content
+	Compact bool
+}
+
+// ParseIndent returns the indentation matching the value: a number of spaces or `\t` for a tab.
+// `0` means the content is compacted instead
+func ParseIndent(value string) (string, bool, error) {
+	if value == `\t` || value == "\t" {
+		return "\t", false, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return "", false, fmt.Errorf("invalid indent '%s': expected a number of spaces or '\\t'", value)
+	}
+
+	if n == 0 {
+		return "", true, nil
+	}
+
+	return strings.Repeat(" ", n), false, nil
+}
+
+// Convert encodes the JSON content in the given format. Object keys are sorted so the result is
+// deterministic
+func Convert(content string, format Format, opts Options) (string, error) {
+	switch format {
+	case "", JSON, Auto:
+		return reindentJSON(content, opts)
+	case YAML:
+		value, err := decodeJSON(content)
+		if err != nil {
+			return "", err
+		}
+
+		return encodeYAML(value, opts)
+	case TOML:
+		value, err := decodeJSON(content)
+		if err != nil {
+			return "", err
+		}
+
+		return encodeTOML(value)
+	case Env:
+		value, err := decodeJSON(content)
+		if err != nil {
+			return "", err
+		}
+
+		return encodeEnv(value)
+	default:
+		return "", fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+// Decode parses a content encoded in the given format, like the output of a text template, and
+// returns it as JSON ready to be converted. A YAML content must be a single document unless the
+// YAMLStream option is set, in which case all the documents are returned as an array
+func Decode(content string, format Format, opts Options) (string, error) {
+	switch format {
+	case "", JSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(strings.TrimSpace(content)), "", "   "); err != nil {
+			return "", fmt.Errorf("can't parse content as JSON: %v", err)
+		}
+		buf.WriteString("\n")
+
+		return buf.String(), nil
+	case YAML:
+		documents, err := decodeYAML(content)
+		if err != nil {
+			return "", err
+		}
+
+		var value interface{} = documents
+		if !opts.YAMLStream {
+			if len(documents) != 1 {
+				return "", fmt.Errorf("can't parse content as YAML: expected a single document but got %d", len(documents))
+			}
+
+			value = documents[0]
+		}
+
+		encoded, err := json.MarshalIndent(value, "", "   ")
+		if err != nil {
+			return "", fmt.Errorf("can't parse content as YAML: %v", err)
+		}
+
+		return string(encoded) + "\n", nil
+	case TOML:
+		var value map[string]interface{}
+		if err := toml.Unmarshal([]byte(content), &value); err != nil {
+			return "", fmt.Errorf("can't parse content as TOML: %v", err)
+		}
+
+		encoded, err := json.MarshalIndent(value, "", "   ")
+		if err != nil {
+			return "", fmt.Errorf("can't parse content as TOML: %v", err)
+		}
+
+		return string(encoded) + "\n", nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+func decodeYAML(content string) ([]interface{}, error) {
+	documents := []interface{}{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var document interface{}
+		if err := decoder.Decode(&document); err == io.EOF {
+			return documents, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("can't parse content as YAML: %v", err)
+		}
+
+		documents = append(documents, document)
+	}
+}
+
+func reindentJSON(content string, opts Options) (string, error) {
+	if !opts.Compact && opts.Indent == "" {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+
+	var err error
+	if opts.Compact {
+		err = json.Compact(&buf, []byte(content))
+	} else {
+		err = json.Indent(&buf, []byte(strings.TrimSpace(content)), "", opts.Indent)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("can't parse content as JSON: %v", err)
+	}
+
+	buf.WriteString("\n")
+
+	return buf.String(), nil
+}
+
+// decodeJSON keeps the numbers as json.Number so the encoders can write them verbatim instead of
+// rounding them to a float64
+func decodeJSON(content string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("can't parse content as JSON: %v", err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("can't parse content as JSON: unexpected content after the value")
+	}
+
+	return value, nil
+}
+
+func encodeYAML(value interface{}, opts Options) (string, error) {
+	value = yamlValue(value)
+
+	documents := []interface{}{value}
+	if opts.YAMLStream {
+		array, ok := value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("can't encode content as a YAML stream: expected an array but got %s", typeName(value))
+		}
+
+		documents = array
+	}
+
+	var buf strings.Builder
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return "", fmt.Errorf("can't encode content as YAML: %v", err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("can't encode content as YAML: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// yamlValue replaces the JSON numbers by YAML nodes holding them verbatim, the encoder quoting
+// them as strings otherwise
+func yamlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(value.String(), ".eE") {
+			tag = "!!float"
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value.String()}
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			array[i] = yamlValue(item)
+		}
+
+		return array
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			object[key] = yamlValue(item)
+		}
+
+		return object
+	default:
+		return value
+	}
+}
+
+func encodeTOML(value interface{}) (string, error) {
+	if _, ok := value.(map[string]interface{}); !ok {
+		return "", fmt.Errorf("can't encode content as TOML: expected an object but got %s", typeName(value))
+	}
+
+	value, err := tomlValue(value, "")
+	if err != nil {
+		return "", fmt.Errorf("can't encode content as TOML: %v", err)
+	}
+
+	var buf strings.Builder
+	if err := toml.NewEncoder(&buf).Encode(value); err != nil {
+		return "", fmt.Errorf("can't encode content as TOML: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+var (
+	envNameRegexp     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	envUnquotedRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+// encodeEnv writes the object as `KEY=VALUE` lines sorted by key. Strings are written as is,
+// numbers and booleans as their JSON encoding. The values which aren't made of safe characters
+// only are single-quoted for the shell
+func encodeEnv(value interface{}) (string, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("can't encode content as env: expected an object but got %s", typeName(value))
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if !envNameRegexp.MatchString(key) {
+			return "", fmt.Errorf("can't encode content as env: invalid variable name '%s'", key)
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	for _, key := range keys {
+		var text string
+
+		switch item := object[key].(type) {
+		case string:
+			text = item
+		case json.Number:
+			text = item.String()
+		case bool:
+			text = strconv.FormatBool(item)
+		default:
+			return "", fmt.Errorf("can't encode content as env: the value of '%s' is %s, expected a string, a number or a boolean", key, typeName(item))
+		}
+
+		buf.WriteString(key)
+		buf.WriteString("=")
+		buf.WriteString(shellQuote(text))
+		buf.WriteString("\n")
+	}
+
+	return buf.String(), nil
+}
+
+// shellQuote single quotes the text unless it's only made of characters the shell doesn't
+// interpret. A single quote in the text closes the quoting, is escaped and reopens it
+func shellQuote(text string) string {
+	if envUnquotedRegexp.MatchString(text) {
+		return text
+	}
+
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
+
+// tomlValue checks the value can be represented in TOML and converts the JSON numbers to integers
+// when they are written without a fraction or an exponent, TOML making the difference between both.
+// TOML integers being 64 bits, larger ones can't be represented
+func tomlValue(value interface{}, path string) (interface{}, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("null value at '%s' can't be represented", path)
+	case json.Number:
+		if !strings.ContainsAny(value.String(), ".eE") {
+			integer, err := value.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("integer %s at '%s' can't be represented", value, path)
+			}
+
+			return integer, nil
+		}
+
+		float, err := value.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s at '%s' can't be represented", value, path)
+		}
+
+		return float, nil
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			converted, err := tomlValue(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+
+			array[i] = converted
+		}
+
+		return array, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+
+			converted, err := tomlValue(item, itemPath)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key] = converted
+		}
+
+		return object, nil
+	default:
+		return value, nil
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64, json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,324 @@
+package format_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+)
+
+func TestConvert(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Format        format.Format
+		Options       format.Options
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "json",
+			Content:  "{\n   \"b\": 1,\n   \"a\": true\n}\n",
+			Format:   format.JSON,
+			Expected: "{\n   \"b\": 1,\n   \"a\": true\n}\n",
+		},
+		{
+			Name:     "json two spaces",
+			Content:  "{\n   \"b\": [1, 2],\n   \"a\": true\n}\n",
+			Format:   format.JSON,
+			Options:  format.Options{Indent: "  "},
+			Expected: "{\n  \"b\": [\n    1,\n    2\n  ],\n  \"a\": true\n}\n",
+		},
+		{
+			Name:     "json tabs",
+			Content:  "{\n   \"a\": true\n}\n",
+			Format:   format.JSON,
+			Options:  format.Options{Indent: "\t"},
+			Expected: "{\n\t\"a\": true\n}\n",
+		},
+		{
+			Name:     "json compact",
+			Content:  "{\n   \"b\": [1, 2],\n   \"a\": \"x y\"\n}\n",
+			Format:   format.JSON,
+			Options:  format.Options{Compact: true},
+			Expected: "{\"b\":[1,2],\"a\":\"x y\"}\n",
+		},
+		{
+			Name:     "yaml",
+			Content:  `{"b": {"d": [1, 2.5], "c": null}, "a": "0.0.0.0:1337"}`,
+			Format:   format.YAML,
+			Expected: "a: 0.0.0.0:1337\nb:\n  c: null\n  d:\n    - 1\n    - 2.5\n",
+		},
+		{
+			Name:     "yaml large numbers",
+			Content:  `{"id": 12345678901234567890, "size": 1e+06, "ratio": 0.1}`,
+			Format:   format.YAML,
+			Expected: "id: 12345678901234567890\nratio: 0.1\nsize: 1e+06\n",
+		},
+		{
+			Name:     "yaml array",
+			Content:  `[{"kind": "Service"}, {"kind": "Deployment"}]`,
+			Format:   format.YAML,
+			Expected: "- kind: Service\n- kind: Deployment\n",
+		},
+		{
+			Name:     "yaml stream",
+			Content:  `[{"kind": "Service"}, {"kind": "Deployment"}]`,
+			Format:   format.YAML,
+			Options:  format.Options{YAMLStream: true},
+			Expected: "kind: Service\n---\nkind: Deployment\n",
+		},
+		{
+			Name:          "yaml stream object",
+			Content:       `{"kind": "Service"}`,
+			Format:        format.YAML,
+			Options:       format.Options{YAMLStream: true},
+			ExpectedError: "can't encode content as a YAML stream: expected an array but got an object",
+		},
+		{
+			Name:          "yaml stream string",
+			Content:       `"kind: Service"`,
+			Format:        format.YAML,
+			Options:       format.Options{YAMLStream: true},
+			ExpectedError: "can't encode content as a YAML stream: expected an array but got a string",
+		},
+		{
+			Name:     "toml",
+			Content:  `{"port": 1337, "ratio": 0.5, "db": {"hosts": ["a", "b"]}, "servers": [{"name": "a"}, {"name": "b"}]}`,
+			Format:   format.TOML,
+			Expected: "port = 1337\nratio = 0.5\n\n[db]\nhosts = ['a', 'b']\n\n[[servers]]\nname = 'a'\n\n[[servers]]\nname = 'b'\n",
+		},
+		{
+			Name:     "toml exponent",
+			Content:  `{"size": 1e+06, "ratio": 0.1}`,
+			Format:   format.TOML,
+			Expected: "ratio = 0.1\nsize = 1000000.0\n",
+		},
+		{
+			Name:          "toml large integer",
+			Content:       `{"db": {"id": 12345678901234567890}}`,
+			Format:        format.TOML,
+			ExpectedError: "can't encode content as TOML: integer 12345678901234567890 at 'db.id' can't be represented",
+		},
+		{
+			Name:          "toml null",
+			Content:       `{"db": {"hosts": ["a", null]}}`,
+			Format:        format.TOML,
+			ExpectedError: "can't encode content as TOML: null value at 'db.hosts[1]' can't be represented",
+		},
+		{
+			Name:          "toml array",
+			Content:       `[{"name": "a"}]`,
+			Format:        format.TOML,
+			ExpectedError: "can't encode content as TOML: expected an object but got an array",
+		},
+		{
+			Name:     "env",
+			Content:  `{"PORT": 1337, "DEBUG": false, "HOST": "db.local:5432", "GREETING": "it's $HOME", "EMPTY": ""}`,
+			Format:   format.Env,
+			Expected: "DEBUG=false\nEMPTY=''\nGREETING='it'\\''s $HOME'\nHOST=db.local:5432\nPORT=1337\n",
+		},
+		{
+			Name:     "env large numbers",
+			Content:  `{"ID": 12345678901234567890, "SIZE": 1e+06}`,
+			Format:   format.Env,
+			Expected: "ID=12345678901234567890\nSIZE=1e+06\n",
+		},
+		{
+			Name:          "env nested",
+			Content:       `{"DB": {"host": "localhost"}}`,
+			Format:        format.Env,
+			ExpectedError: "can't encode content as env: the value of 'DB' is an object, expected a string, a number or a boolean",
+		},
+		{
+			Name:          "env invalid name",
+			Content:       `{"log-level": "debug"}`,
+			Format:        format.Env,
+			ExpectedError: "can't encode content as env: invalid variable name 'log-level'",
+		},
+		{
+			Name:          "env array",
+			Content:       `["a"]`,
+			Format:        format.Env,
+			ExpectedError: "can't encode content as env: expected an object but got an array",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			output, err := format.Convert(tc.Content, tc.Format, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Format        format.Format
+		Options       format.Options
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "json",
+			Content:  "  {\"b\": 1, \"a\": [true]}  \n",
+			Format:   format.JSON,
+			Expected: "{\n   \"b\": 1,\n   \"a\": [\n      true\n   ]\n}\n",
+		},
+		{
+			Name:          "invalid json",
+			Content:       `{"port": }`,
+			Format:        format.JSON,
+			ExpectedError: "can't parse content as JSON: invalid character '}' looking for beginning of value",
+		},
+		{
+			Name:     "yaml",
+			Content:  "port: 1337\nhosts:\n  - a\n",
+			Format:   format.YAML,
+			Expected: "{\n   \"hosts\": [\n      \"a\"\n   ],\n   \"port\": 1337\n}\n",
+		},
+		{
+			Name:          "invalid yaml",
+			Content:       "password: a: b\n",
+			Format:        format.YAML,
+			ExpectedError: "can't parse content as YAML: yaml: mapping values are not allowed in this context",
+		},
+		{
+			Name:          "yaml documents",
+			Content:       "kind: Service\n---\nkind: Deployment\n",
+			Format:        format.YAML,
+			ExpectedError: "can't parse content as YAML: expected a single document but got 2",
+		},
+		{
+			Name:     "yaml stream",
+			Content:  "kind: Service\n---\nkind: Deployment\n",
+			Format:   format.YAML,
+			Options:  format.Options{YAMLStream: true},
+			Expected: "[\n   {\n      \"kind\": \"Service\"\n   },\n   {\n      \"kind\": \"Deployment\"\n   }\n]\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			output, err := format.Decode(tc.Content, tc.Format, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	content := `{"name": "api", "port": 1337, "ratio": 0.5, "tls": true, "db": {"hosts": ["a", "b"], "options": {"timeout": 30}}, "servers": [{"name": "a", "port": 1}, {"name": "b", "tags": []}], "mixed": [1, "a", [true]]}`
+
+	encoded, err := format.Convert(content, format.TOML, format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := format.Decode(encoded, format.TOML, format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected, actual interface{}
+	if err := json.Unmarshal([]byte(content), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(decoded), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("invalid round trip\nexpected:\n%v\nactual:\n%v\nTOML:\n%s\n", expected, actual, encoded)
+	}
+}
+
+func TestParseIndent(t *testing.T) {
+	tcs := []struct {
+		Value           string
+		ExpectedIndent  string
+		ExpectedCompact bool
+		ExpectedError   string
+	}{
+		{Value: "2", ExpectedIndent: "  "},
+		{Value: `\t`, ExpectedIndent: "\t"},
+		{Value: "0", ExpectedCompact: true},
+		{Value: "-1", ExpectedError: `invalid indent '-1': expected a number of spaces or '\t'`},
+		{Value: "two", ExpectedError: `invalid indent 'two': expected a number of spaces or '\t'`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Value, func(t *testing.T) {
+			indent, compact, err := format.ParseIndent(tc.Value)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.ExpectedIndent != indent || tc.ExpectedCompact != compact {
+				t.Fatalf("invalid indent\nexpected:\n%q %v\nactual:\n%q %v\n", tc.ExpectedIndent, tc.ExpectedCompact, indent, compact)
+			}
+		})
+	}
+}
+
+func TestForPath(t *testing.T) {
+	tcs := []struct {
+		Path     string
+		Expected format.Format
+	}{
+		{Path: "config.json", Expected: format.JSON},
+		{Path: "/etc/app/config.yaml", Expected: format.YAML},
+		{Path: "config.YML", Expected: format.YAML},
+		{Path: "config.toml", Expected: format.TOML},
+		{Path: "app.env", Expected: format.Env},
+		{Path: "config.conf", Expected: format.JSON},
+		{Path: "-", Expected: format.JSON},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Path, func(t *testing.T) {
+			if actual := format.ForPath(tc.Path); tc.Expected != actual {
+				t.Fatalf("invalid format\nexpected:\n%s\nactual:\n%s\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HeaderStyle represents the syntax of the header added to a generated content
+type HeaderStyle string
+
+const (
+	// HeaderAuto picks the style matching the format: HeaderHash for YAML, TOML and Env, no header
+	// for JSON and the contents which aren't encoded in any format. It's the default behavior
+	HeaderAuto HeaderStyle = "auto"
+	// HeaderHash writes each line of the header as a `# ` comment
+	HeaderHash HeaderStyle = "hash"
+	// HeaderSlash writes each line of the header as a `// ` comment, e.g. for JSON with comments
+	HeaderSlash HeaderStyle = "slash"
+	// HeaderKey adds a `"_generated": true` key at the top of a JSON object, as JSON has no
+	// comments. The header text isn't written
+	HeaderKey HeaderStyle = "key"
+	// HeaderNone doesn't add any header
+	HeaderNone HeaderStyle = "none"
+)
+
+// HeaderKeyName is the key added by HeaderKey
+const HeaderKeyName = "_generated"
+
+// ParseHeaderStyle returns the HeaderStyle matching the given name
+func ParseHeaderStyle(name string) (HeaderStyle, error) {
+	switch style := HeaderStyle(name); style {
+	case HeaderAuto, HeaderHash, HeaderSlash, HeaderKey, HeaderNone:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unsupported header style '%s'", name)
+	}
+}
+
+// AddHeader returns the content encoded in the format preceded by the header text, one comment
+// per line in the given style
+func AddHeader(content string, text string, f Format, style HeaderStyle) (string, error) {
+	if style == HeaderAuto {
+		style = HeaderNone
+		if f == YAML || f == TOML || f == Env {
+			style = HeaderHash
+		}
+	}
+
+	switch style {
+	case HeaderHash:
+		return comment(text, "#") + content, nil
+	case HeaderSlash:
+		return comment(text, "//") + content, nil
+	case HeaderKey:
+		return addHeaderKey(content)
+	default:
+		return content, nil
+	}
+}
+
+// comment writes each line of the text as a comment starting with the prefix
+func comment(text string, prefix string) string {
+	var buf strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		buf.WriteString(strings.TrimRight(prefix+" "+line, " ") + "\n")
+	}
+
+	return buf.String()
+}
+
+// addHeaderKey inserts the HeaderKeyName key before the first key of the JSON object, following
+// the indentation of the content
+func addHeaderKey(content string) (string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &object); err != nil || object == nil {
+		return "", fmt.Errorf("the key header style expects a JSON object")
+	}
+
+	if _, found := object[HeaderKeyName]; found {
+		return "", fmt.Errorf("the JSON object already has a '%s' key", HeaderKeyName)
+	}
+
+	start := strings.IndexByte(content, '{') + 1
+	body := content[start:]
+	space := body[:len(body)-len(strings.TrimLeft(body, " \t\r\n"))]
+
+	if len(object) == 0 {
+		return content[:start] + space + `"` + HeaderKeyName + `": true` + body, nil
+	}
+
+	if space == "" {
+		return content[:start] + `"` + HeaderKeyName + `":true,` + body, nil
+	}
+
+	return content[:start] + space + `"` + HeaderKeyName + `": true,` + body, nil
+}
@@ -0,0 +1,53 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+)
+
+func TestAddHeader(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Text          string
+		Format        format.Format
+		Style         format.HeaderStyle
+		Expected      string
+		ExpectedError string
+	}{
+		{Name: "auto yaml", Content: "port: 80\n", Text: "Generated", Format: format.YAML, Style: format.HeaderAuto, Expected: "# Generated\nport: 80\n"},
+		{Name: "auto env", Content: "PORT=80\n", Text: "Generated", Format: format.Env, Style: format.HeaderAuto, Expected: "# Generated\nPORT=80\n"},
+		{Name: "auto json", Content: "{\"port\": 80}\n", Text: "Generated", Format: format.JSON, Style: format.HeaderAuto, Expected: "{\"port\": 80}\n"},
+		{Name: "auto text", Content: "port 80\n", Text: "Generated", Format: "text", Style: format.HeaderAuto, Expected: "port 80\n"},
+		{Name: "hash lines", Content: "port 80\n", Text: "Generated\n\ndo not edit\n", Format: "text", Style: format.HeaderHash, Expected: "# Generated\n#\n# do not edit\nport 80\n"},
+		{Name: "slash", Content: "{}\n", Text: "Generated", Format: format.JSON, Style: format.HeaderSlash, Expected: "// Generated\n{}\n"},
+		{Name: "none", Content: "port: 80\n", Text: "Generated", Format: format.YAML, Style: format.HeaderNone, Expected: "port: 80\n"},
+		{Name: "key indented", Content: "{\n   \"port\": 80\n}\n", Format: format.JSON, Style: format.HeaderKey, Expected: "{\n   \"_generated\": true,\n   \"port\": 80\n}\n"},
+		{Name: "key compact", Content: "{\"port\":80}\n", Format: format.JSON, Style: format.HeaderKey, Expected: "{\"_generated\":true,\"port\":80}\n"},
+		{Name: "key empty object", Content: "{ }\n", Format: format.JSON, Style: format.HeaderKey, Expected: "{ \"_generated\": true }\n"},
+		{Name: "key not an object", Content: "[1]\n", Format: format.JSON, Style: format.HeaderKey, ExpectedError: "the key header style expects a JSON object"},
+		{Name: "key defined", Content: "{\"_generated\": false}\n", Format: format.JSON, Style: format.HeaderKey, ExpectedError: "the JSON object already has a '_generated' key"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := format.AddHeader(tc.Content, tc.Text, tc.Format, tc.Style)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.Expected != actual {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
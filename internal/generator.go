@@ -0,0 +1,947 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/schema"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+// PrefixSeparator joins the prefix of a volume and the name of each of its variables, e.g. `DB`
+// and `DATABASE_URL` give `DB_DATABASE_URL`
+const PrefixSeparator = "_"
+
+// Options represents the settings used to generate the content
+type Options struct {
+	// Volume defines how the volumes are read
+	Volume volume.Options
+	// AllowEmptyGlob ignores the volume patterns which don't match any path instead of failing. The
+	// volume paths containing glob metacharacters are expanded with volume.ExpandGlobs
+	AllowEmptyGlob bool
+	// WaitFor, when positive, waits for at most this duration for each volume path, code volume and
+	// group to exist and not be empty before reading it, see volume.Wait
+	WaitFor time.Duration
+	// Conflict defines what to do when several volumes define the same variable
+	Conflict variable.Conflict
+	// Prefixes maps volume paths, after the expansion of the patterns, and code volumes to the
+	// prefix added to the names of their variables with PrefixSeparator, so volumes with the same
+	// file names can be loaded together. The prefixed variables are checked for conflicts. A path
+	// which isn't a volume nor a code volume is an error
+	Prefixes map[string]string
+	// Layers are volumes merged in order before all the other sources, each layer replacing the
+	// variables of the previous ones without any conflict, e.g. a base folder then an environment
+	// one. The merged variables are then loaded like the ones of a volume, before the volumes and
+	// with the same conflict detection
+	Layers []string
+	// Variables are loaded after the volumes, with the same conflict detection, e.g. the entries of
+	// an archive
+	Variables []variable.Variable
+	// CodeVolumes are volumes whose files are loaded as code evaluated by the interpreter. It
+	// requires an interpreter implementing interpreter.CodeInterpreter
+	CodeVolumes []string
+	// JSONVars are JSON files whose top-level keys are loaded as variables, like the files of the
+	// volumes and with the same conflict detection. Non-string values are code variables when the
+	// interpreter implements interpreter.CodeInterpreter, JSON encoded strings otherwise
+	JSONVars []string
+	// JSONVarsInput is read like a JSONVars file after them when set, e.g. STDIN. Its variables
+	// are reported as coming from JSONVarsInputName
+	JSONVarsInput io.Reader
+	// JSONVarsInputName is the source of the JSONVarsInput variables. Defaults to "STDIN"
+	JSONVarsInputName string
+	// DownwardFiles are Kubernetes Downward API labels or annotations files whose lines are loaded
+	// as variables named after the label or annotation, after the JSON variables and with the same
+	// conflict detection. See variable.ParseDownward
+	DownwardFiles []string
+	// PropertiesFiles are Java properties files whose keys are loaded as variables, after the
+	// Downward API files and with the same conflict detection. See variable.ParseProperties
+	PropertiesFiles []string
+	// Groups maps variable names to volumes whose files are loaded as a single object variable, keyed
+	// by variable name. The object is a code variable when the interpreter implements
+	// interpreter.CodeInterpreter, a JSON encoded string otherwise. The group variables are checked
+	// for conflicts with the volume variables
+	Groups map[string]string
+	// EnvFiles are dotenv files loaded as variables as well. Volume variables take precedence over
+	// them and the first file defining a variable wins
+	EnvFiles []string
+	// Env loads the environment variables as well. Volume and env file variables take precedence
+	// over them
+	Env bool
+	// EnvPrefix restricts the loaded environment variables to the ones starting with the prefix
+	EnvPrefix string
+	// Overrides are loaded after all the other sources and replace the variables they define,
+	// the metadata ones included and whatever the conflict strategy, e.g. the values set on the
+	// command line. The last override of a variable wins
+	Overrides []variable.Variable
+	// InjectMetadata loads the variable.GeneratedAtName and variable.HostnameName variables along
+	// with the other sources. The generation time is the current time unless the SOURCE_DATE_EPOCH
+	// environment variable pins it. Another source defining one of them is an error, whatever the
+	// conflict strategy
+	InjectMetadata bool
+	// InterpolateVars replaces the `${NAME}` references in the values of the loaded variables by the
+	// value of the NAME variable, see variable.Set.Interpolate
+	InterpolateVars bool
+	// Required lists the variables which must be defined by one of the sources
+	Required []string
+	// Overlays are templates evaluated after the input with the same variables. Their objects are
+	// deep merged into the one of the input, in order, so the last overlay wins: the keys of nested
+	// objects are merged and any other value, arrays included, is replaced. The input and the
+	// overlays must all produce JSON objects
+	Overlays []io.Reader
+	// Schema validates the JSON content produced by the interpreter, before it's encoded
+	Schema *schema.Schema
+	// Format defines the encoding of the generated content. Defaults to format.JSON. Generate keeps
+	// the content as produced by the interpreter with format.Auto, leaving the choice to the caller
+	Format format.Format
+	// FormatOptions defines how the generated content is encoded
+	FormatOptions format.Options
+	// ParseOutput parses the content produced by the interpreter as Format instead of JSON before
+	// encoding it, for the text interpreters (e.g. plain) rendering a JSON or YAML document. A
+	// syntax error, like an unquoted value breaking the YAML, fails the generation. GenerateMulti
+	// doesn't support it
+	ParseOutput bool
+	// ReformatText parses the content produced by the interpreter as Format when it's format.YAML
+	// or format.TOML and the content isn't JSON, like ParseOutput, so the YAML or TOML document
+	// rendered by a text interpreter (e.g. plain) is encoded canonically. A JSON content is
+	// converted as usual
+	ReformatText bool
+	// Timeout bounds the evaluation of the template by the interpreter, there's no limit when 0. As
+	// the interpreters can't be interrupted, an evaluation running out of time is abandoned and the
+	// interpreter mustn't be used anymore
+	Timeout time.Duration
+	// Logf, when set, reports the scanned sources and the names of the loaded variables. Variable
+	// values are never logged as they are usually secrets
+	Logf func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// Result represents the generated content along with details about its generation
+type Result struct {
+	// Content is the generated content, encoded in the requested format
+	Content string
+	// UsedVars are the names of the loaded variables referenced by the template, sorted
+	// alphabetically. It's nil when the interpreter doesn't implement
+	// interpreter.TrackingInterpreter
+	UsedVars []string
+	// UnusedVars are the names of the loaded variables the template doesn't reference, sorted
+	// alphabetically. It's nil when the interpreter doesn't implement
+	// interpreter.TrackingInterpreter
+	UnusedVars []string
+	// Format is the format of the content: the requested one, or "text" when JSON is requested
+	// but the interpreter produced something else (e.g. a plain template)
+	Format string
+	// Variables are the loaded variables sorted by name, with the values received by the
+	// interpreter
+	Variables []variable.Variable
+}
+
+// TextFormat is the Result.Format of a content which isn't encoded in any format
+const TextFormat = "text"
+
+// Generate reads all the volumes to collect the variables and execute the template
+func Generate(runtime interpreter.Interpreter, input io.Reader, volumes []string, opts Options) (string, error) {
+	result, err := GenerateResult(runtime, input, volumes, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Content, nil
+}
+
+// GenerateResult reads all the volumes to collect the variables and execute the template, like
+// Generate, and reports which variables are used by the template
+func GenerateResult(runtime interpreter.Interpreter, input io.Reader, volumes []string, opts Options) (Result, error) {
+	variables := variable.NewSet(opts.Conflict)
+
+	content, used, err := evaluate(runtime, input, volumes, variables, opts)
+	if err != nil {
+		return Result{}, redactError(err, variables)
+	}
+
+	if opts.ParseOutput || (opts.ReformatText && isTextFormat(opts.Format) && !json.Valid([]byte(content))) {
+		content, err = format.Decode(content, opts.Format, opts.FormatOptions)
+		if err != nil {
+			return Result{}, redactError(ValidationError{Err: fmt.Errorf("can't parse generated content: %v", err)}, variables)
+		}
+	}
+
+	content, err = format.Convert(content, opts.Format, opts.FormatOptions)
+	if err != nil {
+		return Result{}, redactError(fmt.Errorf("can't format content: %v", err), variables)
+	}
+
+	result := Result{Content: content, Format: string(opts.Format), Variables: variables.List()}
+	if opts.Format == "" || opts.Format == format.JSON || opts.Format == format.Auto {
+		result.Format = string(format.JSON)
+		if !json.Valid([]byte(content)) {
+			result.Format = TextFormat
+		}
+	}
+
+	if _, ok := runtime.(interpreter.TrackingInterpreter); ok {
+		result.UsedVars, result.UnusedVars = splitUsedVars(variables, used)
+	}
+
+	return result, nil
+}
+
+// GenerateTo reads all the volumes to collect the variables and execute the template like
+// GenerateResult, writing the content to the writer. When CanStream allows it, the content is
+// written as the template is evaluated instead of being held in memory: the Content of the
+// result is then empty and its Format is TextFormat, and the content written before an error is
+// incomplete. Otherwise, the content is generated by GenerateResult then written
+func GenerateTo(runtime interpreter.Interpreter, input io.Reader, volumes []string, w io.Writer, opts Options) (Result, error) {
+	if !CanStream(runtime, opts) {
+		result, err := GenerateResult(runtime, input, volumes, opts)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if _, err := io.WriteString(w, result.Content); err != nil {
+			return Result{}, fmt.Errorf("can't write content: %v", err)
+		}
+
+		return result, nil
+	}
+
+	variables := variable.NewSet(opts.Conflict)
+
+	used, err := streamTemplate(runtime.(interpreter.StreamingInterpreter), input, volumes, w, variables, opts)
+	if err != nil {
+		return Result{}, redactError(err, variables)
+	}
+
+	result := Result{Format: TextFormat, Variables: variables.List()}
+	if _, ok := runtime.(interpreter.TrackingInterpreter); ok {
+		result.UsedVars, result.UnusedVars = splitUsedVars(variables, used)
+	}
+
+	return result, nil
+}
+
+// CanStream returns whether GenerateTo writes the content as the template is evaluated: the
+// interpreter must implement interpreter.StreamingInterpreter and the content must be written as
+// produced, without any overlay, schema, timeout, parsing nor formatting
+func CanStream(runtime interpreter.Interpreter, opts Options) bool {
+	if _, ok := runtime.(interpreter.StreamingInterpreter); !ok {
+		return false
+	}
+
+	if len(opts.Overlays) > 0 || opts.Schema != nil || opts.Timeout > 0 || opts.ParseOutput {
+		return false
+	}
+
+	if opts.FormatOptions.Indent != "" || opts.FormatOptions.Compact {
+		return false
+	}
+
+	return opts.Format == "" || opts.Format == format.JSON || opts.Format == format.Auto
+}
+
+func streamTemplate(runtime interpreter.StreamingInterpreter, input io.Reader, volumes []string, w io.Writer, variables *variable.Set, opts Options) ([]string, error) {
+	if err := loadVariables(runtime, volumes, variables, opts); err != nil {
+		return nil, err
+	}
+
+	if err := addVariables(runtime, variables, opts); err != nil {
+		return nil, err
+	}
+
+	tpl, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("can't read template: %v", err)
+	}
+
+	if err := runtime.EvaluateTo(w, string(tpl)); err != nil {
+		return nil, fmt.Errorf("can't evaluate template: %v", err)
+	}
+
+	var used []string
+	if tracking, ok := runtime.(interpreter.TrackingInterpreter); ok {
+		used = tracking.UsedVars()
+	}
+
+	return used, nil
+}
+
+// isTextFormat returns true for the formats whose documents can be parsed back by ReformatText
+func isTextFormat(f format.Format) bool {
+	return f == format.YAML || f == format.TOML
+}
+
+// LoadVariables reads all the sources of variables like Generate would, without evaluating any
+// template, and returns the variables the interpreter would receive sorted by name
+func LoadVariables(runtime interpreter.Interpreter, volumes []string, opts Options) ([]variable.Variable, error) {
+	variables := variable.NewSet(opts.Conflict)
+
+	if err := loadVariables(runtime, volumes, variables, opts); err != nil {
+		return nil, redactError(err, variables)
+	}
+
+	return variables.List(), nil
+}
+
+// GenerateMulti reads all the volumes to collect the variables and execute the template which must
+// produce an object mapping file names to their content. String values are returned as is, other
+// values are encoded using the format options, format.Auto picking the format of each file from
+// its name. File names are relative paths using '/' as separator
+// and can't go outside of their parent folder
+func GenerateMulti(runtime interpreter.Interpreter, input io.Reader, volumes []string, opts Options) (map[string]string, error) {
+	variables := variable.NewSet(opts.Conflict)
+
+	files, err := generateMulti(runtime, input, volumes, variables, opts)
+	if err != nil {
+		return nil, redactError(err, variables)
+	}
+
+	return files, nil
+}
+
+func generateMulti(runtime interpreter.Interpreter, input io.Reader, volumes []string, variables *variable.Set, opts Options) (map[string]string, error) {
+	content, _, err := evaluate(runtime, input, volumes, variables, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &values); err != nil || values == nil {
+		return nil, fmt.Errorf("can't split content: multi-file output expects an object mapping file names to contents")
+	}
+
+	files := make(map[string]string, len(values))
+	for name, value := range values {
+		if err := checkFileName(name); err != nil {
+			return nil, fmt.Errorf("can't split content: %v", err)
+		}
+
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			files[name] = text
+			continue
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, value, "", "   "); err != nil {
+			return nil, fmt.Errorf("can't format content of '%s': %v", name, err)
+		}
+
+		fileFormat := opts.Format
+		if fileFormat == format.Auto {
+			fileFormat = format.ForPath(name)
+		}
+
+		fileContent, err := format.Convert(indented.String()+"\n", fileFormat, opts.FormatOptions)
+		if err != nil {
+			return nil, fmt.Errorf("can't format content of '%s': %v", name, err)
+		}
+
+		files[name] = fileContent
+	}
+
+	return files, nil
+}
+
+// GenerateSplit reads all the volumes to collect the variables and execute the template which must
+// produce an object. Each top-level value is encoded using the format options and returned as a
+// file named after its key with the extension of the format, e.g. 'database.yaml'. The characters
+// of the key which aren't letters, digits, '-', '_' or '.', as well as a leading '.', are replaced
+// by '_', and two keys written to the same file are an error
+func GenerateSplit(runtime interpreter.Interpreter, input io.Reader, volumes []string, opts Options) (map[string]string, error) {
+	variables := variable.NewSet(opts.Conflict)
+
+	files, err := generateSplit(runtime, input, volumes, variables, opts)
+	if err != nil {
+		return nil, redactError(err, variables)
+	}
+
+	return files, nil
+}
+
+func generateSplit(runtime interpreter.Interpreter, input io.Reader, volumes []string, variables *variable.Set, opts Options) (map[string]string, error) {
+	content, _, err := evaluate(runtime, input, volumes, variables, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &values); err != nil || values == nil {
+		return nil, fmt.Errorf("can't split content: expected an object")
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fileFormat := opts.Format
+	if fileFormat == "" || fileFormat == format.Auto {
+		fileFormat = format.JSON
+	}
+
+	files := make(map[string]string, len(values))
+	keysByName := make(map[string]string, len(values))
+	for _, key := range keys {
+		name := splitFileName(key) + format.Extension(fileFormat)
+		if existing, found := keysByName[name]; found {
+			return nil, fmt.Errorf("can't split content: keys '%s' and '%s' are both written to '%s'", existing, key, name)
+		}
+		keysByName[name] = key
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, values[key], "", "   "); err != nil {
+			return nil, fmt.Errorf("can't format content of '%s': %v", key, err)
+		}
+
+		fileContent, err := format.Convert(indented.String()+"\n", fileFormat, opts.FormatOptions)
+		if err != nil {
+			return nil, fmt.Errorf("can't format content of '%s': %v", key, err)
+		}
+
+		files[name] = fileContent
+	}
+
+	return files, nil
+}
+
+// splitFileName returns a name safe to use as a file name for the key
+func splitFileName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, key)
+
+	// a leading dot would make a hidden file, or the '.' and '..' folders
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = "_" + strings.TrimPrefix(name, ".")
+	}
+
+	return name
+}
+
+// splitUsedVars returns the names of the loaded variables referenced by the template and the names
+// of the other ones, sorted like the set
+func splitUsedVars(variables *variable.Set, referenced []string) ([]string, []string) {
+	isReferenced := make(map[string]bool, len(referenced))
+	for _, name := range referenced {
+		isReferenced[name] = true
+	}
+
+	used, unused := []string{}, []string{}
+	for _, v := range variables.List() {
+		if isReferenced[v.Name] {
+			used = append(used, v.Name)
+		} else {
+			unused = append(unused, v.Name)
+		}
+	}
+
+	return used, unused
+}
+
+// loadGroup loads the files of the volume as a single variable holding a JSON object
+func loadGroup(name string, root string, opts volume.Options, isCode bool) (variable.Variable, error) {
+	rootVariables, err := volume.LoadAllVariables(root, opts)
+	if err != nil {
+		return variable.Variable{}, err
+	}
+
+	values := make(map[string]string, len(rootVariables))
+	for _, v := range rootVariables {
+		values[v.Name] = v.Value
+	}
+
+	content, err := json.Marshal(values)
+	if err != nil {
+		return variable.Variable{}, fmt.Errorf("can't encode variables as JSON: %v", err)
+	}
+
+	return variable.Variable{Name: name, Value: string(content), Source: root, Code: isCode}, nil
+}
+
+// evaluateWithTimeout evaluates the template in its own goroutine so it can be abandoned when it
+// takes longer than the timeout, e.g. because of an infinite recursion
+func evaluateWithTimeout(runtime interpreter.Interpreter, tpl string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return runtime.Evaluate(tpl)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type evaluation struct {
+		content string
+		err     error
+	}
+
+	done := make(chan evaluation, 1)
+	go func() {
+		content, err := runtime.Evaluate(tpl)
+		done <- evaluation{content: content, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.content, result.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("evaluation timed out after %s", timeout)
+	}
+}
+
+// loadMetadata adds the metadata variables to the set, failing when a source already defines them
+func loadMetadata(variables *variable.Set) error {
+	generatedAt, err := variable.GenerationTime(os.Getenv("SOURCE_DATE_EPOCH"), time.Now())
+	if err != nil {
+		return fmt.Errorf("can't inject metadata: %v", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("can't inject metadata: can't read hostname: %v", err)
+	}
+
+	for _, v := range variable.Metadata(generatedAt, hostname) {
+		if existing, found := variables.Get(v.Name); found {
+			return fmt.Errorf("can't inject metadata: variable '%s' is reserved but defined by '%s'", v.Name, existing.Source)
+		}
+
+		variables.AddFallback(v)
+	}
+
+	return nil
+}
+
+func checkRequired(variables *variable.Set, required []string) error {
+	var missing []string
+	reported := make(map[string]bool)
+
+	for _, name := range required {
+		if _, found := variables.Get(name); found || reported[name] {
+			continue
+		}
+
+		reported[name] = true
+		missing = append(missing, fmt.Sprintf("'%s'", name))
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required variables: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func checkFileName(name string) error {
+	cleaned := path.Clean(name)
+	if name == "" || path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("invalid file name '%s': expected a relative path inside the output folder", name)
+	}
+
+	return nil
+}
+
+// waitForVolume waits for the volume path to be present when opts.WaitFor is set
+func waitForVolume(root string, opts Options) error {
+	if opts.WaitFor <= 0 {
+		return nil
+	}
+
+	opts.logf("waiting for volume '%s'", root)
+
+	return volume.Wait(root, opts.WaitFor)
+}
+
+// loadLayers merges the variables of the layers, the last layer defining a variable wins
+func loadLayers(opts Options) ([]variable.Variable, error) {
+	layers := variable.NewSet(variable.ConflictLast)
+
+	for _, root := range opts.Layers {
+		if err := waitForVolume(root, opts); err != nil {
+			return nil, fmt.Errorf("can't read layer variables '%s': %v", root, err)
+		}
+
+		opts.logf("scanning layer '%s'", root)
+
+		rootVariables, err := volume.LoadAllVariables(root, opts.Volume)
+		if err != nil {
+			return nil, fmt.Errorf("can't read layer variables '%s': %v", root, err)
+		}
+
+		for _, v := range rootVariables {
+			if existing, found := layers.Get(v.Name); found {
+				opts.logf("layer '%s' overrides variable '%s' of '%s'", root, v.Name, existing.Source)
+			}
+		}
+
+		layers.Override(rootVariables...)
+	}
+
+	return layers.List(), nil
+}
+
+// prefixVariables adds the prefix of the volume, if any, to the names of its variables
+func prefixVariables(variables []variable.Variable, root string, opts Options, prefixed map[string]bool) {
+	for path, prefix := range opts.Prefixes {
+		if filepath.Clean(path) != filepath.Clean(root) {
+			continue
+		}
+
+		opts.logf("prefixing the variables of '%s' with '%s%s'", root, prefix, PrefixSeparator)
+
+		for i := range variables {
+			variables[i].Name = prefix + PrefixSeparator + variables[i].Name
+		}
+
+		prefixed[path] = true
+
+		return
+	}
+}
+
+// checkPrefixes ensures each prefix has been given to a volume
+func checkPrefixes(opts Options, prefixed map[string]bool) error {
+	paths := make([]string, 0, len(opts.Prefixes))
+	for path := range opts.Prefixes {
+		if !prefixed[path] {
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	sort.Strings(paths)
+
+	return fmt.Errorf("can't prefix the variables of '%s': not a volume path", strings.Join(paths, "', '"))
+}
+
+// loadVariables collects the variables of all the sources into the set, by order of precedence
+func loadVariables(runtime interpreter.Interpreter, volumes []string, variables *variable.Set, opts Options) error {
+	volumes, err := volume.ExpandGlobs(volumes, opts.AllowEmptyGlob)
+	if err != nil {
+		return err
+	}
+
+	if len(opts.Layers) > 0 {
+		layerVariables, err := loadLayers(opts)
+		if err != nil {
+			return err
+		}
+
+		if err := variables.Add(layerVariables...); err != nil {
+			return fmt.Errorf("can't load layer variables: %v", err)
+		}
+	}
+
+	prefixed := make(map[string]bool, len(opts.Prefixes))
+
+	for _, root := range volumes {
+		if err := waitForVolume(root, opts); err != nil {
+			return fmt.Errorf("can't read volume variables '%s': %v", root, err)
+		}
+
+		opts.logf("scanning volume '%s'", root)
+
+		rootVariables, err := volume.LoadAllVariables(root, opts.Volume)
+		if err != nil {
+			return fmt.Errorf("can't read volume variables '%s': %v", root, err)
+		}
+
+		prefixVariables(rootVariables, root, opts, prefixed)
+
+		if err := variables.Add(rootVariables...); err != nil {
+			return fmt.Errorf("can't load volume variables '%s': %v", root, err)
+		}
+	}
+
+	if err := variables.Add(opts.Variables...); err != nil {
+		return fmt.Errorf("can't load variables: %v", err)
+	}
+
+	for _, root := range opts.CodeVolumes {
+		if err := waitForVolume(root, opts); err != nil {
+			return fmt.Errorf("can't read code volume variables '%s': %v", root, err)
+		}
+
+		opts.logf("scanning code volume '%s'", root)
+
+		rootVariables, err := volume.LoadAllVariables(root, opts.Volume)
+		if err != nil {
+			return fmt.Errorf("can't read code volume variables '%s': %v", root, err)
+		}
+
+		for i := range rootVariables {
+			rootVariables[i].Code = true
+		}
+
+		prefixVariables(rootVariables, root, opts, prefixed)
+
+		if err := variables.Add(rootVariables...); err != nil {
+			return fmt.Errorf("can't load code volume variables '%s': %v", root, err)
+		}
+	}
+
+	if err := checkPrefixes(opts, prefixed); err != nil {
+		return err
+	}
+
+	_, isCodeRuntime := runtime.(interpreter.CodeInterpreter)
+	for _, path := range opts.JSONVars {
+		opts.logf("reading JSON variables '%s'", path)
+
+		fileVariables, err := variable.LoadJSONFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read JSON variables '%s': %v", path, err)
+		}
+
+		for i := range fileVariables {
+			fileVariables[i].Code = fileVariables[i].Code && isCodeRuntime
+		}
+
+		if err := variables.Add(fileVariables...); err != nil {
+			return fmt.Errorf("can't load JSON variables '%s': %v", path, err)
+		}
+	}
+
+	if opts.JSONVarsInput != nil {
+		name := opts.JSONVarsInputName
+		if name == "" {
+			name = "STDIN"
+		}
+
+		opts.logf("reading JSON variables '%s'", name)
+
+		content, err := ioutil.ReadAll(opts.JSONVarsInput)
+		if err != nil {
+			return fmt.Errorf("can't read JSON variables '%s': %v", name, err)
+		}
+
+		inputVariables, err := variable.ParseJSON(content, name)
+		if err != nil {
+			return fmt.Errorf("can't read JSON variables '%s': %v", name, err)
+		}
+
+		for i := range inputVariables {
+			inputVariables[i].Code = inputVariables[i].Code && isCodeRuntime
+		}
+
+		if err := variables.Add(inputVariables...); err != nil {
+			return fmt.Errorf("can't load JSON variables '%s': %v", name, err)
+		}
+	}
+
+	for _, path := range opts.DownwardFiles {
+		opts.logf("reading Downward API file '%s'", path)
+
+		fileVariables, err := variable.LoadDownwardFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read Downward API file '%s': %v", path, err)
+		}
+
+		if err := variables.Add(fileVariables...); err != nil {
+			return fmt.Errorf("can't load Downward API file '%s': %v", path, err)
+		}
+	}
+
+	for _, path := range opts.PropertiesFiles {
+		opts.logf("reading properties file '%s'", path)
+
+		fileVariables, err := variable.LoadPropertiesFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read properties file '%s': %v", path, err)
+		}
+
+		if err := variables.Add(fileVariables...); err != nil {
+			return fmt.Errorf("can't load properties file '%s': %v", path, err)
+		}
+	}
+
+	groupNames := make([]string, 0, len(opts.Groups))
+	for name := range opts.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		root := opts.Groups[name]
+		if err := waitForVolume(root, opts); err != nil {
+			return fmt.Errorf("can't read group variables '%s': %v", root, err)
+		}
+
+		opts.logf("scanning group volume '%s' as '%s'", root, name)
+
+		group, err := loadGroup(name, root, opts.Volume, isCodeRuntime)
+		if err != nil {
+			return fmt.Errorf("can't read group variables '%s': %v", root, err)
+		}
+
+		if err := variables.Add(group); err != nil {
+			return fmt.Errorf("can't load group variables '%s': %v", root, err)
+		}
+	}
+
+	for _, path := range opts.EnvFiles {
+		opts.logf("reading env file '%s'", path)
+
+		fileVariables, err := variable.LoadEnvFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read env file '%s': %v", path, err)
+		}
+
+		variables.AddFallback(fileVariables...)
+	}
+
+	if opts.Env {
+		opts.logf("reading environment variables")
+
+		variables.AddFallback(variable.FromEnviron(os.Environ(), opts.EnvPrefix)...)
+	}
+
+	if opts.InjectMetadata {
+		if err := loadMetadata(variables); err != nil {
+			return err
+		}
+	}
+
+	variables.Override(opts.Overrides...)
+
+	if opts.InterpolateVars {
+		if err := variables.Interpolate(); err != nil {
+			return err
+		}
+	}
+
+	if err := checkRequired(variables, opts.Required); err != nil {
+		return ValidationError{Err: err}
+	}
+
+	return nil
+}
+
+// addData stores a JSON variable as data when the interpreter supports it, as its JSON encoding in
+// a string otherwise
+func addData(runtime interpreter.Interpreter, v variable.Variable) error {
+	dataRuntime, ok := runtime.(interpreter.DataInterpreter)
+	if !ok {
+		runtime.AddVar(v.Name, v.Value)
+		return nil
+	}
+
+	if err := dataRuntime.AddData(v.Name, v.Value); err != nil {
+		return fmt.Errorf("can't load data variable '%s' from '%s': %v", v.Name, v.Source, err)
+	}
+
+	return nil
+}
+
+// evaluate loads the variables, evaluates the input and its overlays and returns the merged content
+// along with the names of the variables referenced by the templates, when the interpreter
+// implements interpreter.TrackingInterpreter
+func evaluate(runtime interpreter.Interpreter, input io.Reader, volumes []string, variables *variable.Set, opts Options) (string, []string, error) {
+	if err := loadVariables(runtime, volumes, variables, opts); err != nil {
+		return "", nil, err
+	}
+
+	if err := addVariables(runtime, variables, opts); err != nil {
+		return "", nil, err
+	}
+
+	content, used, err := evaluateTemplate(runtime, input, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for i, overlay := range opts.Overlays {
+		overlayContent, overlayUsed, err := evaluateTemplate(runtime, overlay, opts)
+		if err != nil {
+			return "", nil, fmt.Errorf("can't evaluate overlay %d: %v", i+1, err)
+		}
+
+		used = append(used, overlayUsed...)
+
+		content, err = mergeContents(content, overlayContent, i)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if opts.Schema != nil {
+		if err := opts.Schema.Validate(content); err != nil {
+			return "", nil, ValidationError{Err: err}
+		}
+	}
+
+	return content, used, nil
+}
+
+// addVariables stores the variables of the set in the interpreter
+func addVariables(runtime interpreter.Interpreter, variables *variable.Set, opts Options) error {
+	for _, v := range variables.List() {
+		opts.logf("loading variable '%s' from '%s'", v.Name, v.Source)
+
+		if !v.Code {
+			runtime.AddVar(v.Name, v.Value)
+			continue
+		}
+
+		codeRuntime, ok := runtime.(interpreter.CodeInterpreter)
+		if !ok && v.JSON {
+			if err := addData(runtime, v); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !ok {
+			return fmt.Errorf("can't load code variable '%s' from '%s': the interpreter doesn't support code variables", v.Name, v.Source)
+		}
+
+		if err := codeRuntime.AddCode(v.Name, v.Value); err != nil {
+			return fmt.Errorf("can't load code variable '%s' from '%s': %v", v.Name, v.Source, err)
+		}
+	}
+
+	return nil
+}
+
+func evaluateTemplate(runtime interpreter.Interpreter, input io.Reader, opts Options) (string, []string, error) {
+	tpl, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("can't read template: %v", err)
+	}
+
+	content, err := evaluateWithTimeout(runtime, string(tpl), opts.Timeout)
+	if err != nil {
+		return "", nil, fmt.Errorf("can't evaluate template: %v", err)
+	}
+
+	var used []string
+	if tracking, ok := runtime.(interpreter.TrackingInterpreter); ok {
+		used = tracking.UsedVars()
+	}
+
+	return content, used, nil
+}
@@ -0,0 +1,1472 @@
+package internal_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/format"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+func getRuntime(t *testing.T, name string) interpreter.Interpreter {
+	runtime, found := interpreter.Get(name)
+	if !found {
+		t.Fatalf("can't get interpreter")
+	}
+
+	return runtime
+}
+
+func openInput(t *testing.T, path string) io.Reader {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("can't open input file: %v", err)
+	}
+	return f
+}
+
+func readExpectedOutput(t *testing.T, path string) string {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("can't read expected output file: %v", err)
+	}
+	return string(s)
+}
+
+func TestValidTemplates(t *testing.T) {
+	tcs := []struct {
+		Name               string
+		RuntimeName        string
+		InputPath          string
+		Volumes            []string
+		ExpectedOutputPath string
+	}{
+		{
+			RuntimeName: "jsonnet",
+			InputPath:   "../cmd/cfgenerator/examples/jsonnet/config.jsonnet",
+			Volumes: []string{
+				"../cmd/cfgenerator/examples/jsonnet/volumes/config",
+				"../cmd/cfgenerator/examples/jsonnet/volumes/secrets",
+			},
+			ExpectedOutputPath: "../cmd/cfgenerator/examples/jsonnet/expected-config.json",
+		},
+		{
+			RuntimeName: "plain",
+			InputPath:   "../cmd/cfgenerator/examples/plain/config.conf.tpl",
+			Volumes: []string{
+				"../cmd/cfgenerator/examples/jsonnet/volumes/config",
+				"../cmd/cfgenerator/examples/jsonnet/volumes/secrets",
+			},
+			ExpectedOutputPath: "../cmd/cfgenerator/examples/plain/expected-config.conf",
+		},
+		{
+			RuntimeName: "starlark",
+			InputPath:   "../cmd/cfgenerator/examples/starlark/config.star",
+			Volumes: []string{
+				"../cmd/cfgenerator/examples/starlark/volumes/config",
+				"../cmd/cfgenerator/examples/starlark/volumes/secrets",
+			},
+			ExpectedOutputPath: "../cmd/cfgenerator/examples/starlark/expected-config.json",
+		},
+		{
+			RuntimeName: "cue",
+			InputPath:   "../cmd/cfgenerator/examples/cue/config.cue",
+			Volumes: []string{
+				"../cmd/cfgenerator/examples/cue/volumes/config",
+				"../cmd/cfgenerator/examples/cue/volumes/secrets",
+			},
+			ExpectedOutputPath: "../cmd/cfgenerator/examples/cue/expected-config.json",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.RuntimeName, func(t *testing.T) {
+			runtime := getRuntime(t, tc.RuntimeName)
+			input := openInput(t, tc.InputPath)
+			expectedOutput := readExpectedOutput(t, tc.ExpectedOutputPath)
+
+			output, err := internal.Generate(runtime, input, tc.Volumes, internal.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expectedOutput != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expectedOutput, output)
+			}
+		})
+	}
+
+}
+
+func setenv(t *testing.T, name string, value string) {
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatalf("can't set environment variable: %v", err)
+	}
+
+	t.Cleanup(func() { os.Unsetenv(name) })
+}
+
+func TestEnvPrecedence(t *testing.T) {
+	setenv(t, "API_PORT", "8080")
+	setenv(t, "CFGENERATOR_ENV", "production")
+
+	root := t.TempDir()
+	first := filepath.Join(root, "first.env")
+	if err := ioutil.WriteFile(first, []byte("API_PORT=9090\nCFGENERATOR_ENV=staging\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	second := filepath.Join(root, "second.env")
+	if err := ioutil.WriteFile(second, []byte("CFGENERATOR_ENV=development\nCFGENERATOR_REGION=eu\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name     string
+		Options  internal.Options
+		Template string
+		Expected string
+	}{
+		{
+			Name:     "volume wins over environment",
+			Options:  internal.Options{Env: true},
+			Template: "{{ .API_PORT }} {{ .CFGENERATOR_ENV }}",
+			Expected: "1337 production",
+		},
+		{
+			Name:     "prefix",
+			Options:  internal.Options{Env: true, EnvPrefix: "CFGENERATOR_"},
+			Template: "{{ .CFGENERATOR_ENV }} {{ .HOME }}",
+			Expected: "production <no value>",
+		},
+		{
+			Name:     "disabled",
+			Options:  internal.Options{},
+			Template: "{{ .CFGENERATOR_ENV }}",
+			Expected: "<no value>",
+		},
+		{
+			Name:     "volume wins over env file and env file over environment",
+			Options:  internal.Options{Env: true, EnvFiles: []string{first}},
+			Template: "{{ .API_PORT }} {{ .CFGENERATOR_ENV }}",
+			Expected: "1337 staging",
+		},
+		{
+			Name:     "first env file wins",
+			Options:  internal.Options{EnvFiles: []string{first, second}},
+			Template: "{{ .CFGENERATOR_ENV }} {{ .CFGENERATOR_REGION }}",
+			Expected: "staging eu",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "plain")
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+
+			output, err := internal.Generate(runtime, strings.NewReader(tc.Template), volumes, tc.Options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "BLOB"), []byte("\x00\x01 gzip\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	runtime := getRuntime(t, "jsonnet")
+	input := strings.NewReader(`std.native('decodeBase64')(std.extVar('BLOB')) == '\u0000\u0001 gzip\n'`)
+	opts := internal.Options{Volume: volume.Options{Binary: volume.BinaryBase64}}
+
+	output, err := internal.Generate(runtime, input, []string{root}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "true\n"; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+}
+
+func TestCodeVolumes(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Interpreter   string
+		Content       string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:        "valid",
+			Interpreter: "jsonnet",
+			Content:     `["a", "b"]`,
+			Expected:    "{\n   \"hosts\": [\n      \"a\",\n      \"b\"\n   ],\n   \"port\": \"1337\"\n}\n",
+		},
+		{
+			Name:          "malformed",
+			Interpreter:   "jsonnet",
+			Content:       `["a", `,
+			ExpectedError: "can't load code variable 'HOSTS' from '%s': invalid jsonnet code",
+		},
+		{
+			Name:          "unsupported interpreter",
+			Interpreter:   "plain",
+			Content:       `["a", "b"]`,
+			ExpectedError: "can't load code variable 'HOSTS' from '%s': the interpreter doesn't support code variables",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "HOSTS")
+			if err := ioutil.WriteFile(path, []byte(tc.Content), 0644); err != nil {
+				t.Fatalf("can't write file: %v", err)
+			}
+
+			runtime := getRuntime(t, tc.Interpreter)
+			input := strings.NewReader(`{ hosts: std.extVar('HOSTS'), port: std.extVar('API_PORT') }`)
+			opts := internal.Options{CodeVolumes: []string{root}}
+
+			output, err := internal.Generate(runtime, input, []string{"../cmd/cfgenerator/examples/plain/volumes/config"}, opts)
+			if tc.ExpectedError != "" {
+				expectedError := fmt.Sprintf(tc.ExpectedError, path)
+				if err == nil || !strings.HasPrefix(err.Error(), expectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestGenerateMulti(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Options       internal.Options
+		Expected      map[string]string
+		ExpectedError string
+	}{
+		{
+			Name:     "json",
+			Template: `{ "api.json": { port: std.extVar('API_PORT') }, "nested/motd.txt": 'hello ' + std.extVar('DATABASE_USERNAME') + '\n' }`,
+			Expected: map[string]string{
+				"api.json":        "{\n   \"port\": \"1337\"\n}\n",
+				"nested/motd.txt": "hello myapp\n",
+			},
+		},
+		{
+			Name:     "yaml",
+			Template: `{ "api.yaml": { port: std.extVar('API_PORT'), user: std.extVar('DATABASE_USERNAME') } }`,
+			Options:  internal.Options{Format: format.YAML},
+			Expected: map[string]string{
+				"api.yaml": "port: \"1337\"\nuser: myapp\n",
+			},
+		},
+		{
+			Name:          "not an object",
+			Template:      `[std.extVar('API_PORT')]`,
+			ExpectedError: "can't split content: multi-file output expects an object mapping file names to contents",
+		},
+		{
+			Name:          "outside of the folder",
+			Template:      `{ "../api.json": {} }`,
+			ExpectedError: "can't split content: invalid file name '../api.json': expected a relative path inside the output folder",
+		},
+		{
+			Name:          "absolute",
+			Template:      `{ "/etc/api.json": {} }`,
+			ExpectedError: "can't split content: invalid file name '/etc/api.json': expected a relative path inside the output folder",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "jsonnet")
+			input := strings.NewReader(tc.Template)
+
+			actual, err := internal.GenerateMulti(runtime, input, []string{"../cmd/cfgenerator/examples/plain/volumes/config"}, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid output\nexpected:\n'%v'\nactual:\n'%v'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestGenerateSplit(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Options       internal.Options
+		Expected      map[string]string
+		ExpectedError string
+	}{
+		{
+			Name:     "json",
+			Template: `{ api: { port: std.extVar('API_PORT') }, database: { username: std.extVar('DATABASE_USERNAME') }, motd: 'hello' }`,
+			Expected: map[string]string{
+				"api.json":      "{\n   \"port\": \"1337\"\n}\n",
+				"database.json": "{\n   \"username\": \"myapp\"\n}\n",
+				"motd.json":     "\"hello\"\n",
+			},
+		},
+		{
+			Name:     "yaml",
+			Template: `{ api: { port: std.extVar('API_PORT') } }`,
+			Options:  internal.Options{Format: format.YAML},
+			Expected: map[string]string{
+				"api.yaml": "port: \"1337\"\n",
+			},
+		},
+		{
+			Name:     "sanitized",
+			Template: `{ "../etc/passwd": {}, "..": {}, "": {}, "api v1.2": {} }`,
+			Expected: map[string]string{
+				"_.json":             "{}\n",
+				"_..json":            "{}\n",
+				"_._etc_passwd.json": "{}\n",
+				"api_v1.2.json":      "{}\n",
+			},
+		},
+		{
+			Name:          "collision",
+			Template:      `{ "api/v1": {}, "api v1": {} }`,
+			ExpectedError: "can't split content: keys 'api v1' and 'api/v1' are both written to 'api_v1.json'",
+		},
+		{
+			Name:          "not an object",
+			Template:      `[std.extVar('API_PORT')]`,
+			ExpectedError: "can't split content: expected an object",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "jsonnet")
+			input := strings.NewReader(tc.Template)
+
+			actual, err := internal.GenerateSplit(runtime, input, []string{"../cmd/cfgenerator/examples/plain/volumes/config"}, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid output\nexpected:\n'%v'\nactual:\n'%v'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestJSONVars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.json")
+	if err := ioutil.WriteFile(path, []byte(`{"HOST": "db.svc", "REPLICAS": {"min": 1}}`), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name        string
+		Interpreter string
+		Template    string
+		Expected    string
+	}{
+		{
+			Name:        "jsonnet",
+			Interpreter: "jsonnet",
+			Template:    `{ host: std.extVar('HOST'), min: std.extVar('REPLICAS').min }`,
+			Expected:    "{\n   \"host\": \"db.svc\",\n   \"min\": 1\n}\n",
+		},
+		{
+			Name:        "plain",
+			Interpreter: "plain",
+			Template:    `{{ .HOST }} {{ .REPLICAS }}`,
+			Expected:    `db.svc {"min":1}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+			opts := internal.Options{JSONVars: []string{path}}
+
+			output, err := internal.Generate(runtime, strings.NewReader(tc.Template), nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestJSONVarsInput(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Input         string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "variables",
+			Input:    `{"PASSWORD": "sssh!", "REPLICAS": {"min": 1}}`,
+			Expected: "{\n   \"min\": 1,\n   \"password\": \"sssh!\",\n   \"port\": \"1337\"\n}\n",
+		},
+		{
+			Name:          "conflict",
+			Input:         `{"API_PORT": "8080"}`,
+			ExpectedError: "can't load JSON variables 'STDIN': variable 'API_PORT' is defined by both",
+		},
+		{
+			Name:          "not an object",
+			Input:         `["PASSWORD"]`,
+			ExpectedError: "can't read JSON variables 'STDIN': expected a JSON object at the top level",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			output, err := internal.Generate(
+				getRuntime(t, "jsonnet"),
+				strings.NewReader(`{ port: std.extVar('API_PORT'), password: std.extVar('PASSWORD'), min: std.extVar('REPLICAS').min }`),
+				[]string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")},
+				internal.Options{JSONVarsInput: strings.NewReader(tc.Input)},
+			)
+
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestPropertiesFiles(t *testing.T) {
+	root := t.TempDir()
+
+	properties := filepath.Join(root, "app.properties")
+	if err := ioutil.WriteFile(properties, []byte("db.url=jdbc:postgresql://db.svc/app\nAPI_PORT=8080\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	volume := filepath.Join(root, "volume")
+	if err := os.MkdirAll(volume, 0755); err != nil {
+		t.Fatalf("can't create directory: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(volume, "API_PORT"), []byte("1337"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name          string
+		Conflict      variable.Conflict
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:          "conflict",
+			ExpectedError: "can't load properties file '" + properties + "': variable 'API_PORT' is defined by both '" + filepath.Join(volume, "API_PORT") + "' and '" + properties + ":2'",
+		},
+		{
+			Name:     "first wins",
+			Conflict: variable.ConflictFirst,
+			Expected: "jdbc:postgresql://db.svc/app 1337",
+		},
+		{
+			Name:     "last wins",
+			Conflict: variable.ConflictLast,
+			Expected: "jdbc:postgresql://db.svc/app 8080",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "plain")
+			opts := internal.Options{PropertiesFiles: []string{properties}, Conflict: tc.Conflict}
+
+			output, err := internal.Generate(runtime, strings.NewReader(`{{ index . "db.url" }} {{ .API_PORT }}`), []string{volume}, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(name string, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("can't create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	write("db/DATABASE_URL", "postgres://db")
+	write("cache/DATABASE_URL", "redis://cache")
+	write("other/DB_DATABASE_URL", "postgres://other")
+
+	db, cache, other := filepath.Join(root, "db"), filepath.Join(root, "cache"), filepath.Join(root, "other")
+
+	tcs := []struct {
+		Name          string
+		Volumes       []string
+		Prefixes      map[string]string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "prefixed",
+			Volumes:  []string{db, cache},
+			Prefixes: map[string]string{db: "DB", cache + "/": "CACHE"},
+			Expected: "postgres://db redis://cache",
+		},
+		{
+			Name:          "same names",
+			Volumes:       []string{db, cache},
+			ExpectedError: "can't load volume variables '" + cache + "': variable 'DATABASE_URL' is defined by both '" + filepath.Join(db, "DATABASE_URL") + "' and '" + filepath.Join(cache, "DATABASE_URL") + "'",
+		},
+		{
+			Name:          "conflict once prefixed",
+			Volumes:       []string{db, other},
+			Prefixes:      map[string]string{db: "DB"},
+			ExpectedError: "can't load volume variables '" + other + "': variable 'DB_DATABASE_URL' is defined by both '" + filepath.Join(db, "DATABASE_URL") + "' and '" + filepath.Join(other, "DB_DATABASE_URL") + "'",
+		},
+		{
+			Name:          "not a volume",
+			Volumes:       []string{db},
+			Prefixes:      map[string]string{db: "DB", cache: "CACHE"},
+			ExpectedError: "can't prefix the variables of '" + cache + "': not a volume path",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "plain")
+			opts := internal.Options{Prefixes: tc.Prefixes}
+
+			output, err := internal.Generate(runtime, strings.NewReader(`{{ .DB_DATABASE_URL }} {{ .CACHE_DATABASE_URL }}`), tc.Volumes, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestInjectMetadata(t *testing.T) {
+	if err := os.Setenv("SOURCE_DATE_EPOCH", "1609459200"); err != nil {
+		t.Fatalf("can't set environment variable: %v", err)
+	}
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(reserved, "_hostname"), []byte("api"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name          string
+		Volumes       []string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "metadata",
+			Volumes:  []string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")},
+			Expected: "{\n   \"generatedAt\": \"2021-01-01T00:00:00Z\",\n   \"hostname\": \"" + hostname + "\"\n}\n",
+		},
+		{
+			Name:          "reserved",
+			Volumes:       []string{reserved},
+			ExpectedError: "can't inject metadata: variable '_hostname' is reserved but defined by '" + filepath.Join(reserved, "_hostname") + "'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			output, err := internal.Generate(
+				getRuntime(t, "jsonnet"),
+				strings.NewReader(`{ generatedAt: std.extVar('_generated_at'), hostname: std.extVar('_hostname') }`),
+				tc.Volumes,
+				internal.Options{InjectMetadata: true, Conflict: "last"},
+			)
+
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	output, err := internal.Generate(
+		getRuntime(t, "jsonnet"),
+		strings.NewReader(`{ port: std.extVar('API_PORT'), user: std.extVar('DATABASE_USERNAME'), replicas: std.extVar('REPLICAS') }`),
+		[]string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")},
+		internal.Options{
+			Overrides: []variable.Variable{
+				{Name: "API_PORT", Value: "8080", Source: "-set"},
+				{Name: "REPLICAS", Value: "3", Source: "-set-code", Code: true},
+				{Name: "API_PORT", Value: "9090", Source: "-set"},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "{\n   \"port\": \"9090\",\n   \"replicas\": 3,\n   \"user\": \"myapp\"\n}\n"; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(name string, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("can't create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	write("base/API_PORT", "1337")
+	write("base/LOG_LEVEL", "info")
+	write("prod/API_PORT", "443")
+	write("secrets/DATABASE_PASSWORD", "sssh!")
+	write("other/API_PORT", "8080")
+
+	tcs := []struct {
+		Name          string
+		Layers        []string
+		Volumes       []string
+		Conflict      variable.Conflict
+		Overrides     []variable.Variable
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "last layer wins",
+			Layers:   []string{"base", "prod"},
+			Volumes:  []string{"secrets"},
+			Expected: "443 info sssh!",
+		},
+		{
+			Name:     "order",
+			Layers:   []string{"prod", "base"},
+			Volumes:  []string{"secrets"},
+			Expected: "1337 info sssh!",
+		},
+		{
+			Name:      "overrides",
+			Layers:    []string{"base", "prod"},
+			Volumes:   []string{"secrets"},
+			Overrides: []variable.Variable{{Name: "API_PORT", Value: "9090", Source: "-set"}},
+			Expected:  "9090 info sssh!",
+		},
+		{
+			Name:          "conflict with a volume",
+			Layers:        []string{"base", "prod"},
+			Volumes:       []string{"secrets", "other"},
+			ExpectedError: "can't load volume variables '" + filepath.Join(root, "other") + "': variable 'API_PORT' is defined by both '" + filepath.Join(root, "prod", "API_PORT") + "' and '" + filepath.Join(root, "other", "API_PORT") + "'",
+		},
+		{
+			Name:     "conflict with a volume, last",
+			Layers:   []string{"base", "prod"},
+			Volumes:  []string{"secrets", "other"},
+			Conflict: variable.ConflictLast,
+			Expected: "8080 info sssh!",
+		},
+		{
+			Name:          "missing layer",
+			Layers:        []string{"base", "missing"},
+			ExpectedError: "can't read layer variables '" + filepath.Join(root, "missing") + "'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := internal.Options{Conflict: tc.Conflict, Overrides: tc.Overrides}
+			for _, layer := range tc.Layers {
+				opts.Layers = append(opts.Layers, filepath.Join(root, layer))
+			}
+
+			var volumes []string
+			for _, v := range tc.Volumes {
+				volumes = append(volumes, filepath.Join(root, v))
+			}
+
+			output, err := internal.Generate(getRuntime(t, "plain"), strings.NewReader("{{ .API_PORT }} {{ .LOG_LEVEL }} {{ .DATABASE_PASSWORD }}"), volumes, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if output != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Required      []string
+		ExpectedError string
+	}{
+		{Name: "provided", Required: []string{"API_PORT", "DATABASE_USERNAME"}},
+		{Name: "missing", Required: []string{"DATABASE_PASSWORD", "API_PORT", "TLS_KEY", "DATABASE_PASSWORD"}, ExpectedError: "missing required variables: 'DATABASE_PASSWORD', 'TLS_KEY'"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "plain")
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+			opts := internal.Options{Required: tc.Required}
+
+			_, err := internal.Generate(runtime, strings.NewReader("{{ .API_PORT }}"), volumes, opts)
+			if tc.ExpectedError == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.ExpectedError {
+				t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var logs []string
+	opts := internal.Options{
+		Logf: func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) },
+	}
+
+	runtime := getRuntime(t, "plain")
+	volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+
+	if _, err := internal.Generate(runtime, strings.NewReader("{{ .DATABASE_USERNAME }}"), volumes, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	output := strings.Join(logs, "\n")
+	for _, expected := range []string{"scanning volume '" + volumes[0] + "'", "loading variable 'DATABASE_USERNAME' from '"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("invalid logs\nexpected to contain:\n'%s'\nactual:\n'%s'\n", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "myapp") {
+		t.Fatalf("logs contain a variable value:\n%s", output)
+	}
+}
+
+func TestSecretsRedactedInErrors(t *testing.T) {
+	const secret = "s3cr3t-p4ssw0rd"
+
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "PASSWORD"), []byte(secret+"\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name        string
+		Interpreter string
+		Template    string
+		Multi       bool
+	}{
+		{Name: "jsonnet error", Interpreter: "jsonnet", Template: "error std.extVar('PASSWORD')"},
+		{Name: "jsonnet parse", Interpreter: "jsonnet", Template: "std.parseInt(std.extVar('PASSWORD'))"},
+		{Name: "plain fail", Interpreter: "plain", Template: "{{ fail .PASSWORD }}"},
+		{Name: "starlark fail", Interpreter: "starlark", Template: "fail(vars['PASSWORD'])"},
+		{Name: "cue conflict", Interpreter: "cue", Template: "password: _vars.PASSWORD & \"other\""},
+		{Name: "multi file name", Interpreter: "jsonnet", Template: "{ ['/' + std.extVar('PASSWORD')]: 'value' }", Multi: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+
+			var err error
+			if tc.Multi {
+				_, err = internal.GenerateMulti(runtime, strings.NewReader(tc.Template), []string{root}, internal.Options{})
+			} else {
+				_, err = internal.Generate(runtime, strings.NewReader(tc.Template), []string{root}, internal.Options{})
+			}
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("error contains the secret value:\n%v", err)
+			}
+
+			if !strings.Contains(err.Error(), internal.RedactedValue) {
+				t.Fatalf("invalid error\nexpected to contain:\n%s\nactual:\n%v\n", internal.RedactedValue, err)
+			}
+		})
+	}
+}
+
+func TestEscapedSecretsRedactedInErrors(t *testing.T) {
+	const secret = "s3cr3t\np4ss\"w0rd"
+
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "PASSWORD"), []byte(secret), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tcs := []struct {
+		Name        string
+		Interpreter string
+		Template    string
+	}{
+		{Name: "jsonnet error", Interpreter: "jsonnet", Template: "error std.extVar('PASSWORD')"},
+		{Name: "jsonnet json", Interpreter: "jsonnet", Template: "error std.manifestJson({ password: 'x' + std.extVar('PASSWORD') })"},
+		{Name: "plain quote", Interpreter: "plain", Template: "{{ fail (printf \"%q\" .PASSWORD) }}"},
+		{Name: "starlark repr", Interpreter: "starlark", Template: "fail(repr(vars['PASSWORD']))"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+
+			_, err := internal.Generate(runtime, strings.NewReader(tc.Template), []string{root}, internal.Options{})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			for _, leaked := range []string{secret, `s3cr3t\np4ss\"w0rd`, "p4ss"} {
+				if strings.Contains(err.Error(), leaked) {
+					t.Fatalf("error contains the secret value:\n%v", err)
+				}
+			}
+
+			if !strings.Contains(err.Error(), internal.RedactedValue) {
+				t.Fatalf("invalid error\nexpected to contain:\n%s\nactual:\n%v\n", internal.RedactedValue, err)
+			}
+		})
+	}
+}
+
+func TestGenerateResult(t *testing.T) {
+	tcs := []struct {
+		Name               string
+		Interpreter        string
+		Template           string
+		Format             format.Format
+		ExpectedUsedVars   []string
+		ExpectedUnusedVars []string
+		ExpectedFormat     string
+	}{
+		{
+			Name:               "jsonnet",
+			Interpreter:        "jsonnet",
+			Template:           "{ port: std.extVar('API_PORT'), missing: std.native('extVarDefault')('MISSING', null) }",
+			ExpectedUsedVars:   []string{"API_PORT"},
+			ExpectedUnusedVars: []string{"DATABASE_USERNAME"},
+			ExpectedFormat:     "json",
+		},
+		{
+			Name:               "jsonnet yaml",
+			Interpreter:        "jsonnet",
+			Template:           "{ user: std.native('extVarDefault')('DATABASE_USERNAME', 'root') }",
+			Format:             format.YAML,
+			ExpectedUsedVars:   []string{"DATABASE_USERNAME"},
+			ExpectedUnusedVars: []string{"API_PORT"},
+			ExpectedFormat:     "yaml",
+		},
+		{
+			Name:               "plain",
+			Interpreter:        "plain",
+			Template:           `{{ if .API_PORT }}{{ var "DATABASE_USERNAME" }}{{ end }}`,
+			ExpectedUsedVars:   []string{"API_PORT", "DATABASE_USERNAME"},
+			ExpectedUnusedVars: []string{},
+			ExpectedFormat:     internal.TextFormat,
+		},
+		{
+			Name:               "envsubst",
+			Interpreter:        "envsubst",
+			Template:           "{\"user\": \"${DATABASE_USERNAME}\"}",
+			ExpectedUsedVars:   []string{"DATABASE_USERNAME"},
+			ExpectedUnusedVars: []string{"API_PORT"},
+			ExpectedFormat:     "json",
+		},
+		{
+			Name:           "starlark",
+			Interpreter:    "starlark",
+			Template:       "config = {'port': vars['API_PORT']}",
+			ExpectedFormat: "json",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+
+			result, err := internal.GenerateResult(runtime, strings.NewReader(tc.Template), volumes, internal.Options{Format: tc.Format})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.ExpectedUsedVars, result.UsedVars) || !reflect.DeepEqual(tc.ExpectedUnusedVars, result.UnusedVars) {
+				t.Fatalf("invalid variables\nexpected:\n%v %v\nactual:\n%v %v\n", tc.ExpectedUsedVars, tc.ExpectedUnusedVars, result.UsedVars, result.UnusedVars)
+			}
+
+			if tc.ExpectedFormat != result.Format {
+				t.Fatalf("invalid format\nexpected:\n'%s'\nactual:\n'%s'\n", tc.ExpectedFormat, result.Format)
+			}
+
+			var names []string
+			for _, v := range result.Variables {
+				names = append(names, v.Name+"="+v.Value)
+			}
+
+			if expected := []string{"API_PORT=1337", "DATABASE_USERNAME=myapp"}; !reflect.DeepEqual(expected, names) {
+				t.Fatalf("invalid loaded variables\nexpected:\n%v\nactual:\n%v\n", expected, names)
+			}
+		})
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Format        format.Format
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "yaml",
+			Template: "api:\n  port: ${API_PORT}\n  user: \"${DATABASE_USERNAME}\"\n",
+			Format:   format.YAML,
+			Expected: "api:\n  port: 1337\n  user: myapp\n",
+		},
+		{
+			Name:          "broken yaml",
+			Template:      "api: ${API_PORT}: ${DATABASE_USERNAME}\n",
+			Format:        format.YAML,
+			ExpectedError: "can't parse generated content: can't parse content as YAML",
+		},
+		{
+			Name:     "json",
+			Template: `{"port": ${API_PORT}}`,
+			Format:   format.JSON,
+			Expected: "{\n   \"port\": 1337\n}\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "envsubst")
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+			opts := internal.Options{Format: tc.Format, ParseOutput: true}
+
+			output, err := internal.Generate(runtime, strings.NewReader(tc.Template), volumes, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestReformatText(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Format        format.Format
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "yaml",
+			Template: "user:   ${DATABASE_USERNAME}\napi:\n    port: ${API_PORT}\n",
+			Format:   format.YAML,
+			Expected: "api:\n  port: 1337\nuser: myapp\n",
+		},
+		{
+			Name:     "toml",
+			Template: "user = \"${DATABASE_USERNAME}\"\n[api]\nport = ${API_PORT}\n",
+			Format:   format.TOML,
+			Expected: "user = 'myapp'\n\n[api]\nport = 1337\n",
+		},
+		{
+			Name:     "json converted",
+			Template: `{"port": ${API_PORT}}`,
+			Format:   format.TOML,
+			Expected: "port = 1337\n",
+		},
+		{
+			Name:          "broken toml",
+			Template:      "port = = ${API_PORT}\n",
+			Format:        format.TOML,
+			ExpectedError: "can't parse generated content: can't parse content as TOML",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, "envsubst")
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+			opts := internal.Options{Format: tc.Format, ReformatText: true}
+
+			output, err := internal.Generate(runtime, strings.NewReader(tc.Template), volumes, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"host": "db.local\n", "port": "5432\n"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	tcs := []struct {
+		Name          string
+		Interpreter   string
+		Template      string
+		Group         string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:        "jsonnet",
+			Interpreter: "jsonnet",
+			Template:    "std.extVar('database').host + ':' + std.extVar('API_PORT')",
+			Group:       "database",
+			Expected:    "\"db.local:1337\"\n",
+		},
+		{
+			Name:        "plain",
+			Interpreter: "plain",
+			Template:    "{{ .database }}",
+			Group:       "database",
+			Expected:    `{"host":"db.local","port":"5432"}`,
+		},
+		{
+			Name:          "conflict",
+			Interpreter:   "jsonnet",
+			Template:      "std.extVar('API_PORT')",
+			Group:         "API_PORT",
+			ExpectedError: "can't load group variables '" + root + "'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+			volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+			opts := internal.Options{Groups: map[string]string{tc.Group: root}}
+
+			output, err := internal.Generate(runtime, strings.NewReader(tc.Template), volumes, opts)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+type blockingInterpreter struct {
+	release chan struct{}
+}
+
+func (b blockingInterpreter) AddVar(name string, value string) {}
+
+func (b blockingInterpreter) Evaluate(tpl string) (string, error) {
+	<-b.release
+	return tpl, nil
+}
+
+func TestTimeout(t *testing.T) {
+	runtime := blockingInterpreter{release: make(chan struct{})}
+	defer close(runtime.release)
+
+	_, err := internal.Generate(runtime, strings.NewReader("{}"), nil, internal.Options{Timeout: 10 * time.Millisecond})
+
+	expected := "can't evaluate template: evaluation timed out after 10ms"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+
+	output, err := internal.Generate(getRuntime(t, "jsonnet"), strings.NewReader("{}"), nil, internal.Options{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "{ }\n"; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+}
+
+func TestLoadVariables(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(envFile, []byte("API_PORT=8080\nLOG_LEVEL=debug\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	runtime := getRuntime(t, "jsonnet")
+	volumes := []string{"../cmd/cfgenerator/examples/plain/volumes/config"}
+
+	variables, err := internal.LoadVariables(runtime, volumes, internal.Options{EnvFiles: []string{envFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := make(map[string]string, len(variables))
+	for _, v := range variables {
+		actual[v.Name] = v.Value
+	}
+
+	expected := map[string]string{"API_PORT": "1337", "DATABASE_USERNAME": "myapp", "LOG_LEVEL": "debug"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+	}
+}
+
+func TestOverlays(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Input         string
+		Overlays      []string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:  "deep merge",
+			Input: `{ port: std.parseInt(std.extVar("API_PORT")), database: { username: std.extVar("DATABASE_USERNAME"), options: { ssl: false, pool: 5 } }, hosts: ["a", "b"] }`,
+			Overlays: []string{
+				`{ database: { options: { ssl: true } }, hosts: ["c"] }`,
+				`{ database: { options: { pool: 10 } }, debug: false }`,
+			},
+			Expected: "{\n   \"database\": {\n      \"options\": {\n         \"pool\": 10,\n         \"ssl\": true\n      },\n      \"username\": \"myapp\"\n   },\n   \"debug\": false,\n   \"hosts\": [\n      \"c\"\n   ],\n   \"port\": 1337\n}\n",
+		},
+		{
+			Name:     "overlay replaces object",
+			Input:    `{ database: { username: "a" } }`,
+			Overlays: []string{`{ database: "b" }`},
+			Expected: "{\n   \"database\": \"b\"\n}\n",
+		},
+		{
+			Name:          "base array",
+			Input:         `[1]`,
+			Overlays:      []string{`{}`},
+			ExpectedError: "can't merge templates: base must produce an object",
+		},
+		{
+			Name:          "overlay string",
+			Input:         `{}`,
+			Overlays:      []string{`{}`, `"port"`},
+			ExpectedError: "can't merge templates: overlay 2 must produce an object",
+		},
+		{
+			Name:          "overlay error",
+			Input:         `{}`,
+			Overlays:      []string{`{ port: std.extVar("MISSING") }`},
+			ExpectedError: "can't evaluate overlay 1: can't evaluate template:",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			overlays := make([]io.Reader, 0, len(tc.Overlays))
+			for _, overlay := range tc.Overlays {
+				overlays = append(overlays, strings.NewReader(overlay))
+			}
+
+			output, err := internal.Generate(
+				getRuntime(t, "jsonnet"),
+				strings.NewReader(tc.Input),
+				[]string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")},
+				internal.Options{Overlays: overlays},
+			)
+
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestGenerateTo(t *testing.T) {
+	volumes := []string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")}
+
+	tcs := []struct {
+		Name             string
+		Interpreter      string
+		Template         string
+		Options          internal.Options
+		ExpectedStreamed bool
+		Expected         string
+		ExpectedError    string
+	}{
+		{
+			Name:             "plain",
+			Interpreter:      "plain",
+			Template:         "port: {{ .API_PORT }}\n",
+			ExpectedStreamed: true,
+			Expected:         "port: 1337\n",
+		},
+		{
+			Name:        "formatted",
+			Interpreter: "plain",
+			Template:    `{"port": {{ .API_PORT }}}`,
+			Options:     internal.Options{Format: format.YAML, ReformatText: true},
+			Expected:    "port: 1337\n",
+		},
+		{
+			Name:        "not streaming",
+			Interpreter: "jsonnet",
+			Template:    "{ port: std.extVar('API_PORT') }",
+			Expected:    "{\n   \"port\": \"1337\"\n}\n",
+		},
+		{
+			Name:          "error",
+			Interpreter:   "plain",
+			Template:      "port: {{ .API_PORT }}\n{{ fail \"boom\" }}",
+			Expected:      "port: 1337\n",
+			ExpectedError: "can't evaluate template: can't evaluate plain template: template: :2:3: executing \"\" at <fail \"boom\">: error calling fail: boom",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := getRuntime(t, tc.Interpreter)
+
+			if streamed := internal.CanStream(runtime, tc.Options); streamed != tc.ExpectedStreamed && tc.ExpectedError == "" {
+				t.Fatalf("invalid streaming\nexpected:\n%v\nactual:\n%v\n", tc.ExpectedStreamed, streamed)
+			}
+
+			var buf strings.Builder
+			result, err := internal.GenerateTo(runtime, strings.NewReader(tc.Template), volumes, &buf, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.Expected != buf.String() {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, buf.String())
+			}
+
+			if tc.ExpectedStreamed && result.Content != "" {
+				t.Fatalf("invalid result content\nexpected:\n''\nactual:\n'%s'\n", result.Content)
+			}
+		})
+	}
+}
+
+// largeTemplate renders about 9MB of text, the streaming path saving the copies of the content
+const largeTemplate = `{{ range $i := until 500000 }}line {{ $i }}: {{ $.API_PORT }}
+{{ end }}`
+
+func BenchmarkGenerate(b *testing.B) {
+	volumes := []string{filepath.Join("..", "cmd", "cfgenerator", "examples", "plain", "volumes", "config")}
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			runtime, _ := interpreter.Get("plain")
+
+			content, err := internal.Generate(runtime, strings.NewReader(largeTemplate), volumes, internal.Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if _, err := io.WriteString(ioutil.Discard, content); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			runtime, _ := interpreter.Get("plain")
+
+			if _, err := internal.GenerateTo(runtime, strings.NewReader(largeTemplate), volumes, ioutil.Discard, internal.Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
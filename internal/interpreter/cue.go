@@ -0,0 +1,82 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+)
+
+// CUEVarsField is the name of the hidden field holding the variables in a CUE template
+const CUEVarsField = "_vars"
+
+// CUE represents the CUE interpreter. The template can't declare a package as the '_vars' hidden
+// field belongs to the anonymous package
+type CUE struct {
+	vars map[string]string
+}
+
+// NewCUE builds a new CUE interpreter
+func NewCUE() *CUE {
+	return &CUE{vars: make(map[string]string)}
+}
+
+// AddVar stores a new variable in the '_vars' hidden field
+func (c *CUE) AddVar(name string, value string) {
+	c.vars[name] = value
+}
+
+// Evaluate unifies the template with all the variable previously stored accessible in the '_vars'
+// hidden field and returns the result encoded as JSON. It fails when the result isn't concrete or
+// violates a constraint
+func (c *CUE) Evaluate(tpl string) (string, error) {
+	ctx := cuecontext.New()
+
+	varsPath := cue.MakePath(cue.Hid(CUEVarsField, "_"))
+
+	// The scope resolves the references to '_vars' when the template doesn't declare it, while
+	// FillPath unifies the variables with its declaration (e.g. a schema) when it does
+	scope := ctx.CompileString("{}").FillPath(varsPath, c.vars)
+
+	value := ctx.CompileString(tpl, cue.Filename("template.cue"), cue.Scope(scope))
+	if err := value.Err(); err != nil {
+		return "", fmt.Errorf("can't evaluate cue template: %v", cueError(err))
+	}
+
+	value = value.FillPath(varsPath, c.vars)
+	if err := value.Validate(cue.Concrete(true)); err != nil {
+		return "", fmt.Errorf("can't evaluate cue template: %v", cueError(err))
+	}
+
+	content, err := value.MarshalJSON()
+	if err != nil {
+		return "", fmt.Errorf("can't evaluate cue template: %v", cueError(err))
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, content, "", "   "); err != nil {
+		return "", fmt.Errorf("can't encode cue content as JSON: %v", err)
+	}
+
+	return indented.String() + "\n", nil
+}
+
+// CheckSyntax parses the template without unifying it with the variables. The error reports the
+// 'template.cue:line:column' of the invalid token
+func (c *CUE) CheckSyntax(tpl string) error {
+	if _, err := parser.ParseFile("template.cue", tpl); err != nil {
+		return fmt.Errorf("can't parse cue template: %v", cueError(err))
+	}
+
+	return nil
+}
+
+// cueError lists all the errors reported by CUE with their positions instead of only the first one
+func cueError(err error) string {
+	return strings.TrimRight(errors.Details(err, nil), "\n")
+}
@@ -0,0 +1,71 @@
+package interpreter_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestCUE(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "vars",
+			Template: "port: _vars.API_PORT\nreplicas: int & >0\nreplicas: 3",
+			Expected: "{\n   \"port\": \"1337\",\n   \"replicas\": 3\n}\n",
+		},
+		{
+			Name:     "declared vars",
+			Template: "_vars: API_PORT: =~\"^[0-9]+$\"\nport: _vars.API_PORT",
+			Expected: "{\n   \"port\": \"1337\"\n}\n",
+		},
+		{
+			Name:          "failing constraint",
+			Template:      "port: _vars.API_PORT\nreplicas: int & >5\nreplicas: 3",
+			ExpectedError: "can't evaluate cue template: replicas: invalid value 3 (out of bound >5):\n    template.cue:2:17\n    template.cue:3:11",
+		},
+		{
+			Name:          "failing variable constraint",
+			Template:      "_vars: API_PORT: =~\"^[a-z]+$\"\nport: _vars.API_PORT",
+			ExpectedError: "can't evaluate cue template: _vars.API_PORT: invalid value \"1337\" (out of bound =~\"^[a-z]+$\"):\n    template.cue:1:18",
+		},
+		{
+			Name:          "incomplete",
+			Template:      "port: _vars.API_PORT\nreplicas: int",
+			ExpectedError: "can't evaluate cue template: replicas: incomplete value int:\n    template.cue:2:11",
+		},
+		{
+			Name:          "missing variable",
+			Template:      "port: _vars.UNKNOWN",
+			ExpectedError: "can't evaluate cue template: port: undefined field: UNKNOWN:\n    template.cue:1:13",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewCUE()
+			runtime.AddVar("API_PORT", "1337")
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvsubstOptions represents the settings of the envsubst interpreter
+type EnvsubstOptions struct {
+	// Strict fails the evaluation when the template references a variable that isn't defined
+	// instead of keeping the reference as is
+	Strict bool
+}
+
+// Envsubst represents the interpreter replacing the shell-style `$NAME` and `${NAME}` references
+// by the value of the variables. `$$` is a literal `$`
+type Envsubst struct {
+	vars map[string]string
+	used map[string]bool
+	opts EnvsubstOptions
+}
+
+// NewEnvsubst builds a new envsubst interpreter
+func NewEnvsubst() *Envsubst {
+	return &Envsubst{vars: make(map[string]string), used: make(map[string]bool)}
+}
+
+// Configure sets the options of the interpreter
+func (e *Envsubst) Configure(opts EnvsubstOptions) {
+	e.opts = opts
+}
+
+// AddVar stores a new variable
+func (e *Envsubst) AddVar(name string, value string) {
+	e.vars[name] = value
+}
+
+// Evaluate replaces all the variable references of the template
+func (e *Envsubst) Evaluate(tpl string) (string, error) {
+	e.used = make(map[string]bool)
+
+	var buf strings.Builder
+	var missing []string
+
+	for i := 0; i < len(tpl); i++ {
+		if tpl[i] != '$' || i+1 == len(tpl) {
+			buf.WriteByte(tpl[i])
+			continue
+		}
+
+		next := tpl[i+1]
+		switch {
+		case next == '$':
+			buf.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(tpl[i+2:], '}')
+			if end < 0 || !isEnvsubstName(tpl[i+2:i+2+end]) {
+				buf.WriteByte('$')
+				continue
+			}
+
+			name := tpl[i+2 : i+2+end]
+			missing = e.write(&buf, name, tpl[i:i+3+end], missing)
+			i += 2 + end
+		case isEnvsubstNameStart(next):
+			end := i + 2
+			for end < len(tpl) && isEnvsubstNameChar(tpl[end]) {
+				end++
+			}
+
+			missing = e.write(&buf, tpl[i+1:end], tpl[i:end], missing)
+			i = end - 1
+		default:
+			buf.WriteByte('$')
+		}
+	}
+
+	if e.opts.Strict && len(missing) > 0 {
+		return "", fmt.Errorf("can't evaluate envsubst template: undefined variables: %s", strings.Join(missing, ", "))
+	}
+
+	return buf.String(), nil
+}
+
+// CheckSyntax accepts any template: the text which isn't a valid reference is kept as is
+func (e *Envsubst) CheckSyntax(tpl string) error {
+	return nil
+}
+
+// IsText returns true, the substitutions being made in plain text
+func (e *Envsubst) IsText() bool {
+	return true
+}
+
+// UsedVars returns the names of the variables referenced by the last evaluated template
+func (e *Envsubst) UsedVars() []string {
+	return sortedNames(e.used)
+}
+
+func (e *Envsubst) write(buf *strings.Builder, name string, reference string, missing []string) []string {
+	e.used[name] = true
+
+	value, found := e.vars[name]
+	if !found {
+		buf.WriteString(reference)
+
+		quoted := fmt.Sprintf("'%s'", name)
+		for _, m := range missing {
+			if m == quoted {
+				return missing
+			}
+		}
+
+		return append(missing, quoted)
+	}
+
+	buf.WriteString(value)
+
+	return missing
+}
+
+func isEnvsubstName(name string) bool {
+	if name == "" || !isEnvsubstNameStart(name[0]) {
+		return false
+	}
+
+	for i := 1; i < len(name); i++ {
+		if !isEnvsubstNameChar(name[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isEnvsubstNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isEnvsubstNameChar(c byte) bool {
+	return isEnvsubstNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,50 @@
+package interpreter_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestEnvsubst(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Strict        bool
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{Name: "braces", Template: "listen ${API_PORT};", Expected: "listen 1337;"},
+		{Name: "bare", Template: "listen $API_PORT;\nuser $DATABASE_USERNAME", Expected: "listen 1337;\nuser myapp"},
+		{Name: "escaped", Template: "price: $$5 $$API_PORT", Expected: "price: $5 $API_PORT"},
+		{Name: "unknown kept", Template: "home: $HOME ${HOME}", Expected: "home: $HOME ${HOME}"},
+		{Name: "not references", Template: "$ $1 ${} ${API-PORT} ${API_PORT trailing $", Expected: "$ $1 ${} ${API-PORT} ${API_PORT trailing $"},
+		{Name: "strict", Strict: true, Template: "${API_PORT} $HOME ${USER} ${HOME}", ExpectedError: "can't evaluate envsubst template: undefined variables: 'HOME', 'USER'"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewEnvsubst()
+			runtime.Configure(interpreter.EnvsubstOptions{Strict: tc.Strict})
+			runtime.AddVar("API_PORT", "1337")
+			runtime.AddVar("DATABASE_USERNAME", "myapp")
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package interpreter
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"text/template/parse"
+)
+
+// HTML represents the Go html/template interpreter. It's the plain interpreter with the contextual
+// auto-escaping of html/template: each value is escaped according to where it's written in the
+// HTML document (text, attribute, URL, JavaScript or CSS)
+type HTML struct {
+	vars map[string]interface{}
+	used []string
+	opts PlainOptions
+}
+
+// NewHTML builds a new Go html/template interpreter
+func NewHTML() *HTML {
+	return &HTML{vars: make(map[string]interface{})}
+}
+
+// Configure sets the options of the interpreter, which are the same as the plain ones
+func (h *HTML) Configure(opts PlainOptions) {
+	h.opts = opts
+}
+
+// AddVar stores a new variable
+func (h *HTML) AddVar(name string, value string) {
+	h.vars[name] = value
+}
+
+// AddData stores a new variable holding the data of the JSON value, so the template can read its
+// fields (e.g. '.database.host')
+func (h *HTML) AddData(name string, data string) error {
+	value, err := decodeData(data)
+	if err != nil {
+		return err
+	}
+
+	h.vars[name] = value
+
+	return nil
+}
+
+// Evaluate executes the template with all the variable previously stored accessible, escaping them
+// according to their context
+func (h *HTML) Evaluate(tpl string) (string, error) {
+	var buf strings.Builder
+	if err := h.EvaluateTo(&buf, tpl); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// EvaluateTo executes the template like Evaluate, writing the content to the writer as it's
+// rendered
+func (h *HTML) EvaluateTo(w io.Writer, tpl string) error {
+	used, err := evaluateGoTemplate("html", h.newTemplate, w, tpl, h.opts.Includes, h.vars)
+	if err != nil {
+		return err
+	}
+
+	h.used = used
+
+	return nil
+}
+
+// CheckSyntax parses the template and its includes without executing them, like the plain
+// interpreter. The escaping contexts are only checked by the evaluation
+func (h *HTML) CheckSyntax(tpl string) error {
+	_, err := parseGoTemplate("html", h.newTemplate, tpl, h.opts.Includes)
+
+	return err
+}
+
+// newTemplate builds the empty template configured with the options of the interpreter
+func (h *HTML) newTemplate() goTemplate {
+	return htmlTemplate{t: template.New("").
+		Option(missingKeyOption(h.opts.Strict)).
+		Delims(h.opts.LeftDelim, h.opts.RightDelim).
+		Funcs(template.FuncMap(templateFuncs(h.opts.Sprig, h.vars, h.opts.Strict)))}
+}
+
+// htmlTemplate is the goTemplate of the html/template package
+type htmlTemplate struct {
+	t *template.Template
+}
+
+func (t htmlTemplate) define(name string, text string) error {
+	if name == "" {
+		_, err := t.t.Parse(text)
+		return err
+	}
+
+	_, err := t.t.New(name).Parse(text)
+
+	return err
+}
+
+func (t htmlTemplate) execute(w io.Writer, data interface{}) error {
+	return t.t.Execute(w, data)
+}
+
+func (t htmlTemplate) trees() []*parse.Tree {
+	var trees []*parse.Tree
+	for _, defined := range t.t.Templates() {
+		if defined.Tree != nil {
+			trees = append(trees, defined.Tree)
+		}
+	}
+
+	return trees
+}
+
+// IsText returns true, the content of an html template being plain text
+func (h *HTML) IsText() bool {
+	return true
+}
+
+// UsedVars returns the names of the variables referenced by the last evaluated template and its
+// includes, like the plain interpreter
+func (h *HTML) UsedVars() []string {
+	return h.used
+}
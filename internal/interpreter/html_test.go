@@ -0,0 +1,84 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestHTMLEscaping(t *testing.T) {
+	value := `</script><b title="x">Tom & "Jerry"</b>`
+
+	tcs := []struct {
+		Name        string
+		Interpreter interpreter.Interpreter
+		Template    string
+		Expected    string
+	}{
+		{
+			Name:        "plain script",
+			Interpreter: interpreter.NewPlain(),
+			Template:    `<script>window.config = { name: "{{ .NAME }}" };</script>`,
+			Expected:    `<script>window.config = { name: "</script><b title="x">Tom & "Jerry"</b>" };</script>`,
+		},
+		{
+			Name:        "html script",
+			Interpreter: interpreter.NewHTML(),
+			Template:    `<script>window.config = { name: "{{ .NAME }}" };</script>`,
+			Expected:    `<script>window.config = { name: "\u003c\/script\u003e\u003cb title=\u0022x\u0022\u003eTom \u0026 \u0022Jerry\u0022\u003c\/b\u003e" };</script>`,
+		},
+		{
+			Name:        "html script value",
+			Interpreter: interpreter.NewHTML(),
+			Template:    `<script>window.config = { name: {{ .NAME }} };</script>`,
+			Expected:    `<script>window.config = { name: "\u003c/script\u003e\u003cb title=\"x\"\u003eTom \u0026 \"Jerry\"\u003c/b\u003e" };</script>`,
+		},
+		{
+			Name:        "html text",
+			Interpreter: interpreter.NewHTML(),
+			Template:    `<p>{{ .NAME }}</p>`,
+			Expected:    `<p>&lt;/script&gt;&lt;b title=&#34;x&#34;&gt;Tom &amp; &#34;Jerry&#34;&lt;/b&gt;</p>`,
+		},
+		{
+			Name:        "html attribute",
+			Interpreter: interpreter.NewHTML(),
+			Template:    `<a title="{{ .NAME }}" href="/search?q={{ .NAME }}">`,
+			Expected:    `<a title="&lt;/script&gt;&lt;b title=&#34;x&#34;&gt;Tom &amp; &#34;Jerry&#34;&lt;/b&gt;" href="/search?q=%3c%2fscript%3e%3cb%20title%3d%22x%22%3eTom%20%26%20%22Jerry%22%3c%2fb%3e">`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Interpreter.AddVar("NAME", value)
+
+			output, err := tc.Interpreter.Evaluate(tc.Template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestHTMLOptions(t *testing.T) {
+	html := interpreter.NewHTML()
+	html.Configure(interpreter.PlainOptions{Strict: true, LeftDelim: "[[", RightDelim: "]]"})
+	html.AddVar("PORT", "1337")
+
+	output, err := html.Evaluate(`<p>[[ var "PORT" | default "80" ]]</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<p>1337</p>"; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+
+	if _, err := html.Evaluate("<p>[[ .MISSING ]]</p>"); err == nil || !strings.Contains(err.Error(), `map has no entry for key "MISSING"`) {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", `map has no entry for key "MISSING"`, err)
+	}
+}
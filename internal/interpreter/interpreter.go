@@ -0,0 +1,214 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNotFound is an error returned when no interpreter match the givent criteria
+	ErrNotFound = errors.New("not found")
+
+	interpretersMutex sync.RWMutex
+	interpreters      = make(map[string]BuilderFunc)
+	extensions        = make(map[string]string)
+)
+
+// Auto is the name used to detect the interpreter from the extension of the template path
+const Auto = "auto"
+
+// Default is the name of the interpreter used when it can't be detected (e.g. reading from STDIN)
+const Default = "jsonnet"
+
+func init() {
+	Register("cue", func() Interpreter { return NewCUE() })
+	Register("envsubst", func() Interpreter { return NewEnvsubst() })
+	Register("html", func() Interpreter { return NewHTML() })
+	Register("jsonc", func() Interpreter { return NewJSONC() })
+	Register("jsonnet", func() Interpreter { return NewJsonnet() })
+	Register("plain", func() Interpreter { return NewPlain() })
+	Register("starlark", func() Interpreter { return NewStarlark() })
+
+	RegisterExtension(".cue", "cue")
+	RegisterExtension(".gohtml", "html")
+	RegisterExtension(".html", "html")
+	RegisterExtension(".jsonc", "jsonc")
+	RegisterExtension(".jsonnet", "jsonnet")
+	RegisterExtension(".libsonnet", "jsonnet")
+	RegisterExtension(".star", "starlark")
+	RegisterExtension(".tmpl", "plain")
+	RegisterExtension(".tpl", "plain")
+	RegisterExtension(".txt", "plain")
+}
+
+// BuilderFunc represents a function that initialize a new Interpreter
+type BuilderFunc func() Interpreter
+
+// Register makes an interpreter available by the provided name. It panics if the builder is nil or
+// if an interpreter is already registered with the same name
+func Register(name string, builderFunc BuilderFunc) {
+	interpretersMutex.Lock()
+	defer interpretersMutex.Unlock()
+
+	if builderFunc == nil {
+		panic(fmt.Sprintf("can't register interpreter '%s': nil builder", name))
+	}
+
+	if _, found := interpreters[name]; found {
+		panic(fmt.Sprintf("can't register interpreter '%s': already registered", name))
+	}
+
+	interpreters[name] = builderFunc
+}
+
+// Get builds a new interpreter from its name and return a boolean indicating wether the interpreter
+// has been found
+func Get(name string) (Interpreter, bool) {
+	interpretersMutex.RLock()
+	builder, found := interpreters[name]
+	interpretersMutex.RUnlock()
+
+	if !found {
+		return nil, false
+	}
+
+	return builder(), true
+}
+
+// RegisterExtension associates a template file extension (including the leading dot) to an
+// interpreter name used by Detect
+func RegisterExtension(extension string, name string) {
+	interpretersMutex.Lock()
+	defer interpretersMutex.Unlock()
+
+	extensions[extension] = name
+}
+
+// lookupExtension returns the name of the interpreter registered for the extension
+func lookupExtension(extension string) (string, bool) {
+	interpretersMutex.RLock()
+	defer interpretersMutex.RUnlock()
+
+	name, found := extensions[extension]
+
+	return name, found
+}
+
+// Detect returns the name of the interpreter matching the extension of the template path. It
+// returns Default when the path is `-` (STDIN) and an error when the extension is unknown
+func Detect(path string) (string, error) {
+	if path == "-" {
+		return Default, nil
+	}
+
+	extension := filepath.Ext(path)
+
+	name, found := lookupExtension(extension)
+	if !found {
+		return "", fmt.Errorf("can't detect interpreter of '%s': unknown extension '%s'", path, extension)
+	}
+
+	return name, nil
+}
+
+// DetectWithFallback returns the name of the interpreter matching the extension of the template
+// path like Detect, but returns the fallback when the path is `-` (STDIN) or the extension is
+// unknown. It behaves like Detect when the fallback is empty
+func DetectWithFallback(path string, fallback string) (string, error) {
+	if fallback == "" {
+		return Detect(path)
+	}
+
+	if path == "-" {
+		return fallback, nil
+	}
+
+	name, found := lookupExtension(filepath.Ext(path))
+	if !found {
+		return fallback, nil
+	}
+
+	return name, nil
+}
+
+// Names returns the names of all the registered interpreters, sorted alphabetically
+func Names() []string {
+	interpretersMutex.RLock()
+	defer interpretersMutex.RUnlock()
+
+	names := make([]string, 0, len(interpreters))
+	for name := range interpreters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Interpreter represents something able to aggregate variables and render templates
+type Interpreter interface {
+	AddVar(name string, value string)
+	Evaluate(tpl string) (string, error)
+}
+
+// CodeInterpreter represents an interpreter able to store variables whose value is code evaluated
+// by the interpreter itself instead of a string
+type CodeInterpreter interface {
+	Interpreter
+	AddCode(name string, code string) error
+}
+
+// DataInterpreter represents an interpreter able to store variables whose value is structured
+// data, given as JSON, and read field by field by the template
+type DataInterpreter interface {
+	Interpreter
+	AddData(name string, data string) error
+}
+
+// TrackingInterpreter represents an interpreter able to report the names of the variables referenced
+// by the last evaluated template, sorted alphabetically. The names of undefined variables can be
+// reported as well
+type TrackingInterpreter interface {
+	Interpreter
+	UsedVars() []string
+}
+
+// StreamingInterpreter represents an interpreter able to write the content to a writer as the
+// template is evaluated, instead of returning it once evaluated. The content written before an
+// error is incomplete
+type StreamingInterpreter interface {
+	Interpreter
+	EvaluateTo(w io.Writer, tpl string) error
+}
+
+// TextInterpreter represents an interpreter producing plain text instead of JSON, like the plain,
+// html and envsubst ones: several templates can be concatenated and the content isn't converted
+// to the output formats. The interpreter produces JSON when IsText returns false
+type TextInterpreter interface {
+	Interpreter
+	IsText() bool
+}
+
+// SyntaxInterpreter represents an interpreter able to check the syntax of a template without
+// evaluating it nor reading any variable. The error reports the position of the first invalid
+// token, when the parser provides it
+type SyntaxInterpreter interface {
+	Interpreter
+	CheckSyntax(tpl string) error
+}
+
+// sortedNames returns the keys of the set sorted alphabetically
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -0,0 +1,262 @@
+package interpreter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestNames(t *testing.T) {
+	expected := []string{"cue", "envsubst", "html", "jsonc", "jsonnet", "plain", "starlark"}
+
+	if actual := interpreter.Names(); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("invalid names\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		expected := "can't register interpreter 'plain': already registered"
+		if actual := recover(); actual != expected {
+			t.Fatalf("invalid panic\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+		}
+	}()
+
+	interpreter.Register("plain", func() interpreter.Interpreter { return interpreter.NewPlain() })
+}
+
+func TestDetect(t *testing.T) {
+	tcs := []struct {
+		Path          string
+		Expected      string
+		ExpectedError string
+	}{
+		{Path: "-", Expected: "jsonnet"},
+		{Path: "/app/config.cue", Expected: "cue"},
+		{Path: "/app/config.jsonnet", Expected: "jsonnet"},
+		{Path: "lib/util.libsonnet", Expected: "jsonnet"},
+		{Path: "config.conf.tpl", Expected: "plain"},
+		{Path: "config.star", Expected: "starlark"},
+		{Path: "config.tmpl", Expected: "plain"},
+		{Path: "config.txt", Expected: "plain"},
+		{Path: "config.json", ExpectedError: "can't detect interpreter of 'config.json': unknown extension '.json'"},
+		{Path: "config", ExpectedError: "can't detect interpreter of 'config': unknown extension ''"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Path, func(t *testing.T) {
+			actual, err := interpreter.Detect(tc.Path)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != actual {
+				t.Fatalf("invalid interpreter\nexpected:\n%s\nactual:\n%s\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestDetectWithFallback(t *testing.T) {
+	tcs := []struct {
+		Path          string
+		Fallback      string
+		Expected      string
+		ExpectedError string
+	}{
+		{Path: "-", Fallback: "plain", Expected: "plain"},
+		{Path: "config.cue", Fallback: "plain", Expected: "cue"},
+		{Path: "config.jsonnet", Fallback: "plain", Expected: "jsonnet"},
+		{Path: "config.libsonnet", Fallback: "plain", Expected: "jsonnet"},
+		{Path: "config.star", Fallback: "plain", Expected: "starlark"},
+		{Path: "config.tpl", Fallback: "jsonnet", Expected: "plain"},
+		{Path: "config.tmpl", Fallback: "jsonnet", Expected: "plain"},
+		{Path: "config.txt", Fallback: "jsonnet", Expected: "plain"},
+		{Path: "config.json", Fallback: "jsonnet", Expected: "jsonnet"},
+		{Path: "config.conf", Fallback: "plain", Expected: "plain"},
+		{Path: "config", Fallback: "plain", Expected: "plain"},
+		{Path: "-", Expected: "jsonnet"},
+		{Path: "config.conf", ExpectedError: "can't detect interpreter of 'config.conf': unknown extension '.conf'"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Path+" "+tc.Fallback, func(t *testing.T) {
+			actual, err := interpreter.DetectWithFallback(tc.Path, tc.Fallback)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != actual {
+				t.Fatalf("invalid interpreter\nexpected:\n%s\nactual:\n%s\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Interpreter   string
+		Template      string
+		ExpectedError string
+	}{
+		{Name: "cue", Interpreter: "cue", Template: "port: _vars.API_PORT"},
+		{Name: "cue invalid", Interpreter: "cue", Template: "a: 1\nb: {", ExpectedError: "can't parse cue template: expected '}', found 'EOF':\n    template.cue:2:5"},
+		{Name: "envsubst", Interpreter: "envsubst", Template: "listen ${API_PORT"},
+		{Name: "html", Interpreter: "html", Template: "<p>{{ .NAME }}</p>"},
+		{Name: "html invalid", Interpreter: "html", Template: "a\n{{ if .A }}\n", ExpectedError: "can't parse html template: template: :3: unexpected EOF"},
+		{Name: "jsonc", Interpreter: "jsonc", Template: "{\n  // the port\n  \"port\": ${API_PORT},\n}"},
+		{Name: "jsonc invalid", Interpreter: "jsonc", Template: "{\n  /* the port\n  */ \"port\": 1 2\n}", ExpectedError: "can't parse jsonc template: invalid JSON at line 3, column 16: invalid character '2' after object key:value pair"},
+		{Name: "jsonnet", Interpreter: "jsonnet", Template: "{ port: std.extVar('API_PORT') }"},
+		{Name: "jsonnet invalid", Interpreter: "jsonnet", Template: "{\n  a: 1,\n  b: ,\n}", ExpectedError: "can't parse jsonnet template: 3:6-7 Unexpected: (\",\", \",\") while parsing terminal"},
+		{Name: "plain", Interpreter: "plain", Template: "port: {{ .API_PORT | quote }}"},
+		{Name: "plain invalid", Interpreter: "plain", Template: "a\n{{ nope .A }}\n", ExpectedError: "can't parse plain template: template: :2: function \"nope\" not defined"},
+		{Name: "starlark", Interpreter: "starlark", Template: "config = {'port': vars['API_PORT']}"},
+		{Name: "starlark invalid", Interpreter: "starlark", Template: "config = {\n  'a': undefined_name,\n}", ExpectedError: "can't parse starlark template: template.star:2:8: undefined: undefined_name"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime, found := interpreter.Get(tc.Interpreter)
+			if !found {
+				t.Fatalf("interpreter '%s' not found", tc.Interpreter)
+			}
+
+			checker, ok := runtime.(interpreter.SyntaxInterpreter)
+			if !ok {
+				t.Fatalf("interpreter '%s' can't check the syntax", tc.Interpreter)
+			}
+
+			err := checker.CheckSyntax(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Interpreter   interpreter.OptionsInterpreter
+		Options       map[string]string
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:        "plain delimiters",
+			Interpreter: interpreter.NewPlain(),
+			Options:     map[string]string{"delim-left": "[[", "delim-right": "]]"},
+			Template:    "[[ .NAME ]] {{ .NAME }}",
+			Expected:    "value {{ .NAME }}",
+		},
+		{
+			Name:          "plain strict",
+			Interpreter:   interpreter.NewPlain(),
+			Options:       map[string]string{"strict": "true"},
+			Template:      "{{ .MISSING }}",
+			ExpectedError: `can't evaluate plain template: template: :1:3: executing "" at <.MISSING>: map has no entry for key "MISSING"`,
+		},
+		{
+			Name:        "html sprig",
+			Interpreter: interpreter.NewHTML(),
+			Options:     map[string]string{"sprig": "hermetic"},
+			Template:    "{{ upper .NAME }}",
+			Expected:    "VALUE",
+		},
+		{
+			Name:          "envsubst strict",
+			Interpreter:   interpreter.NewEnvsubst(),
+			Options:       map[string]string{"strict": "yes"},
+			ExpectedError: "invalid value 'yes' for option 'strict': expected true or false",
+		},
+		{
+			Name:          "plain one delimiter",
+			Interpreter:   interpreter.NewPlain(),
+			Options:       map[string]string{"delim-left": "[["},
+			ExpectedError: "options 'delim-left' and 'delim-right' must be set together and can't be empty",
+		},
+		{
+			Name:        "jsonnet bundle",
+			Interpreter: interpreter.NewJsonnet(),
+			Options:     map[string]string{"bundle-extvar": "vars", "bundle-only": "true"},
+			Template:    "std.extVar('vars').NAME",
+			Expected:    "\"value\"\n",
+		},
+		{
+			Name:          "unknown",
+			Interpreter:   interpreter.NewJsonnet(),
+			Options:       map[string]string{"strict": "true"},
+			ExpectedError: "unsupported option 'strict': expected one of bundle-extvar, bundle-only, jpath, trace",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Interpreter.AddVar("NAME", "value")
+
+			err := tc.Interpreter.SetOptions(tc.Options)
+			if err == nil {
+				var actual string
+				actual, err = tc.Interpreter.Evaluate(tc.Template)
+
+				if err == nil && tc.Expected != actual {
+					t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+				}
+			}
+
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSetOptionsInvalidKeepsSettings(t *testing.T) {
+	runtime := interpreter.NewEnvsubst()
+	runtime.Configure(interpreter.EnvsubstOptions{Strict: true})
+
+	expected := "invalid value 'yes' for option 'strict': expected true or false"
+	if err := runtime.SetOptions(map[string]string{"strict": "yes"}); err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+
+	expected = "can't evaluate envsubst template: undefined variables: 'MISSING'"
+	if _, err := runtime.Evaluate("${MISSING}"); err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+}
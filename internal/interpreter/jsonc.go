@@ -0,0 +1,326 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONC represents the interpreter reading JSON with comments: the `//` and `/* */` comments and
+// the trailing commas are removed and the `${NAME}` references are replaced by the value of the
+// variables. In a string literal the value is escaped and written in the string, elsewhere a
+// string variable is written as a JSON string and a code variable as is. `$${` is a literal `${`.
+// The output must be valid JSON
+type JSONC struct {
+	vars  map[string]string
+	codes map[string]string
+	used  map[string]bool
+}
+
+// NewJSONC builds a new jsonc interpreter
+func NewJSONC() *JSONC {
+	return &JSONC{vars: make(map[string]string), codes: make(map[string]string), used: make(map[string]bool)}
+}
+
+// AddVar stores a new variable
+func (j *JSONC) AddVar(name string, value string) {
+	j.vars[name] = value
+}
+
+// AddCode stores a variable whose value is JSON, written as is outside of the string literals
+func (j *JSONC) AddCode(name string, code string) error {
+	if !json.Valid([]byte(code)) {
+		return fmt.Errorf("invalid JSON")
+	}
+
+	j.codes[name] = code
+
+	return nil
+}
+
+// Evaluate strips the comments and the trailing commas of the template and replaces its variable
+// references
+func (j *JSONC) Evaluate(tpl string) (string, error) {
+	output, err := j.evaluate(tpl, false)
+	if err != nil {
+		return "", fmt.Errorf("can't evaluate jsonc template: %v", err)
+	}
+
+	return output, nil
+}
+
+// CheckSyntax strips the comments and the trailing commas of the template and ensures the result
+// is valid JSON, replacing its variable references by null
+func (j *JSONC) CheckSyntax(tpl string) error {
+	if _, err := j.evaluate(tpl, true); err != nil {
+		return fmt.Errorf("can't parse jsonc template: %v", err)
+	}
+
+	return nil
+}
+
+// evaluate builds the JSON of the template. When checking the syntax, the variables aren't read:
+// the references are written as null outside of the string literals and removed in them
+func (j *JSONC) evaluate(tpl string, check bool) (string, error) {
+	j.used = make(map[string]bool)
+
+	s := jsoncScanner{tpl: tpl, line: 1}
+	var missing []string
+
+	for s.pos < len(tpl) {
+		c := tpl[s.pos]
+
+		switch {
+		case c == '"':
+			var err error
+			if missing, err = j.scanString(&s, missing, check); err != nil {
+				return "", err
+			}
+		case c == '/' && s.pos+1 < len(tpl) && (tpl[s.pos+1] == '/' || tpl[s.pos+1] == '*'):
+			if err := s.skipComment(); err != nil {
+				return "", err
+			}
+		case c == ',':
+			s.flushComma()
+			s.comma = true
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if c == '\n' {
+				s.line++
+			}
+
+			if s.comma {
+				s.space.WriteByte(c)
+			} else {
+				s.buf.WriteByte(c)
+			}
+			s.pos++
+		case strings.HasPrefix(tpl[s.pos:], "$${"):
+			s.write("${")
+			s.pos += 3
+		case strings.HasPrefix(tpl[s.pos:], "${"):
+			name, length, ok := j.reference(tpl[s.pos:])
+			if !ok {
+				return "", fmt.Errorf("invalid variable reference at line %d", s.line)
+			}
+
+			if check {
+				s.write("null")
+			} else if code, found := j.codes[name]; found {
+				s.write(code)
+			} else if value, found := j.vars[name]; found {
+				s.write(jsonString(value))
+			} else {
+				missing = appendMissing(missing, name)
+			}
+
+			j.used[name] = true
+			s.pos += length
+		case c == '}' || c == ']':
+			s.comma = false
+			s.buf.WriteString(s.space.String())
+			s.space.Reset()
+			s.buf.WriteByte(c)
+			s.pos++
+		default:
+			s.flushComma()
+			s.buf.WriteByte(c)
+			s.pos++
+		}
+	}
+
+	s.flushComma()
+
+	if len(missing) > 0 && !check {
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+
+	output := s.buf.String()
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line, column := jsonPosition(output, syntaxErr.Offset)
+
+			return "", fmt.Errorf("invalid JSON at line %d, column %d: %v", line, column, err)
+		}
+
+		return "", fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	return output, nil
+}
+
+// UsedVars returns the names of the variables referenced by the last evaluated template
+func (j *JSONC) UsedVars() []string {
+	return sortedNames(j.used)
+}
+
+// scanString copies the string literal starting at the scanner position, replacing its variable
+// references by their escaped value
+func (j *JSONC) scanString(s *jsoncScanner, missing []string, check bool) ([]string, error) {
+	start := s.line
+	var literal strings.Builder
+	literal.WriteByte('"')
+
+	for s.pos++; s.pos < len(s.tpl); s.pos++ {
+		c := s.tpl[s.pos]
+
+		switch {
+		case c == '\\' && s.pos+1 < len(s.tpl):
+			literal.WriteString(s.tpl[s.pos : s.pos+2])
+			s.pos++
+		case c == '"':
+			literal.WriteByte('"')
+			s.pos++
+			s.write(literal.String())
+
+			return missing, nil
+		case c == '\n':
+			return missing, fmt.Errorf("unterminated string at line %d", start)
+		case strings.HasPrefix(s.tpl[s.pos:], "$${"):
+			literal.WriteString("${")
+			s.pos += 2
+		case strings.HasPrefix(s.tpl[s.pos:], "${"):
+			name, length, ok := j.reference(s.tpl[s.pos:])
+			if !ok {
+				literal.WriteByte(c)
+				continue
+			}
+
+			value, found := j.vars[name]
+			if code, isCode := j.codes[name]; isCode {
+				value, found = code, true
+			}
+
+			if check {
+				// The reference is removed
+			} else if found {
+				quoted := jsonString(value)
+				literal.WriteString(quoted[1 : len(quoted)-1])
+			} else {
+				missing = appendMissing(missing, name)
+			}
+
+			j.used[name] = true
+			s.pos += length - 1
+		default:
+			literal.WriteByte(c)
+		}
+	}
+
+	return missing, fmt.Errorf("unterminated string at line %d", start)
+}
+
+// reference returns the name and the length of the `${NAME}` reference starting the text
+func (j *JSONC) reference(text string) (string, int, bool) {
+	end := strings.IndexByte(text, '}')
+	if end < 0 || !isEnvsubstName(text[2:end]) {
+		return "", 0, false
+	}
+
+	return text[2:end], end + 1, true
+}
+
+// jsoncScanner holds the state of the evaluation of a jsonc template. A comma is kept pending,
+// along with the white spaces following it, until the next token tells whether it's a trailing
+// one
+type jsoncScanner struct {
+	tpl   string
+	pos   int
+	line  int
+	buf   strings.Builder
+	comma bool
+	space strings.Builder
+}
+
+// write appends a token to the output, after the pending comma if any
+func (s *jsoncScanner) write(token string) {
+	s.flushComma()
+	s.buf.WriteString(token)
+}
+
+func (s *jsoncScanner) flushComma() {
+	if s.comma {
+		s.buf.WriteByte(',')
+		s.comma = false
+	}
+
+	s.buf.WriteString(s.space.String())
+	s.space.Reset()
+}
+
+// skipComment moves the scanner after the comment starting at its position. A line comment ends
+// before the new line, which is kept
+func (s *jsoncScanner) skipComment() error {
+	if s.tpl[s.pos+1] == '/' {
+		end := strings.IndexByte(s.tpl[s.pos:], '\n')
+		if end < 0 {
+			s.pos = len(s.tpl)
+		} else {
+			s.pos += end
+		}
+
+		return nil
+	}
+
+	end := strings.Index(s.tpl[s.pos+2:], "*/")
+	if end < 0 {
+		return fmt.Errorf("unterminated comment at line %d", s.line)
+	}
+
+	comment := s.tpl[s.pos : s.pos+2+end+2]
+	s.pos += len(comment)
+
+	// The comment is blanked out, keeping its new lines, so the positions of the output match the
+	// template ones
+	s.line += strings.Count(comment, "\n")
+	replacement := strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+
+		return ' '
+	}, comment)
+
+	if s.comma {
+		s.space.WriteString(replacement)
+	} else {
+		s.buf.WriteString(replacement)
+	}
+
+	return nil
+}
+
+// jsonPosition returns the line and the column of the byte following the offset, as reported by a
+// json.SyntaxError
+func jsonPosition(content string, offset int64) (int, int) {
+	before := content
+	if offset >= 1 && int(offset) <= len(content) {
+		before = content[:offset-1]
+	}
+
+	return strings.Count(before, "\n") + 1, len(before) - strings.LastIndex(before, "\n")
+}
+
+// jsonString encodes the value as a JSON string, without escaping the HTML characters
+func jsonString(value string) string {
+	var buf strings.Builder
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func appendMissing(missing []string, name string) []string {
+	quoted := fmt.Sprintf("'%s'", name)
+	for _, m := range missing {
+		if m == quoted {
+			return missing
+		}
+	}
+
+	return append(missing, quoted)
+}
@@ -0,0 +1,98 @@
+package interpreter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestJSONC(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Expected      string
+		ExpectedUsed  []string
+		ExpectedError string
+	}{
+		{
+			Name:     "comments",
+			Template: "{\n  // the port\n  \"port\": 1337, /* inline */ \"host\": \"0.0.0.0\"\n}",
+			Expected: "{\n  \n  \"port\": 1337,              \"host\": \"0.0.0.0\"\n}",
+		},
+		{
+			Name:     "trailing commas",
+			Template: "{\"hosts\": [\"a\", \"b\",], \"tls\": {\"enabled\": true, // enabled\n},}",
+			Expected: "{\"hosts\": [\"a\", \"b\"], \"tls\": {\"enabled\": true \n}}",
+		},
+		{
+			Name:     "markers in strings",
+			Template: `{"url": "http://example.com/*path*/", "comment": "// not a comment", "comma": ",]", "quote": "\"//\""}`,
+			Expected: `{"url": "http://example.com/*path*/", "comment": "// not a comment", "comma": ",]", "quote": "\"//\""}`,
+		},
+		{
+			Name:         "variables",
+			Template:     `{"address": "0.0.0.0:${API_PORT}", "port": ${API_PORT}, "replicas": ${REPLICAS}, "password": "${PASSWORD}", "escaped": "$${API_PORT}"}`,
+			Expected:     `{"address": "0.0.0.0:1337", "port": "1337", "replicas": 3, "password": "\"<s>\"\\", "escaped": "${API_PORT}"}`,
+			ExpectedUsed: []string{"API_PORT", "PASSWORD", "REPLICAS"},
+		},
+		{
+			Name:          "undefined variables",
+			Template:      `{"home": "${HOME}", "user": ${USER}, "again": ${HOME}}`,
+			ExpectedError: "can't evaluate jsonc template: undefined variables: 'HOME', 'USER'",
+		},
+		{
+			Name:          "unterminated comment",
+			Template:      "{\n\"a\": 1 /* comment\n}",
+			ExpectedError: "can't evaluate jsonc template: unterminated comment at line 2",
+		},
+		{
+			Name:          "unterminated string",
+			Template:      "{\"a\": \"1\n}",
+			ExpectedError: "can't evaluate jsonc template: unterminated string at line 1",
+		},
+		{
+			Name:          "invalid json",
+			Template:      "{\n\"a\": 1 \"b\": 2}",
+			ExpectedError: "can't evaluate jsonc template: invalid JSON at line 2, column 8: invalid character '\"' after object key:value pair",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJSONC()
+			runtime.AddVar("API_PORT", "1337")
+			runtime.AddVar("PASSWORD", `"<s>"\`)
+			if err := runtime.AddCode("REPLICAS", "3"); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+
+			if tc.ExpectedUsed != nil && !reflect.DeepEqual(tc.ExpectedUsed, runtime.UsedVars()) {
+				t.Fatalf("invalid used variables\nexpected:\n%v\nactual:\n%v\n", tc.ExpectedUsed, runtime.UsedVars())
+			}
+		})
+	}
+}
+
+func TestJSONCInvalidCode(t *testing.T) {
+	if err := interpreter.NewJSONC().AddCode("REPLICAS", "{replicas: 3}"); err == nil || err.Error() != "invalid JSON" {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", "invalid JSON", err)
+	}
+}
@@ -0,0 +1,456 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+)
+
+// Jsonnet represents the JSONNET interpreter
+type Jsonnet struct {
+	vm     *jsonnet.VM
+	errors *jsonnetErrorFormatter
+	opts   JsonnetOptions
+	hasTLA bool
+	bundle string
+	only   bool
+	vars   map[string]string
+	codes  map[string]string
+	used   []string
+}
+
+// JsonnetOptions represents the settings of the JSONNET interpreter
+type JsonnetOptions struct {
+	// JPaths are the library search folders used to resolve imports. Like the jsonnet command line,
+	// the right-most folder wins when several contain the imported file
+	JPaths []string
+	// TLAVars are the top-level arguments passed as strings to the top-level function
+	TLAVars map[string]string
+	// TLACodes are the top-level arguments passed as JSONNET code to the top-level function
+	TLACodes map[string]string
+	// BundleExtVar, when set, is the name of an additional code ExtVar holding an object mapping
+	// every variable name to its value. A variable with the same name is an error
+	BundleExtVar string
+	// BundleOnly only registers the BundleExtVar, not one ExtVar per variable
+	BundleOnly bool
+	// LazyVolumes are folders whose files aren't loaded as ExtVars but read on demand by the
+	// readVolumeFile(name) native function, from the first folder containing them
+	LazyVolumes []string
+	// Trace names the file of every frame of the evaluation stack in the runtime errors, the
+	// template included, instead of the default locations of the VM
+	Trace bool
+}
+
+// NewJsonnet builds a new JSONNET interpreter
+func NewJsonnet() *Jsonnet {
+	j := &Jsonnet{vars: make(map[string]string), codes: make(map[string]string)}
+	j.vm = j.newVM()
+	j.errors = &jsonnetErrorFormatter{ErrorFormatter: j.vm.ErrorFormatter}
+	j.vm.ErrorFormatter = j.errors
+
+	return j
+}
+
+// newVM builds a VM with the native functions and the library folders of the interpreter
+func (j *Jsonnet) newVM() *jsonnet.VM {
+	vm := jsonnet.MakeVM()
+	for _, f := range jsonnetNativeFunctions {
+		vm.NativeFunction(f)
+	}
+
+	vm.NativeFunction(j.extVarDefaultFunction())
+	vm.NativeFunction(j.readVolumeFileFunction())
+	vm.Importer(&jsonnet.FileImporter{JPaths: j.opts.JPaths})
+
+	return vm
+}
+
+// Configure applies the options to the interpreter
+func (j *Jsonnet) Configure(opts JsonnetOptions) {
+	j.opts = opts
+	j.errors.trace = opts.Trace
+	j.vm.Importer(&jsonnet.FileImporter{JPaths: opts.JPaths})
+
+	for name, value := range opts.TLAVars {
+		j.vm.TLAVar(name, value)
+	}
+
+	for name, value := range opts.TLACodes {
+		j.vm.TLACode(name, value)
+	}
+
+	j.hasTLA = len(opts.TLAVars) > 0 || len(opts.TLACodes) > 0
+	j.bundle = opts.BundleExtVar
+	j.only = opts.BundleExtVar != "" && opts.BundleOnly
+}
+
+// AddVar stores a new variable as ExtVar
+func (j *Jsonnet) AddVar(name string, value string) {
+	j.vars[name] = value
+	if !j.only {
+		j.vm.ExtVar(name, value)
+	}
+}
+
+// AddCode checks the code is valid JSONNET and stores it as a code ExtVar
+func (j *Jsonnet) AddCode(name string, code string) error {
+	if _, err := jsonnet.SnippetToAST(name, code); err != nil {
+		return fmt.Errorf("invalid jsonnet code: %v", err)
+	}
+
+	j.codes[name] = code
+	if !j.only {
+		j.vm.ExtCode(name, code)
+	}
+
+	return nil
+}
+
+// Evaluate executes the template with all the variable previously stored accessible using std.extVar
+func (j *Jsonnet) Evaluate(tpl string) (string, error) {
+	if j.bundle != "" {
+		if err := j.registerBundle(); err != nil {
+			return "", fmt.Errorf("can't evaluate jsonnet template: %v", err)
+		}
+	}
+
+	evaluate := j.vm.EvaluateSnippet
+	if j.hasTLA {
+		evaluate = j.evaluateTopLevelFunction
+	}
+
+	json, err := evaluate("", tpl)
+	if err != nil {
+		return "", fmt.Errorf("can't evaluate jsonnet template: %v", err)
+	}
+
+	j.used = nil
+	if node, err := jsonnet.SnippetToAST("", tpl); err == nil {
+		used := make(map[string]bool)
+		collectJsonnetVars(node, used)
+
+		if j.bundle != "" && used[j.bundle] {
+			// The fields read from the bundle can't be known without evaluating the template, so all
+			// the variables are considered used
+			for name := range j.vars {
+				used[name] = true
+			}
+
+			for name := range j.codes {
+				used[name] = true
+			}
+		}
+
+		j.used = sortedNames(used)
+	}
+
+	return json, nil
+}
+
+// CheckSyntax parses the template without evaluating it. The error starts with the
+// 'line:column' of the invalid token
+func (j *Jsonnet) CheckSyntax(tpl string) error {
+	if _, err := jsonnet.SnippetToAST("", tpl); err != nil {
+		return fmt.Errorf("can't parse jsonnet template: %v", err)
+	}
+
+	return nil
+}
+
+// UsedVars returns the names of the variables read by the last evaluated template with
+// std.extVar('NAME') or std.native('extVarDefault')('NAME', ...). Only the literal names of the
+// template itself are reported, not the ones computed at runtime or read by imported files. All the
+// variables are reported when the template reads the bundle ExtVar
+func (j *Jsonnet) UsedVars() []string {
+	return j.used
+}
+
+// collectJsonnetVars walks the AST to find the variable names passed to std.extVar and extVarDefault
+func collectJsonnetVars(node ast.Node, used map[string]bool) {
+	if node == nil {
+		return
+	}
+
+	if apply, ok := node.(*ast.Apply); ok && len(apply.Arguments.Positional) > 0 {
+		if name, ok := apply.Arguments.Positional[0].Expr.(*ast.LiteralString); ok && isJsonnetVarAccessor(apply.Target) {
+			used[name.Value] = true
+		}
+	}
+
+	for _, child := range toolutils.Children(node) {
+		collectJsonnetVars(child, used)
+	}
+}
+
+// isJsonnetVarAccessor returns whether the node is std.extVar or std.native('extVarDefault')
+func isJsonnetVarAccessor(node ast.Node) bool {
+	if isJsonnetStdField(node, "extVar") {
+		return true
+	}
+
+	apply, ok := node.(*ast.Apply)
+	if !ok || len(apply.Arguments.Positional) != 1 || !isJsonnetStdField(apply.Target, "native") {
+		return false
+	}
+
+	name, ok := apply.Arguments.Positional[0].Expr.(*ast.LiteralString)
+
+	return ok && name.Value == "extVarDefault"
+}
+
+func isJsonnetStdField(node ast.Node, field string) bool {
+	index, ok := node.(*ast.Index)
+	if !ok {
+		return false
+	}
+
+	if target, ok := index.Target.(*ast.Var); !ok || target.Id != "std" {
+		return false
+	}
+
+	if index.Id != nil {
+		return string(*index.Id) == field
+	}
+
+	name, ok := index.Index.(*ast.LiteralString)
+
+	return ok && name.Value == field
+}
+
+// registerBundle registers the code ExtVar holding all the variables, the code variables being
+// embedded as is
+func (j *Jsonnet) registerBundle() error {
+	if _, found := j.vars[j.bundle]; found {
+		return fmt.Errorf("can't bundle the variables as '%s': a variable has the same name", j.bundle)
+	}
+
+	if _, found := j.codes[j.bundle]; found {
+		return fmt.Errorf("can't bundle the variables as '%s': a variable has the same name", j.bundle)
+	}
+
+	fields := make(map[string]string, len(j.vars)+len(j.codes))
+	for name, value := range j.vars {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("can't bundle variable '%s': %v", name, err)
+		}
+
+		fields[name] = string(encoded)
+	}
+
+	for name, code := range j.codes {
+		fields[name] = "(\n" + code + "\n)"
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var code strings.Builder
+	code.WriteString("{\n")
+	for _, name := range names {
+		key, _ := json.Marshal(name)
+		fmt.Fprintf(&code, "%s: %s,\n", key, fields[name])
+	}
+	code.WriteString("}")
+
+	j.vm.ExtCode(j.bundle, code.String())
+
+	return nil
+}
+
+// topLevelFileName names the wrapper checking the template evaluates to a function
+const topLevelFileName = "<top-level>"
+
+// notFunctionMessage is the error raised by the wrapper when the template isn't a function
+const notFunctionMessage = "top-level arguments are set but the top-level expression isn't a function"
+
+// evaluateTopLevelFunction evaluates the template once, failing when it doesn't evaluate to a
+// function as JSONNET silently ignores the top-level arguments in this case. The template is parsed
+// on its own and bound in the wrapper AST so its errors keep their line numbers, the frames of the
+// wrapper being removed from their stack
+func (j *Jsonnet) evaluateTopLevelFunction(filename string, tpl string) (string, error) {
+	node, err := jsonnet.SnippetToAST(filename, tpl)
+	if err != nil {
+		return "", errors.New(j.vm.ErrorFormatter.Format(err))
+	}
+
+	wrapper, err := jsonnet.SnippetToAST(topLevelFileName, "local template = null; if std.isFunction(template) then template else error \""+notFunctionMessage+"\"")
+	if err != nil {
+		return "", err
+	}
+
+	local, ok := wrapper.(*ast.Local)
+	if !ok || len(local.Binds) != 1 {
+		return "", fmt.Errorf("unexpected top-level function wrapper %T", wrapper)
+	}
+	local.Binds[0].Body = node
+
+	json, err := j.vm.Evaluate(local)
+	if err == nil {
+		return json, nil
+	}
+
+	runtimeErr, ok := err.(jsonnet.RuntimeError)
+	if !ok {
+		return "", errors.New(j.vm.ErrorFormatter.Format(err))
+	}
+
+	frames := runtimeErr.StackTrace
+	if runtimeErr.Msg == notFunctionMessage && len(frames) > 0 && frames[len(frames)-1].Loc.FileName == topLevelFileName {
+		return "", errors.New(notFunctionMessage)
+	}
+
+	// The frames are stored outermost first: the template frames follow the innermost frame of the
+	// wrapper, forcing the template, and the frames without location describe the evaluation step
+	last := -1
+	for i, frame := range frames {
+		if frame.Loc.FileName == topLevelFileName {
+			last = i
+		}
+	}
+
+	if last >= 0 {
+		kept := frames[:0:0]
+		for i := 0; i < len(frames) && !frames[i].Loc.IsSet(); i++ {
+			kept = append(kept, frames[i])
+		}
+
+		runtimeErr.StackTrace = append(kept, frames[last+1:]...)
+	}
+
+	return "", errors.New(j.vm.ErrorFormatter.Format(runtimeErr))
+}
+
+// extVarDefaultFunction builds the extVarDefault(name, default) native function returning the
+// ExtVar when it's defined, even with an empty value, and default otherwise
+func (j *Jsonnet) extVarDefaultFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "extVarDefault",
+		Params: ast.Identifiers{"name", "default"},
+		Func: func(args []interface{}) (interface{}, error) {
+			name, err := nativeStringArg("extVarDefault", args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if value, found := j.vars[name]; found {
+				return value, nil
+			}
+
+			code, found := j.codes[name]
+			if !found {
+				return args[1], nil
+			}
+
+			// The code is evaluated like an ExtVar of the template: with the same imports, native
+			// functions and variables
+			vm := j.newVM()
+			if !j.only {
+				for varName, value := range j.vars {
+					vm.ExtVar(varName, value)
+				}
+
+				for varName, varCode := range j.codes {
+					vm.ExtCode(varName, varCode)
+				}
+			}
+
+			content, err := vm.EvaluateSnippet(name, code)
+			if err != nil {
+				return nil, fmt.Errorf("extVarDefault: can't evaluate '%s': %v", name, err)
+			}
+
+			var value interface{}
+			if err := json.Unmarshal([]byte(content), &value); err != nil {
+				return nil, fmt.Errorf("extVarDefault: can't decode '%s': %v", name, err)
+			}
+
+			return value, nil
+		},
+	}
+}
+
+// readVolumeFileFunction builds the readVolumeFile(name) native function returning the content, as
+// is, of the file at the relative path name in the lazy volumes. The name can't escape the volumes,
+// symbolic links included
+func (j *Jsonnet) readVolumeFileFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "readVolumeFile",
+		Params: ast.Identifiers{"name"},
+		Func: func(args []interface{}) (interface{}, error) {
+			name, err := nativeStringArg("readVolumeFile", args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			cleaned := path.Clean(name)
+			if name == "" || path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+				return nil, fmt.Errorf("readVolumeFile: invalid file name '%s': expected a path relative to the volumes", name)
+			}
+
+			for _, root := range j.opts.LazyVolumes {
+				content, found, err := readVolumeFile(root, cleaned)
+				if err != nil {
+					return nil, fmt.Errorf("readVolumeFile: %v", err)
+				}
+
+				if found {
+					return content, nil
+				}
+			}
+
+			return nil, fmt.Errorf("readVolumeFile: file '%s' not found in the lazy volumes", name)
+		},
+	}
+}
+
+// readVolumeFile reads the file at the relative path in the volume, ensuring it's inside of it
+// once the symbolic links are resolved
+func readVolumeFile(root string, name string) (string, bool, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false, fmt.Errorf("can't resolve volume %s: %v", root, err)
+	}
+
+	realPath, err := filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("can't resolve file '%s' in volume %s: %v", name, root, err)
+	}
+
+	if rel, err := filepath.Rel(realRoot, realPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("file '%s' is outside of volume %s", name, root)
+	}
+
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return "", false, fmt.Errorf("can't read file '%s' in volume %s: %v", name, root, err)
+	}
+
+	if info.IsDir() {
+		return "", false, fmt.Errorf("'%s' is a folder in volume %s", name, root)
+	}
+
+	content, err := ioutil.ReadFile(realPath)
+	if err != nil {
+		return "", false, fmt.Errorf("can't read file '%s' in volume %s: %v", name, root, err)
+	}
+
+	return string(content), true, nil
+}
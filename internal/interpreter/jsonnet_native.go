@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+// jsonnetNativeFunctions lists the functions available in the JSONNET templates using std.native
+var jsonnetNativeFunctions = []*jsonnet.NativeFunction{
+	// base64Decode(s) decodes the standard base64 string s, e.g. a binary file loaded with the
+	// base64 binary mode
+	{Name: "base64Decode", Params: ast.Identifiers{"s"}, Func: nativeBase64Decode("base64Decode")},
+	// decodeBase64(s) is kept as an alias of base64Decode
+	{Name: "decodeBase64", Params: ast.Identifiers{"s"}, Func: nativeBase64Decode("decodeBase64")},
+	{
+		// base64Encode(s) encodes the string s in standard base64
+		Name:   "base64Encode",
+		Params: ast.Identifiers{"s"},
+		Func: func(args []interface{}) (interface{}, error) {
+			s, err := nativeStringArg("base64Encode", args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			return base64.StdEncoding.EncodeToString([]byte(s)), nil
+		},
+	},
+	{
+		// regexMatch(pattern, s) returns whether the string s contains a match of the RE2 pattern
+		Name:   "regexMatch",
+		Params: ast.Identifiers{"pattern", "s"},
+		Func: func(args []interface{}) (interface{}, error) {
+			re, err := nativeRegexpArg("regexMatch", args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := nativeStringArg("regexMatch", args, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			return re.MatchString(s), nil
+		},
+	},
+	{
+		// regexReplace(pattern, s, repl) replaces the matches of the RE2 pattern in the string s by
+		// repl, which can reference the submatches with $1 or ${name}
+		Name:   "regexReplace",
+		Params: ast.Identifiers{"pattern", "s", "repl"},
+		Func: func(args []interface{}) (interface{}, error) {
+			re, err := nativeRegexpArg("regexReplace", args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := nativeStringArg("regexReplace", args, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			repl, err := nativeStringArg("regexReplace", args, 2)
+			if err != nil {
+				return nil, err
+			}
+
+			return re.ReplaceAllString(s, repl), nil
+		},
+	},
+}
+
+func nativeBase64Decode(name string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, err := nativeStringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+
+		return string(decoded), nil
+	}
+}
+
+func nativeStringArg(name string, args []interface{}, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("%s: expected a string but got %T", name, args[i])
+	}
+
+	return s, nil
+}
+
+func nativeRegexpArg(name string, args []interface{}, i int) (*regexp.Regexp, error) {
+	pattern, err := nativeStringArg(name, args, i)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid pattern: %v", name, err)
+	}
+
+	return re, nil
+}
@@ -0,0 +1,435 @@
+package interpreter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestJsonnetJPaths(t *testing.T) {
+	root := t.TempDir()
+
+	writeLib := func(dir string, content string) string {
+		path := filepath.Join(root, dir, "lib", "util.libsonnet")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("can't create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+
+		return filepath.Join(root, dir)
+	}
+
+	first := writeLib("first", `{ name: 'first', port(n):: 'port-' + n }`)
+	second := writeLib("second", `{ name: 'second', port(n):: 'port-' + n }`)
+
+	tcs := []struct {
+		Name          string
+		JPaths        []string
+		Expected      string
+		ExpectedError string
+	}{
+		{Name: "single", JPaths: []string{first}, Expected: "{\n   \"name\": \"first\",\n   \"port\": \"port-1337\"\n}\n"},
+		{Name: "right-most wins", JPaths: []string{first, second}, Expected: "{\n   \"name\": \"second\",\n   \"port\": \"port-1337\"\n}\n"},
+		{Name: "not found", JPaths: nil, ExpectedError: "couldn't open import \"lib/util.libsonnet\""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJsonnet()
+			runtime.Configure(interpreter.JsonnetOptions{JPaths: tc.JPaths})
+			runtime.AddVar("API_PORT", "1337")
+
+			actual, err := runtime.Evaluate(`local util = import 'lib/util.libsonnet'; { name: util.name, port: util.port(std.extVar('API_PORT')) }`)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestJsonnetTLA(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Options       interpreter.JsonnetOptions
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "string and code",
+			Template: "function(port, replicas) { address: '0.0.0.0:' + port, replicas: replicas, user: std.extVar('DATABASE_USERNAME') }",
+			Options: interpreter.JsonnetOptions{
+				TLAVars:  map[string]string{"port": "1337"},
+				TLACodes: map[string]string{"replicas": "1 + 2"},
+			},
+			Expected: "{\n   \"address\": \"0.0.0.0:1337\",\n   \"replicas\": 3,\n   \"user\": \"myapp\"\n}\n",
+		},
+		{
+			Name:     "function behind locals",
+			Template: "local prefix = 'port-';\nfunction(port) prefix + port // comment",
+			Options:  interpreter.JsonnetOptions{TLAVars: map[string]string{"port": "1337"}},
+			Expected: "\"port-1337\"\n",
+		},
+		{
+			Name:     "no argument",
+			Template: "{ user: std.extVar('DATABASE_USERNAME') }",
+			Expected: "{\n   \"user\": \"myapp\"\n}\n",
+		},
+		{
+			Name:          "not a function",
+			Template:      "{ user: std.extVar('DATABASE_USERNAME') }",
+			Options:       interpreter.JsonnetOptions{TLAVars: map[string]string{"port": "1337"}},
+			ExpectedError: "can't evaluate jsonnet template: top-level arguments are set but the top-level expression isn't a function",
+		},
+		{
+			Name:          "error at the top level",
+			Template:      "local f(x) = error 'boom ' + x;\nf('top')",
+			Options:       interpreter.JsonnetOptions{TLAVars: map[string]string{"port": "1337"}},
+			ExpectedError: "can't evaluate jsonnet template: RUNTIME ERROR: boom top\n\t1:14-31\tfunction <f>\n\t2:1-9\t$\n\tDuring evaluation\t\n",
+		},
+		{
+			Name:          "error in the function",
+			Template:      "function(port)\n  local f(x) = error 'boom ' + x;\n  { a: f(port) }",
+			Options:       interpreter.JsonnetOptions{TLAVars: map[string]string{"port": "1337"}},
+			ExpectedError: "can't evaluate jsonnet template: RUNTIME ERROR: boom 1337\n\t2:16-33\tfunction <f>\n\t3:8-15\tobject <anonymous>\n\tDuring manifestation\t\n",
+		},
+		{
+			Name:          "syntax error",
+			Template:      "function(port) {",
+			Options:       interpreter.JsonnetOptions{TLAVars: map[string]string{"port": "1337"}},
+			ExpectedError: "can't evaluate jsonnet template: 1:17 Unexpected: end of file while parsing field definition\n\nfunction(port) {\n\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJsonnet()
+			runtime.Configure(tc.Options)
+			runtime.AddVar("DATABASE_USERNAME", "myapp")
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestJsonnetNativeFunctions(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{Name: "base64 round trip", Template: `std.native('base64Decode')(std.native('base64Encode')('\u0000 gzip\n')) == '\u0000 gzip\n'`, Expected: "true\n"},
+		{Name: "base64 encode", Template: `std.native('base64Encode')('myapp')`, Expected: "\"bXlhcHA=\"\n"},
+		{Name: "base64 decode alias", Template: `std.native('decodeBase64')('bXlhcHA=')`, Expected: "\"myapp\"\n"},
+		{Name: "base64 invalid", Template: `std.native('base64Decode')('!')`, ExpectedError: "base64Decode: illegal base64 data at input byte 0"},
+		{Name: "regex match", Template: `[std.native('regexMatch')('^[0-9]+$', '1337'), std.native('regexMatch')('^[0-9]+$', 'port')]`, Expected: "[\n   true,\n   false\n]\n"},
+		{Name: "regex replace", Template: `std.native('regexReplace')('([a-z]+)\\.svc', 'db.svc:5432', '$1.internal')`, Expected: "\"db.internal:5432\"\n"},
+		{Name: "regex invalid", Template: `std.native('regexMatch')('(', 'port')`, ExpectedError: "regexMatch: invalid pattern"},
+		{Name: "not a string", Template: `std.native('regexReplace')('a', 1, 'b')`, ExpectedError: "regexReplace: expected a string but got float64"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := interpreter.NewJsonnet().Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestJsonnetExtVarDefault(t *testing.T) {
+	runtime := interpreter.NewJsonnet()
+	runtime.AddVar("API_PORT", "1337")
+	runtime.AddVar("EMPTY", "")
+	if err := runtime.AddCode("REPLICAS", "{ min: 1 + 1 }"); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := runtime.Evaluate(`
+local default = std.native('extVarDefault');
+{
+  port: default('API_PORT', '8080'),
+  missing: default('MISSING', 8080),
+  empty: default('EMPTY', 'none'),
+  replicas: default('REPLICAS', {}).min,
+}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "{\n   \"empty\": \"\",\n   \"missing\": 8080,\n   \"port\": \"1337\",\n   \"replicas\": 2\n}\n"
+	if actual != expected {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+}
+
+func TestJsonnetExtVarDefaultCode(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "replicas.libsonnet"), []byte("{ min: 2 }"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	runtime := interpreter.NewJsonnet()
+	runtime.Configure(interpreter.JsonnetOptions{JPaths: []string{root}})
+	runtime.AddVar("API_PORT", "1337")
+	if err := runtime.AddCode("REPLICAS", "(import 'replicas.libsonnet').min"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runtime.AddCode("TOKEN", "std.native('base64Encode')(std.extVar('API_PORT'))"); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := runtime.Evaluate(`
+local default = std.native('extVarDefault');
+{
+  replicas: default('REPLICAS', 1),
+  token: default('TOKEN', ''),
+}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "{\n   \"replicas\": 2,\n   \"token\": \"MTMzNw==\"\n}\n"
+	if actual != expected {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+}
+
+func TestJsonnetBundleExtVar(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Options       interpreter.JsonnetOptions
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "bundle",
+			Options:  interpreter.JsonnetOptions{BundleExtVar: "_vars"},
+			Template: "local v = std.extVar('_vars'); [v.API_PORT, v['log-level'], v.REPLICAS.min, std.extVar('API_PORT')]",
+			Expected: "[\n   \"1337\",\n   \"debug\",\n   2,\n   \"1337\"\n]\n",
+		},
+		{
+			Name:          "bundle only",
+			Options:       interpreter.JsonnetOptions{BundleExtVar: "_vars", BundleOnly: true},
+			Template:      "std.extVar('API_PORT')",
+			ExpectedError: "Undefined external variable: API_PORT",
+		},
+		{
+			Name:          "conflict",
+			Options:       interpreter.JsonnetOptions{BundleExtVar: "API_PORT"},
+			Template:      "std.extVar('API_PORT')",
+			ExpectedError: "can't bundle the variables as 'API_PORT': a variable has the same name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJsonnet()
+			runtime.Configure(tc.Options)
+			runtime.AddVar("API_PORT", "1337")
+			runtime.AddVar("log-level", "debug")
+			if err := runtime.AddCode("REPLICAS", "{ min: 1 + 1 }"); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != actual {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+
+			if expected := []string{"API_PORT", "REPLICAS", "_vars", "log-level"}; !reflect.DeepEqual(expected, runtime.UsedVars()) {
+				t.Fatalf("invalid used variables\nexpected:\n%v\nactual:\n%v\n", expected, runtime.UsedVars())
+			}
+		})
+	}
+}
+
+func TestJsonnetReadVolumeFile(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(name string, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("can't create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	write("first/certs/ca.pem", "CA\n")
+	write("second/certs/ca.pem", "other CA\n")
+	write("second/key", "KEY")
+	write("secret", "outside")
+
+	if err := os.Symlink(filepath.Join(root, "secret"), filepath.Join(root, "first", "escape")); err != nil {
+		t.Fatalf("can't create symbolic link: %v", err)
+	}
+
+	tcs := []struct {
+		Name          string
+		File          string
+		Expected      string
+		ExpectedError string
+	}{
+		{Name: "first wins", File: "certs/ca.pem", Expected: "\"CA\\n\"\n"},
+		{Name: "second", File: "key", Expected: "\"KEY\"\n"},
+		{Name: "unknown", File: "missing", ExpectedError: "readVolumeFile: file 'missing' not found in the lazy volumes"},
+		{Name: "parent", File: "../secret", ExpectedError: "readVolumeFile: invalid file name '../secret': expected a path relative to the volumes"},
+		{Name: "absolute", File: "/etc/passwd", ExpectedError: "readVolumeFile: invalid file name '/etc/passwd': expected a path relative to the volumes"},
+		{Name: "symbolic link", File: "escape", ExpectedError: "readVolumeFile: file 'escape' is outside of volume " + filepath.Join(root, "first")},
+		{Name: "folder", File: "certs", ExpectedError: "readVolumeFile: 'certs' is a folder in volume " + filepath.Join(root, "first")},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJsonnet()
+			runtime.Configure(interpreter.JsonnetOptions{LazyVolumes: []string{filepath.Join(root, "first"), filepath.Join(root, "second")}})
+
+			actual, err := runtime.Evaluate(`std.native('readVolumeFile')('` + tc.File + `')`)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestJsonnetTrace(t *testing.T) {
+	root := t.TempDir()
+
+	if err := ioutil.WriteFile(filepath.Join(root, "inner.libsonnet"), []byte("{ boom: error 'boom' }\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "outer.libsonnet"), []byte("local inner = import 'inner.libsonnet';\n{ value: inner.boom }\n"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tpl := "local outer = import 'outer.libsonnet';\n{\n  out: outer.value,\n}\n"
+	functions := "local f(x) = error 'boom ' + x;\nlocal g(x) = f(x);\n{ a: g(1) }\n"
+
+	tcs := []struct {
+		Name          string
+		Template      string
+		Trace         bool
+		ExpectedError string
+	}{
+		{
+			Name:     "without trace",
+			Template: functions,
+			ExpectedError: "can't evaluate jsonnet template: RUNTIME ERROR: boom 1\n" +
+				"\t1:14-31\tfunction <f>\n" +
+				"\t2:14-18\tfunction <g>\n" +
+				"\t3:6-10\tobject <anonymous>\n" +
+				"\tDuring manifestation\t\n",
+		},
+		{
+			Name:     "functions with trace",
+			Template: functions,
+			Trace:    true,
+			ExpectedError: "can't evaluate jsonnet template: RUNTIME ERROR: boom 1\n" +
+				"\t<template>:1:14-31\tfunction <f>\n" +
+				"\t<template>:2:14-18\tfunction <g>\n" +
+				"\t<template>:3:6-10\tobject <anonymous>\n" +
+				"\tDuring manifestation",
+		},
+		{
+			Name:     "with trace",
+			Template: tpl,
+			Trace:    true,
+			ExpectedError: "can't evaluate jsonnet template: RUNTIME ERROR: boom\n" +
+				"\t" + filepath.Join(root, "inner.libsonnet") + ":1:9-21\tobject <anonymous>\n" +
+				"\t" + filepath.Join(root, "outer.libsonnet") + ":2:10-20\tobject <anonymous>\n" +
+				"\t<template>:3:8-19\tobject <anonymous>\n" +
+				"\tDuring manifestation",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewJsonnet()
+			runtime.Configure(interpreter.JsonnetOptions{JPaths: []string{root}, Trace: tc.Trace})
+
+			_, err := runtime.Evaluate(tc.Template)
+			if err == nil || err.Error() != tc.ExpectedError {
+				t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+			}
+		})
+	}
+}
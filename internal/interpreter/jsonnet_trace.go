@@ -0,0 +1,46 @@
+package interpreter
+
+import (
+	"strings"
+
+	"github.com/google/go-jsonnet"
+)
+
+// templateFileName names the frames of the evaluated template in the stack traces, its snippet
+// having no file name
+const templateFileName = "<template>"
+
+// jsonnetErrorFormatter formats the errors of the JSONNET VM. Without trace, they're formatted by
+// the default formatter of the VM, listing every frame of the stack. With trace, the runtime errors
+// come with every frame of the evaluation stack, innermost first, as a "file:line:column" location
+// naming the file of each frame, the template included
+type jsonnetErrorFormatter struct {
+	jsonnet.ErrorFormatter
+	trace bool
+}
+
+// Format formats the error returned by the VM
+func (f *jsonnetErrorFormatter) Format(err error) string {
+	runtimeErr, ok := err.(jsonnet.RuntimeError)
+	if !ok || !f.trace {
+		return f.ErrorFormatter.Format(err)
+	}
+
+	var buf strings.Builder
+	buf.WriteString(runtimeErr.Error())
+
+	for i := len(runtimeErr.StackTrace) - 1; i >= 0; i-- {
+		frame := runtimeErr.StackTrace[i]
+		location := frame.Loc.String()
+		if frame.Loc.IsSet() && frame.Loc.FileName == "" {
+			location = templateFileName + ":" + location
+		}
+
+		buf.WriteString("\n\t" + location)
+		if frame.Name != "" {
+			buf.WriteString("\t" + frame.Name)
+		}
+	}
+
+	return buf.String()
+}
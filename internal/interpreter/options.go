@@ -0,0 +1,172 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OptionsInterpreter represents an interpreter accepting its settings as a map of named options
+// (e.g. given as key=value pairs on the command line). The options are applied on top of the
+// current settings of the interpreter
+type OptionsInterpreter interface {
+	Interpreter
+	SetOptions(options map[string]string) error
+}
+
+// SetOptions applies the named options to the settings of the interpreter. The recognized keys
+// are `delim-left`, `delim-right`, `include` (comma separated glob patterns, added to the current
+// ones), `sprig` and `strict`
+func (g *Plain) SetOptions(options map[string]string) error {
+	return setPlainOptions(&g.opts, options)
+}
+
+// SetOptions applies the named options to the settings of the interpreter. The recognized keys
+// are the plain ones
+func (h *HTML) SetOptions(options map[string]string) error {
+	return setPlainOptions(&h.opts, options)
+}
+
+// SetOptions applies the named options to the settings of the interpreter. The only recognized
+// key is `strict`
+func (e *Envsubst) SetOptions(options map[string]string) error {
+	updated := e.opts
+
+	err := forEachOption(options, []string{"strict"}, func(key string, value string) error {
+		var err error
+		updated.Strict, err = parseBoolOption(key, value)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	e.opts = updated
+
+	return nil
+}
+
+// SetOptions applies the named options to the settings of the interpreter. The recognized keys
+// are `bundle-extvar`, `bundle-only`, `jpath` (comma separated folders, added to the current
+// ones) and `trace`
+func (j *Jsonnet) SetOptions(options map[string]string) error {
+	opts := j.opts
+
+	err := forEachOption(options, []string{"bundle-extvar", "bundle-only", "jpath", "trace"}, func(key string, value string) error {
+		var err error
+
+		switch key {
+		case "bundle-extvar":
+			opts.BundleExtVar = value
+		case "bundle-only":
+			opts.BundleOnly, err = parseBoolOption(key, value)
+		case "jpath":
+			opts.JPaths = append(append([]string{}, opts.JPaths...), splitListOption(value)...)
+		case "trace":
+			opts.Trace, err = parseBoolOption(key, value)
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.BundleOnly && opts.BundleExtVar == "" {
+		return fmt.Errorf("option 'bundle-only' requires option 'bundle-extvar'")
+	}
+
+	j.Configure(opts)
+
+	return nil
+}
+
+func setPlainOptions(opts *PlainOptions, options map[string]string) error {
+	updated := *opts
+
+	err := forEachOption(options, []string{"delim-left", "delim-right", "include", "sprig", "strict"}, func(key string, value string) error {
+		var err error
+
+		switch key {
+		case "delim-left":
+			updated.LeftDelim = value
+		case "delim-right":
+			updated.RightDelim = value
+		case "include":
+			updated.Includes = append(append([]string{}, updated.Includes...), splitListOption(value)...)
+		case "sprig":
+			updated.Sprig, err = ParseSprig(value)
+		case "strict":
+			updated.Strict, err = parseBoolOption(key, value)
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if (updated.LeftDelim == "") != (updated.RightDelim == "") {
+		return fmt.Errorf("options 'delim-left' and 'delim-right' must be set together and can't be empty")
+	}
+
+	*opts = updated
+
+	return nil
+}
+
+// forEachOption calls the function for every option, sorted by key, after ensuring all the keys
+// are supported
+func forEachOption(options map[string]string, supported []string, fn func(key string, value string) error) error {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !containsString(supported, key) {
+			return fmt.Errorf("unsupported option '%s': expected one of %s", key, strings.Join(supported, ", "))
+		}
+	}
+
+	for _, key := range keys {
+		if err := fn(key, options[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseBoolOption(key string, value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for option '%s': expected true or false", value, key)
+	}
+
+	return b, nil
+}
+
+func splitListOption(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
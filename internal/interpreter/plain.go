@@ -0,0 +1,481 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// Sprig represents the set of Sprig functions available in the plain templates
+type Sprig string
+
+const (
+	// SprigFull makes all the Sprig functions available. It's the default behavior
+	SprigFull Sprig = "full"
+	// SprigHermetic makes the Sprig functions available except the ones whose result isn't
+	// reproducible (time, randomness, environment, network...)
+	SprigHermetic Sprig = "hermetic"
+	// SprigNone doesn't make any Sprig function available
+	SprigNone Sprig = "none"
+)
+
+// ParseSprig returns the Sprig matching the given name
+func ParseSprig(name string) (Sprig, error) {
+	switch s := Sprig(name); s {
+	case SprigFull, SprigHermetic, SprigNone:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported sprig mode '%s'", name)
+	}
+}
+
+// sprigNonHermeticFuncs lists the Sprig functions depending on the time, randomness, the
+// environment or the network
+var sprigNonHermeticFuncs = []string{
+	"ago", "now",
+	"randAlpha", "randAlphaNum", "randAscii", "randBytes", "randInt", "randNumeric", "shuffle", "uuidv4",
+	"bcrypt", "htpasswd", "encryptAES",
+	"genCA", "genCAWithKey", "genPrivateKey",
+	"genSelfSignedCert", "genSelfSignedCertWithKey", "genSignedCert", "genSignedCertWithKey",
+	"env", "expandenv",
+	"getHostByName",
+}
+
+func (s Sprig) funcs() template.FuncMap {
+	switch s {
+	case SprigNone:
+		return template.FuncMap{}
+	case SprigHermetic:
+		funcs := sprig.TxtFuncMap()
+		for _, name := range sprigNonHermeticFuncs {
+			delete(funcs, name)
+		}
+
+		return funcs
+	default:
+		return sprig.TxtFuncMap()
+	}
+}
+
+// PlainOptions represents the settings of the Go Template interpreter
+type PlainOptions struct {
+	// Sprig defines the Sprig functions available in the template. Defaults to SprigFull
+	Sprig Sprig
+	// LeftDelim and RightDelim replace the `{{` and `}}` action delimiters when set
+	LeftDelim  string
+	RightDelim string
+	// Strict fails the evaluation when the template references a variable that isn't defined
+	Strict bool
+	// Includes lists glob patterns of additional template files made available by name to the
+	// template. The name of an included template is its file name without extension
+	Includes []string
+}
+
+// Plain represents the Go Template interpreter
+type Plain struct {
+	vars map[string]interface{}
+	used []string
+	opts PlainOptions
+}
+
+// NewPlain builds a new Go Template interpreter
+func NewPlain() *Plain {
+	return &Plain{vars: make(map[string]interface{})}
+}
+
+// Configure sets the options of the interpreter
+func (g *Plain) Configure(opts PlainOptions) {
+	g.opts = opts
+}
+
+// AddVar stores a new variable
+func (g *Plain) AddVar(name string, value string) {
+	g.vars[name] = value
+}
+
+// AddData stores a new variable holding the data of the JSON value, so the template can read its
+// fields (e.g. '.database.host')
+func (g *Plain) AddData(name string, data string) error {
+	value, err := decodeData(data)
+	if err != nil {
+		return err
+	}
+
+	g.vars[name] = value
+
+	return nil
+}
+
+// Evaluate executes the template with all the variable previously stored accessible
+func (g *Plain) Evaluate(tpl string) (string, error) {
+	var buf strings.Builder
+	if err := g.EvaluateTo(&buf, tpl); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// EvaluateTo executes the template like Evaluate, writing the content to the writer as it's
+// rendered
+func (g *Plain) EvaluateTo(w io.Writer, tpl string) error {
+	used, err := evaluateGoTemplate("plain", g.newTemplate, w, tpl, g.opts.Includes, g.vars)
+	if err != nil {
+		return err
+	}
+
+	g.used = used
+
+	return nil
+}
+
+// CheckSyntax parses the template and its includes without executing them. The error reports
+// the line of the invalid action
+func (g *Plain) CheckSyntax(tpl string) error {
+	_, err := parseGoTemplate("plain", g.newTemplate, tpl, g.opts.Includes)
+
+	return err
+}
+
+// newTemplate builds the empty template configured with the options of the interpreter
+func (g *Plain) newTemplate() goTemplate {
+	return textTemplate{t: template.New("").
+		Option(missingKeyOption(g.opts.Strict)).
+		Delims(g.opts.LeftDelim, g.opts.RightDelim).
+		Funcs(g.funcs())}
+}
+
+// IsText returns true, the content of a Go Template being plain text
+func (g *Plain) IsText() bool {
+	return true
+}
+
+// UsedVars returns the names of the variables referenced by the last evaluated template and its
+// includes using '.NAME', '$.NAME', 'var "NAME"', 'index . "NAME"' or 'index vars "NAME"'. The
+// fields read inside 'range' and 'with' are reported as well, even when the dot isn't the
+// variables anymore, but ranging over the dot or 'vars' doesn't report any variable
+func (g *Plain) UsedVars() []string {
+	return g.used
+}
+
+// collectPlainVars walks the template tree to find the variable names it references
+func collectPlainVars(node parse.Node, used map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		for _, child := range node.Nodes {
+			collectPlainVars(child, used)
+		}
+	case *parse.ActionNode:
+		collectPlainVars(node.Pipe, used)
+	case *parse.TemplateNode:
+		collectPlainVars(node.Pipe, used)
+	case *parse.IfNode:
+		collectPlainBranchVars(&node.BranchNode, used)
+	case *parse.RangeNode:
+		collectPlainBranchVars(&node.BranchNode, used)
+	case *parse.WithNode:
+		collectPlainBranchVars(&node.BranchNode, used)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+
+		for _, cmd := range node.Cmds {
+			collectPlainVars(cmd, used)
+		}
+	case *parse.CommandNode:
+		if name, ok := plainVarCall(node.Args); ok {
+			used[name] = true
+		}
+
+		for _, arg := range node.Args {
+			collectPlainVars(arg, used)
+		}
+	case *parse.ChainNode:
+		collectPlainVars(node.Node, used)
+	case *parse.FieldNode:
+		used[node.Ident[0]] = true
+	case *parse.VariableNode:
+		if len(node.Ident) > 1 && node.Ident[0] == "$" {
+			used[node.Ident[1]] = true
+		}
+	}
+}
+
+func collectPlainBranchVars(node *parse.BranchNode, used map[string]bool) {
+	collectPlainVars(node.Pipe, used)
+	collectPlainVars(node.List, used)
+	collectPlainVars(node.ElseList, used)
+}
+
+// plainVarCall returns the variable name of the 'var "NAME"', 'index . "NAME"' and
+// 'index vars "NAME"' commands
+func plainVarCall(args []parse.Node) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+
+	function, ok := args[0].(*parse.IdentifierNode)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case function.Ident == "var":
+		name, ok := args[1].(*parse.StringNode)
+		if ok {
+			return name.Text, true
+		}
+	case function.Ident == "index" && len(args) == 3:
+		_, isDot := args[1].(*parse.DotNode)
+		if identifier, isIdentifier := args[1].(*parse.IdentifierNode); isIdentifier && identifier.Ident == "vars" {
+			isDot = true
+		}
+
+		name, ok := args[2].(*parse.StringNode)
+		if isDot && ok {
+			return name.Text, true
+		}
+	}
+
+	return "", false
+}
+
+// funcs returns the Sprig functions enabled by the options along with `var`, returning the value of
+// a variable by name, `vars`, returning all the variables as a map, and `default`, when Sprig
+// doesn't provide it
+func (g *Plain) funcs() template.FuncMap {
+	return templateFuncs(g.opts.Sprig, g.vars, g.opts.Strict)
+}
+
+// templateFuncs returns the functions of the plain and html templates. `var` returns an empty
+// string for an undefined variable, or fails when strict
+func templateFuncs(s Sprig, vars map[string]interface{}, strict bool) template.FuncMap {
+	funcs := s.funcs()
+
+	funcs["var"] = func(name string) (string, error) {
+		value, found := vars[name]
+		if !found {
+			if strict {
+				return "", fmt.Errorf("variable '%s' isn't defined", name)
+			}
+
+			return "", nil
+		}
+
+		if text, isText := value.(string); isText {
+			return text, nil
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("can't encode variable '%s' as JSON: %v", name, err)
+		}
+
+		return string(encoded), nil
+	}
+
+	funcs["vars"] = func() map[string]interface{} {
+		return vars
+	}
+
+	if _, found := funcs["default"]; !found {
+		funcs["default"] = plainDefault
+	}
+
+	return funcs
+}
+
+// plainDefault returns the default value when the given value is missing or empty, like the Sprig
+// function it replaces for strings, numbers, booleans and nil
+func plainDefault(defaultValue interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 {
+		return defaultValue
+	}
+
+	switch value := given[0].(type) {
+	case nil:
+		return defaultValue
+	case string:
+		if value == "" {
+			return defaultValue
+		}
+	case bool:
+		if !value {
+			return defaultValue
+		}
+	case int:
+		if value == 0 {
+			return defaultValue
+		}
+	case float64:
+		if value == 0 {
+			return defaultValue
+		}
+	}
+
+	return given[0]
+}
+
+// goTemplate is a text/template or html/template template, so the plain and html interpreters
+// share the parsing of the template and its includes
+type goTemplate interface {
+	// define parses the text as the template of the name, the main template for an empty name
+	define(name string, text string) error
+	execute(w io.Writer, data interface{}) error
+	// trees returns the parsed trees of the main template and its includes
+	trees() []*parse.Tree
+}
+
+// textTemplate is the goTemplate of the text/template package
+type textTemplate struct {
+	t *template.Template
+}
+
+func (t textTemplate) define(name string, text string) error {
+	if name == "" {
+		_, err := t.t.Parse(text)
+		return err
+	}
+
+	_, err := t.t.New(name).Parse(text)
+
+	return err
+}
+
+func (t textTemplate) execute(w io.Writer, data interface{}) error {
+	return t.t.Execute(w, data)
+}
+
+func (t textTemplate) trees() []*parse.Tree {
+	var trees []*parse.Tree
+	for _, defined := range t.t.Templates() {
+		if defined.Tree != nil {
+			trees = append(trees, defined.Tree)
+		}
+	}
+
+	return trees
+}
+
+// missingKeyOption returns the template option handling the undefined variables
+func missingKeyOption(strict bool) string {
+	if strict {
+		return "missingkey=error"
+	}
+
+	return "missingkey=default"
+}
+
+// parseGoTemplate parses the template and the files of the include patterns in a template built by
+// newTemplate. The kind names the interpreter in the errors
+func parseGoTemplate(kind string, newTemplate func() goTemplate, tpl string, patterns []string) (goTemplate, error) {
+	t := newTemplate()
+	if err := t.define("", tpl); err != nil {
+		return nil, fmt.Errorf("can't parse %s template: %v", kind, err)
+	}
+
+	includes, err := readIncludes(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, included := range includes {
+		if err := t.define(included.name, included.content); err != nil {
+			return nil, fmt.Errorf("can't parse included template '%s': %v", included.path, err)
+		}
+	}
+
+	return t, nil
+}
+
+// evaluateGoTemplate parses the template like parseGoTemplate and executes it, returning the names
+// of the variables referenced by the template and its includes
+func evaluateGoTemplate(kind string, newTemplate func() goTemplate, w io.Writer, tpl string, patterns []string, vars map[string]interface{}) ([]string, error) {
+	t, err := parseGoTemplate(kind, newTemplate, tpl, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.execute(w, vars); err != nil {
+		return nil, fmt.Errorf("can't evaluate %s template: %v", kind, err)
+	}
+
+	used := make(map[string]bool)
+	for _, tree := range t.trees() {
+		collectPlainVars(tree.Root, used)
+	}
+
+	return sortedNames(used), nil
+}
+
+// include represents a template file made available by name to the template
+type include struct {
+	name    string
+	path    string
+	content string
+}
+
+// readIncludes reads the template files matching the patterns. The name of an included template is
+// its file name without extension
+func readIncludes(patterns []string) ([]include, error) {
+	var includes []include
+	sources := make(map[string]string)
+
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern '%s': %v", pattern, err)
+		}
+
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("include pattern '%s' doesn't match any file", pattern)
+		}
+
+		for _, p := range paths {
+			name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+			if source, found := sources[name]; found {
+				if source == p {
+					continue
+				}
+
+				return nil, fmt.Errorf("included template '%s' is defined by both '%s' and '%s'", name, source, p)
+			}
+			sources[name] = p
+
+			content, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("can't read included template '%s': %v", p, err)
+			}
+
+			includes = append(includes, include{name: name, path: p, content: string(content)})
+		}
+	}
+
+	return includes, nil
+}
+
+// decodeData returns the value of the JSON data, keeping the numbers as json.Number so they're
+// written as given
+func decodeData(data string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	return value, nil
+}
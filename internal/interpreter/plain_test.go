@@ -0,0 +1,329 @@
+package interpreter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestPlainSprig(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Sprig         interpreter.Sprig
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "b64enc",
+			Template: "{{ .PASSWORD | b64enc }}",
+			Expected: "c3NzaCE=",
+		},
+		{
+			Name:     "default",
+			Template: `{{ .MISSING | default "8080" }}:{{ .PORT | default "8080" }}`,
+			Expected: "8080:1337",
+		},
+		{
+			Name:     "quote",
+			Template: "{{ .PASSWORD | quote }}",
+			Expected: `"sssh!"`,
+		},
+		{
+			Name:     "hermetic",
+			Sprig:    interpreter.SprigHermetic,
+			Template: "{{ .PASSWORD | upper | trim }}",
+			Expected: "SSSH!",
+		},
+		{
+			Name:          "hermetic non reproducible",
+			Sprig:         interpreter.SprigHermetic,
+			Template:      "{{ uuidv4 }}",
+			ExpectedError: `function "uuidv4" not defined`,
+		},
+		{
+			Name:          "none",
+			Sprig:         interpreter.SprigNone,
+			Template:      "{{ .PASSWORD | b64enc }}",
+			ExpectedError: `function "b64enc" not defined`,
+		},
+		{
+			Name:     "var default",
+			Template: `{{ var "MISSING" | default "8080" }}:{{ var "PORT" | default "8080" }}:{{ var "EMPTY" | default "none" }}`,
+			Expected: "8080:1337:none",
+		},
+		{
+			Name:     "none var default",
+			Sprig:    interpreter.SprigNone,
+			Template: `{{ var "MISSING" | default "8080" }}:{{ var "PORT" | default "8080" }}:{{ var "EMPTY" | default "none" }}`,
+			Expected: "8080:1337:none",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			plain := interpreter.NewPlain()
+			plain.Configure(interpreter.PlainOptions{Sprig: tc.Sprig})
+			plain.AddVar("PASSWORD", "sssh!")
+			plain.AddVar("PORT", "1337")
+			plain.AddVar("EMPTY", "")
+
+			output, err := plain.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestPlainVarsMap(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Strict        bool
+		Template      string
+		Expected      string
+		ExpectedUsed  []string
+		ExpectedError string
+	}{
+		{
+			Name:         "index",
+			Template:     `{{ index vars "log-level" }} {{ index . "app.kubernetes.io/name" }} {{ var "log-level" }}`,
+			Expected:     "debug api debug",
+			ExpectedUsed: []string{"app.kubernetes.io/name", "log-level"},
+		},
+		{
+			Name:         "range",
+			Template:     `{{ range $name, $value := vars }}{{ $name }}={{ $value }};{{ end }}`,
+			Expected:     "app.kubernetes.io/name=api;log-level=debug;",
+			ExpectedUsed: []string{},
+		},
+		{
+			Name:         "missing",
+			Template:     `[{{ var "MISSING" }}]`,
+			Expected:     "[]",
+			ExpectedUsed: []string{"MISSING"},
+		},
+		{
+			Name:          "strict missing",
+			Strict:        true,
+			Template:      `[{{ var "MISSING" }}]`,
+			ExpectedError: "variable 'MISSING' isn't defined",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			plain := interpreter.NewPlain()
+			plain.Configure(interpreter.PlainOptions{Strict: tc.Strict})
+			plain.AddVar("log-level", "debug")
+			plain.AddVar("app.kubernetes.io/name", "api")
+
+			output, err := plain.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+
+			if !reflect.DeepEqual(tc.ExpectedUsed, plain.UsedVars()) {
+				t.Fatalf("invalid used variables\nexpected:\n%v\nactual:\n%v\n", tc.ExpectedUsed, plain.UsedVars())
+			}
+		})
+	}
+}
+
+func TestPlainAddData(t *testing.T) {
+	plain := interpreter.NewPlain()
+	plain.AddVar("name", "api")
+
+	if err := plain.AddData("database", `{"host": "localhost", "port": 5432, "tags": ["a", "b"]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := plain.Evaluate(`{{ .name }} {{ .database.host }}:{{ .database.port }} {{ index .database.tags 1 }} {{ var "database" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `api localhost:5432 b {"host":"localhost","port":5432,"tags":["a","b"]}`; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+
+	if err := plain.AddData("broken", `{"host":`); err == nil || !strings.HasPrefix(err.Error(), "invalid JSON: ") {
+		t.Fatalf("invalid error\nexpected:\ninvalid JSON: ...\nactual:\n%v\n", err)
+	}
+}
+
+func TestPlainDelims(t *testing.T) {
+	plain := interpreter.NewPlain()
+	plain.Configure(interpreter.PlainOptions{LeftDelim: "[[", RightDelim: "]]"})
+	plain.AddVar("PORT", "1337")
+
+	output, err := plain.Evaluate("port: [[ .PORT ]]\nhelm: {{ .Values.port }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "port: 1337\nhelm: {{ .Values.port }}"; expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+}
+
+func TestPlainStrict(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Strict        bool
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "lenient",
+			Strict:   false,
+			Expected: "1337 <no value>",
+		},
+		{
+			Name:          "strict",
+			Strict:        true,
+			ExpectedError: `map has no entry for key "DATABASE_PASSWORD"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			plain := interpreter.NewPlain()
+			plain.Configure(interpreter.PlainOptions{Strict: tc.Strict})
+			plain.AddVar("PORT", "1337")
+
+			output, err := plain.Evaluate("{{ .PORT }} {{ .DATABASE_PASSWORD }}")
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestPlainIncludes(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"partials/header.tpl":  "# generated for {{ .NAME }}",
+		"partials/footer.tmpl": "# end",
+		"other/header.txt":     "# other header",
+	}
+
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("can't create folder: %v", err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+
+	tcs := []struct {
+		Name          string
+		Includes      []string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "includes",
+			Includes: []string{filepath.Join(root, "partials", "*")},
+			Expected: "# generated for api\nport: 1337\n# end",
+		},
+		{
+			Name:          "clash",
+			Includes:      []string{filepath.Join(root, "partials", "*"), filepath.Join(root, "other", "*")},
+			ExpectedError: "included template 'header' is defined by both",
+		},
+		{
+			Name:          "no match",
+			Includes:      []string{filepath.Join(root, "missing", "*")},
+			ExpectedError: "doesn't match any file",
+		},
+	}
+
+	// The html interpreter shares the includes of the plain one
+	runtimes := map[string]func(interpreter.PlainOptions) interpreter.Interpreter{
+		"plain": func(opts interpreter.PlainOptions) interpreter.Interpreter {
+			runtime := interpreter.NewPlain()
+			runtime.Configure(opts)
+			return runtime
+		},
+		"html": func(opts interpreter.PlainOptions) interpreter.Interpreter {
+			runtime := interpreter.NewHTML()
+			runtime.Configure(opts)
+			return runtime
+		},
+	}
+
+	for _, tc := range tcs {
+		for name, newRuntime := range runtimes {
+			t.Run(tc.Name+" "+name, func(t *testing.T) {
+				testPlainIncludes(t, newRuntime(interpreter.PlainOptions{Includes: tc.Includes}), tc.Expected, tc.ExpectedError)
+			})
+		}
+	}
+}
+
+func testPlainIncludes(t *testing.T, runtime interpreter.Interpreter, expected string, expectedError string) {
+	runtime.AddVar("NAME", "api")
+	runtime.AddVar("PORT", "1337")
+
+	output, err := runtime.Evaluate(`{{ template "header" . }}` + "\nport: {{ .PORT }}\n" + `{{ template "footer" }}`)
+	if expectedError != "" {
+		if err == nil || !strings.Contains(err.Error(), expectedError) {
+			t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expectedError, err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected != output {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, output)
+	}
+}
@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+)
+
+// StarlarkConfigGlobal is the name of the global holding the value produced by a Starlark template
+const StarlarkConfigGlobal = "config"
+
+// StarlarkVarsGlobal is the name of the dict holding the variables in a Starlark template
+const StarlarkVarsGlobal = "vars"
+
+// Starlark represents the Starlark interpreter. Templates are executed without access to the
+// filesystem or the network: 'load' statements are rejected and only the 'json' module is available
+type Starlark struct {
+	vars map[string]string
+}
+
+// NewStarlark builds a new Starlark interpreter
+func NewStarlark() *Starlark {
+	return &Starlark{vars: make(map[string]string)}
+}
+
+// AddVar stores a new variable, available in the 'vars' dict of the next evaluations
+func (s *Starlark) AddVar(name string, value string) {
+	s.vars[name] = value
+}
+
+// Evaluate executes the template with all the variable previously stored accessible in the 'vars'
+// dict and returns the value of its 'config' global encoded as JSON
+func (s *Starlark) Evaluate(tpl string) (string, error) {
+	thread := &starlark.Thread{Name: "cfgenerator"}
+
+	// The dict is built for each evaluation and frozen so the template can't modify it
+	vars := starlark.NewDict(len(s.vars))
+	for name, value := range s.vars {
+		// SetKey can only fail on unhashable keys or frozen dicts, the keys are strings and the dict
+		// isn't frozen yet
+		_ = vars.SetKey(starlark.String(name), starlark.String(value))
+	}
+	vars.Freeze()
+
+	predeclared := starlark.StringDict{
+		StarlarkVarsGlobal: vars,
+		"json":             starlarkjson.Module,
+	}
+
+	globals, err := starlark.ExecFile(thread, "template.star", tpl, predeclared)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return "", fmt.Errorf("can't evaluate starlark template: %v", evalErr.Backtrace())
+		}
+
+		return "", fmt.Errorf("can't evaluate starlark template: %v", err)
+	}
+
+	config, found := globals[StarlarkConfigGlobal]
+	if !found {
+		return "", fmt.Errorf("can't evaluate starlark template: the template must define a '%s' global", StarlarkConfigGlobal)
+	}
+
+	encoded, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{config}, nil)
+	if err != nil {
+		return "", fmt.Errorf("can't encode starlark '%s' as JSON: %v", StarlarkConfigGlobal, err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(string(encoded.(starlark.String))), "", "   "); err != nil {
+		return "", fmt.Errorf("can't encode starlark '%s' as JSON: %v", StarlarkConfigGlobal, err)
+	}
+
+	return indented.String() + "\n", nil
+}
+
+// CheckSyntax parses the template and resolves its names without executing it. The error starts
+// with the 'template.star:line:column' of the invalid token
+func (s *Starlark) CheckSyntax(tpl string) error {
+	isPredeclared := func(name string) bool { return name == StarlarkVarsGlobal || name == "json" }
+
+	if _, _, err := starlark.SourceProgram("template.star", tpl, isPredeclared); err != nil {
+		return fmt.Errorf("can't parse starlark template: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+func TestStarlark(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Template      string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "config",
+			Template: "config = {'port': int(vars['API_PORT']), 'hosts': [h.strip() for h in vars['HOSTS'].split(',')]}",
+			Expected: "{\n   \"hosts\": [\n      \"a\",\n      \"b\"\n   ],\n   \"port\": 1337\n}\n",
+		},
+		{
+			Name:     "json module",
+			Template: "config = json.decode(vars['HOSTS_JSON'])",
+			Expected: "[\n   \"a\",\n   \"b\"\n]\n",
+		},
+		{
+			Name:          "missing config",
+			Template:      "port = vars['API_PORT']",
+			ExpectedError: "can't evaluate starlark template: the template must define a 'config' global",
+		},
+		{
+			Name:          "missing variable",
+			Template:      "config = vars['UNKNOWN']",
+			ExpectedError: `key "UNKNOWN" not in dict`,
+		},
+		{
+			Name:          "load",
+			Template:      "load('secrets.star', 'password')\nconfig = password",
+			ExpectedError: "load not implemented by this application",
+		},
+		{
+			Name:          "frozen variables",
+			Template:      "vars['API_PORT'] = '80'\nconfig = vars",
+			ExpectedError: "cannot insert into frozen hash table",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			runtime := interpreter.NewStarlark()
+			runtime.AddVar("API_PORT", "1337")
+			runtime.AddVar("HOSTS", "a, b")
+			runtime.AddVar("HOSTS_JSON", `["a", "b"]`)
+
+			actual, err := runtime.Evaluate(tc.Template)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestStarlarkAddVarAfterEvaluate(t *testing.T) {
+	runtime := interpreter.NewStarlark()
+	runtime.AddVar("API_PORT", "1337")
+
+	if _, err := runtime.Evaluate("config = vars['API_PORT']"); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime.AddVar("DATABASE_USERNAME", "myapp")
+
+	actual, err := runtime.Evaluate("config = [vars['API_PORT'], vars['DATABASE_USERNAME']]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[\n   \"1337\",\n   \"myapp\"\n]\n"
+	if actual != expected {
+		t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", expected, actual)
+	}
+}
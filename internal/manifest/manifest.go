@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind represents the kind of the Kubernetes object wrapping the generated content
+type Kind string
+
+const (
+	// Secret wraps the content in a Secret, base64 encoded in its data
+	Secret Kind = "secret"
+	// ConfigMap wraps the content in a ConfigMap, as is in its data or base64 encoded in its
+	// binaryData when it isn't valid UTF-8
+	ConfigMap Kind = "configmap"
+)
+
+// DefaultKey is the key holding the content when Options.Key is empty
+const DefaultKey = "config"
+
+var (
+	subdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	labelPattern     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	keyPattern       = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// ParseKind returns the Kind matching the given name
+func ParseKind(name string) (Kind, error) {
+	switch kind := Kind(name); kind {
+	case Secret, ConfigMap:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unsupported wrap kind '%s'", name)
+	}
+}
+
+// Options represents the metadata of the wrapping object
+type Options struct {
+	// Name is the name of the object, a DNS subdomain (e.g. 'api-config')
+	Name string
+	// Namespace is the namespace of the object, a DNS label. The metadata has no namespace when
+	// empty, so the one of the kubectl context is used
+	Namespace string
+	// Key is the data key holding the content. Defaults to DefaultKey
+	Key string
+}
+
+// Validate ensures the name, the namespace and the key are accepted by Kubernetes
+func (o Options) Validate() error {
+	if len(o.Name) > 253 || !subdomainPattern.MatchString(o.Name) {
+		return fmt.Errorf("invalid name '%s': expected a DNS subdomain: lowercase letters, digits, '-' and '.'", o.Name)
+	}
+
+	if o.Namespace != "" && (len(o.Namespace) > 63 || !labelPattern.MatchString(o.Namespace)) {
+		return fmt.Errorf("invalid namespace '%s': expected a DNS label: lowercase letters, digits and '-'", o.Namespace)
+	}
+
+	if o.Key != "" && (len(o.Key) > 253 || !keyPattern.MatchString(o.Key) || o.Key == "." || o.Key == "..") {
+		return fmt.Errorf("invalid key '%s': expected letters, digits, '-', '_' and '.'", o.Key)
+	}
+
+	return nil
+}
+
+type object struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   metadata          `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	BinaryData map[string]string `yaml:"binaryData,omitempty"`
+}
+
+type metadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// Wrap returns the YAML manifest of the Kubernetes object of the kind holding the content in its
+// single key
+func Wrap(content string, kind Kind, opts Options) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", fmt.Errorf("can't wrap content: %v", err)
+	}
+
+	key := opts.Key
+	if key == "" {
+		key = DefaultKey
+	}
+
+	manifest := object{
+		APIVersion: "v1",
+		Metadata:   metadata{Name: opts.Name, Namespace: opts.Namespace},
+	}
+
+	switch kind {
+	case Secret:
+		manifest.Kind = "Secret"
+		manifest.Type = "Opaque"
+		manifest.Data = map[string]string{key: base64.StdEncoding.EncodeToString([]byte(content))}
+	case ConfigMap:
+		manifest.Kind = "ConfigMap"
+		if utf8.ValidString(content) {
+			manifest.Data = map[string]string{key: content}
+		} else {
+			manifest.BinaryData = map[string]string{key: base64.StdEncoding.EncodeToString([]byte(content))}
+		}
+	default:
+		return "", fmt.Errorf("can't wrap content: unsupported wrap kind '%s'", kind)
+	}
+
+	var buf strings.Builder
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(manifest); err != nil {
+		return "", fmt.Errorf("can't wrap content: %v", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("can't wrap content: %v", err)
+	}
+
+	return buf.String(), nil
+}
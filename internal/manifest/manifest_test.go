@@ -0,0 +1,85 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/manifest"
+)
+
+func TestWrap(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Kind          manifest.Kind
+		Options       manifest.Options
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "secret",
+			Content:  "{\n   \"port\": 1337\n}\n",
+			Kind:     manifest.Secret,
+			Options:  manifest.Options{Name: "api-config", Namespace: "production"},
+			Expected: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: api-config\n  namespace: production\ntype: Opaque\ndata:\n  config: ewogICAicG9ydCI6IDEzMzcKfQo=\n",
+		},
+		{
+			Name:     "configmap",
+			Content:  "{\n   \"port\": 1337\n}\n",
+			Kind:     manifest.ConfigMap,
+			Options:  manifest.Options{Name: "api-config", Key: "config.json"},
+			Expected: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: api-config\ndata:\n  config.json: |\n    {\n       \"port\": 1337\n    }\n",
+		},
+		{
+			Name:     "configmap binary",
+			Content:  "\xff\xfe",
+			Kind:     manifest.ConfigMap,
+			Options:  manifest.Options{Name: "api-config"},
+			Expected: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: api-config\nbinaryData:\n  config: //4=\n",
+		},
+		{
+			Name:          "invalid name",
+			Kind:          manifest.Secret,
+			Options:       manifest.Options{Name: "API_config"},
+			ExpectedError: "can't wrap content: invalid name 'API_config': expected a DNS subdomain: lowercase letters, digits, '-' and '.'",
+		},
+		{
+			Name:          "invalid namespace",
+			Kind:          manifest.Secret,
+			Options:       manifest.Options{Name: "api", Namespace: "prod.eu"},
+			ExpectedError: "can't wrap content: invalid namespace 'prod.eu': expected a DNS label: lowercase letters, digits and '-'",
+		},
+		{
+			Name:          "invalid key",
+			Kind:          manifest.Secret,
+			Options:       manifest.Options{Name: "api", Key: "config/app.json"},
+			ExpectedError: "can't wrap content: invalid key 'config/app.json': expected letters, digits, '-', '_' and '.'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			output, err := manifest.Wrap(tc.Content, tc.Kind, tc.Options)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != output {
+				t.Fatalf("invalid output\nexpected:\n'%s'\nactual:\n'%s'\n", tc.Expected, output)
+			}
+		})
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	if _, err := manifest.ParseKind("deployment"); err == nil || err.Error() != "unsupported wrap kind 'deployment'" {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", "unsupported wrap kind 'deployment'", err)
+	}
+}
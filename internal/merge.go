@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// mergeContents deep merges the JSON objects of the overlay into the ones of the base: the keys of
+// both objects are merged recursively, any other value of the overlay, arrays included, replaces the
+// value of the base
+func mergeContents(base string, overlay string, index int) (string, error) {
+	var baseValue, overlayValue interface{}
+
+	if err := decodeObject(base, &baseValue); err != nil {
+		return "", fmt.Errorf("can't merge templates: base %v", err)
+	}
+
+	if err := decodeObject(overlay, &overlayValue); err != nil {
+		return "", fmt.Errorf("can't merge templates: overlay %d %v", index+1, err)
+	}
+
+	var merged bytes.Buffer
+	encoder := json.NewEncoder(&merged)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "   ")
+
+	if err := encoder.Encode(deepMerge(baseValue, overlayValue)); err != nil {
+		return "", fmt.Errorf("can't merge templates: %v", err)
+	}
+
+	return merged.String(), nil
+}
+
+func decodeObject(content string, value *interface{}) error {
+	decoder := json.NewDecoder(bytes.NewBufferString(content))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("isn't valid JSON: %v", err)
+	}
+
+	if _, ok := (*value).(map[string]interface{}); !ok {
+		return fmt.Errorf("must produce an object")
+	}
+
+	return nil
+}
+
+func deepMerge(base interface{}, overlay interface{}) interface{} {
+	baseObject, isBaseObject := base.(map[string]interface{})
+	overlayObject, isOverlayObject := overlay.(map[string]interface{})
+
+	if !isBaseObject || !isOverlayObject {
+		return overlay
+	}
+
+	for key, value := range overlayObject {
+		if baseValue, found := baseObject[key]; found {
+			value = deepMerge(baseValue, value)
+		}
+
+		baseObject[key] = value
+	}
+
+	return baseObject
+}
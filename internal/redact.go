@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+// RedactedValue replaces the variable values found in the returned errors
+const RedactedValue = "<redacted>"
+
+// minRedactedLength is the length under which values aren't redacted, they would hide too many
+// unrelated parts of the errors (e.g. a "1" value) without protecting anything
+const minRedactedLength = 4
+
+// redactError replaces the values of the variables found in the error message so a template or
+// interpreter error quoting a secret doesn't leak it in the logs. The values are also redacted
+// once escaped, an interpreter quoting a value with Go or JSON escapes. Errors shouldn't contain
+// values in the first place, this is a last line of defense
+func redactError(err error, variables *variable.Set) error {
+	if err == nil {
+		return nil
+	}
+
+	if validationErr, ok := err.(ValidationError); ok {
+		return ValidationError{Err: redactError(validationErr.Err, variables)}
+	}
+
+	var values []string
+	for _, v := range variables.List() {
+		candidates := append([]string{v.Value, strings.TrimSpace(v.Value)}, jsonStrings(v.Value)...)
+		for _, candidate := range candidates {
+			if len(candidate) >= minRedactedLength {
+				values = append(values, candidate)
+				values = append(values, escapedStrings(candidate)...)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return err
+	}
+
+	// Replace the longest values first so a value containing another one is redacted as a whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	replacements := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		replacements = append(replacements, value, RedactedValue)
+	}
+
+	message := err.Error()
+	redacted := strings.NewReplacer(replacements...).Replace(message)
+	if redacted == message {
+		return err
+	}
+
+	return errors.New(redacted)
+}
+
+// escapedStrings returns the value escaped by strconv.Quote and by the JSON encoder, without the
+// surrounding quotes so the value is found even when it's only a part of a quoted string
+func escapedStrings(value string) []string {
+	quoted := strconv.Quote(value)
+	escaped := []string{quoted[1 : len(quoted)-1]}
+
+	// The JSON encoders may or may not escape the HTML characters, e.g. `<` as `\u003c`
+	for _, escapeHTML := range []bool{true, false} {
+		var buf strings.Builder
+
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(escapeHTML)
+		if err := encoder.Encode(value); err != nil {
+			continue
+		}
+
+		encoded := strings.TrimSuffix(buf.String(), "\n")
+		escaped = append(escaped, encoded[1:len(encoded)-1])
+	}
+
+	return escaped
+}
+
+// jsonStrings returns the strings of the value when it's a JSON array or object, like the groups
+// and JSON variables, so they are redacted even when the interpreter quotes a single one of them
+func jsonStrings(value string) []string {
+	if !strings.HasPrefix(value, "{") && !strings.HasPrefix(value, "[") {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil
+	}
+
+	var strs []string
+	var walk func(interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case string:
+			strs = append(strs, value)
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(decoded)
+
+	return strs
+}
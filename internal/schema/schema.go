@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Schema represents a compiled JSON Schema
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Load reads and compiles the JSON Schema stored in the file. References to other schemas are
+// resolved relatively to the file
+func Load(path string) (*Schema, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve schema path '%s': %v", path, err)
+	}
+
+	compiled, err := jsonschema.Compile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't compile schema '%s': %v", path, err)
+	}
+
+	return &Schema{schema: compiled}, nil
+}
+
+// Validate checks the JSON content matches the schema. The error lists all the violations, one per
+// line, with the JSON pointer of the invalid value and the reason
+func (s *Schema) Validate(content string) error {
+	decoder := json.NewDecoder(bytes.NewBufferString(content))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("can't validate content: invalid JSON: %v", err)
+	}
+
+	err := s.schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return fmt.Errorf("can't validate content: %v", err)
+	}
+
+	var violations []string
+	collectViolations(validationErr, &violations)
+
+	return fmt.Errorf("content doesn't match the schema:\n%s", strings.Join(violations, "\n"))
+}
+
+func collectViolations(err *jsonschema.ValidationError, violations *[]string) {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+
+		*violations = append(*violations, fmt.Sprintf("- %s: %s", location, err.Message))
+		return
+	}
+
+	for _, cause := range err.Causes {
+		collectViolations(cause, violations)
+	}
+}
@@ -0,0 +1,78 @@
+package schema_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/schema"
+)
+
+const apiSchema = `{
+  "type": "object",
+  "required": ["api", "database"],
+  "properties": {
+    "api": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer", "minimum": 1}
+      }
+    },
+    "database": {
+      "type": "object",
+      "required": ["username"]
+    }
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := ioutil.WriteFile(path, []byte(apiSchema), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	s, err := schema.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		Name          string
+		Content       string
+		ExpectedError string
+	}{
+		{Name: "valid", Content: `{"api": {"port": 1337}, "database": {"username": "myapp"}}`},
+		{
+			Name:          "invalid",
+			Content:       `{"api": {"port": "1337"}, "database": {}}`,
+			ExpectedError: "content doesn't match the schema:\n- /api/port: expected integer, but got string\n- /database: missing properties: 'username'",
+		},
+		{
+			Name:          "missing root property",
+			Content:       `{"api": {"port": 0}}`,
+			ExpectedError: "content doesn't match the schema:\n- /: missing properties: 'database'\n- /api/port: must be >= 1 but found 0",
+		},
+		{
+			Name:          "not JSON",
+			Content:       `upstream api {}`,
+			ExpectedError: "can't validate content: invalid JSON: invalid character 'u' looking for beginning of value",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := s.Validate(tc.Content)
+			if tc.ExpectedError == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.ExpectedError {
+				t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+			}
+		})
+	}
+}
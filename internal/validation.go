@@ -0,0 +1,19 @@
+package internal
+
+// ValidationError reports that the variables or the generated content don't satisfy the
+// requirements of the generation (e.g. a missing required variable or a content not matching the
+// schema), as opposed to an error reading the sources or evaluating the template
+type ValidationError struct {
+	Err error
+}
+
+func (e ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// IsValidationError returns whether the error is a ValidationError
+func IsValidationError(err error) bool {
+	_, ok := err.(ValidationError)
+
+	return ok
+}
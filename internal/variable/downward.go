@@ -0,0 +1,62 @@
+package variable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxDownwardLineSize is the maximum size of a line of a Downward API file. An annotation value can
+// be much larger than the default line limit of bufio.Scanner, e.g. the
+// `kubectl.kubernetes.io/last-applied-configuration` one
+const maxDownwardLineSize = 1024 * 1024
+
+// LoadDownwardFile reads the variables of a Downward API labels or annotations file. See
+// ParseDownward for the supported syntax
+func LoadDownwardFile(path string) ([]Variable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseDownward(f, path)
+}
+
+// ParseDownward reads the `key="value"` lines written by the Kubernetes Downward API for the
+// `metadata.labels` and `metadata.annotations` fields. Each key is a variable named after the label
+// or annotation (e.g. `app.kubernetes.io/name`) whose value is unquoted following the Go syntax
+// used by Kubernetes. Empty lines are ignored
+func ParseDownward(r io.Reader, source string) ([]Variable, error) {
+	var variables []Variable
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxDownwardLineSize)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("line %d: expected key=\"value\"", lineNumber)
+		}
+
+		value, err := strconv.Unquote(parts[1])
+		if err != nil || !strings.HasPrefix(parts[1], `"`) {
+			return nil, fmt.Errorf("line %d: invalid value of '%s': expected a double-quoted string", lineNumber, parts[0])
+		}
+
+		variables = append(variables, Variable{Name: parts[0], Value: value, Source: fmt.Sprintf("%s:%d", source, lineNumber)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
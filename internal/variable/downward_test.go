@@ -0,0 +1,85 @@
+package variable_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+func TestParseDownward(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name:    "labels",
+			Content: "app=\"api\"\napp.kubernetes.io/name=\"api\"\n\npod-template-hash=\"\"\n",
+			Expected: []variable.Variable{
+				{Name: "app", Value: "api", Source: "labels:1"},
+				{Name: "app.kubernetes.io/name", Value: "api", Source: "labels:2"},
+				{Name: "pod-template-hash", Value: "", Source: "labels:4"},
+			},
+		},
+		{
+			Name:    "escaped annotations",
+			Content: `description="a \"quoted\" value\nwith = signs and a \\ backslash"` + "\n" + `owner="équipe"`,
+			Expected: []variable.Variable{
+				{Name: "description", Value: "a \"quoted\" value\nwith = signs and a \\ backslash", Source: "labels:1"},
+				{Name: "owner", Value: "équipe", Source: "labels:2"},
+			},
+		},
+		{
+			Name:          "missing separator",
+			Content:       "app=\"api\"\nsssh!\n",
+			ExpectedError: `line 2: expected key="value"`,
+		},
+		{
+			Name:          "unquoted",
+			Content:       "password=sssh!\n",
+			ExpectedError: "line 1: invalid value of 'password': expected a double-quoted string",
+		},
+		{
+			Name:          "unterminated",
+			Content:       `password="sssh!`,
+			ExpectedError: "line 1: invalid value of 'password': expected a double-quoted string",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			variables, err := variable.ParseDownward(strings.NewReader(tc.Content), "labels")
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, variables) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, variables)
+			}
+		})
+	}
+}
+
+func TestParseDownwardLongLine(t *testing.T) {
+	value := strings.Repeat("a", 200*1024)
+
+	variables, err := variable.ParseDownward(strings.NewReader(`config="`+value+`"`), "annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(variables) != 1 || variables[0].Value != value {
+		t.Fatalf("invalid variables: expected a single variable holding the long value")
+	}
+}
@@ -0,0 +1,152 @@
+package variable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envFileNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// LoadEnvFile reads the variables of a dotenv file. See ParseEnvFile for the supported syntax
+func LoadEnvFile(path string) ([]Variable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseEnvFile(f, path)
+}
+
+// ParseEnvFile reads `KEY=VALUE` lines. Empty lines and lines starting with `#` are ignored and
+// the `export ` prefix is allowed. Values can be double-quoted (supporting `\n`, `\t`, `\"` and
+// `\\` escapes), single-quoted (kept as is) or unquoted (trimmed, `#` starts a comment when
+// preceded by a space). When a key is defined several times the last line wins
+func ParseEnvFile(r io.Reader, source string) ([]Variable, error) {
+	var variables []Variable
+	indexes := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, err := parseEnvFileLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		v := Variable{Name: name, Value: value, Source: fmt.Sprintf("%s:%d", source, lineNumber)}
+		if i, found := indexes[name]; found {
+			variables[i] = v
+			continue
+		}
+
+		indexes[name] = len(variables)
+		variables = append(variables, v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
+
+func parseEnvFileLine(line string) (string, string, error) {
+	line = strings.TrimPrefix(line, "export ")
+
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("expected KEY=VALUE")
+	}
+
+	name := strings.TrimSpace(parts[0])
+	if !envFileNameRegexp.MatchString(name) {
+		return "", "", fmt.Errorf("invalid key '%s': expected letters, digits, '_' and '.' not starting with a digit", name)
+	}
+
+	value, err := parseEnvFileValue(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid value of '%s': %v", name, err)
+	}
+
+	return name, value, nil
+}
+
+func parseEnvFileValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch quote := raw[0]; quote {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+
+		if err := checkEnvFileTrailing(raw[end+2:]); err != nil {
+			return "", err
+		}
+
+		return raw[1 : end+1], nil
+	case '"':
+		var value strings.Builder
+
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; c {
+			case '"':
+				if err := checkEnvFileTrailing(raw[i+1:]); err != nil {
+					return "", err
+				}
+
+				return value.String(), nil
+			case '\\':
+				if i+1 == len(raw) {
+					return "", fmt.Errorf("missing closing quote")
+				}
+
+				i++
+				switch escaped := raw[i]; escaped {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				case 'r':
+					value.WriteByte('\r')
+				case '"', '\\', '$':
+					value.WriteByte(escaped)
+				default:
+					value.WriteByte('\\')
+					value.WriteByte(escaped)
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+
+		return "", fmt.Errorf("missing closing quote")
+	default:
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+func checkEnvFileTrailing(trailing string) error {
+	trailing = strings.TrimSpace(trailing)
+	if trailing != "" && !strings.HasPrefix(trailing, "#") {
+		return fmt.Errorf("unexpected characters after the closing quote")
+	}
+
+	return nil
+}
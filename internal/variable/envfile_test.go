@@ -0,0 +1,80 @@
+package variable_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name: "valid",
+			Content: strings.Join([]string{
+				"# database settings",
+				"",
+				"DATABASE_HOST=db.svc # the service",
+				"export DATABASE_PORT = 5432",
+				`DATABASE_PASSWORD="sssh! \"it's\" secret\n"`,
+				`DATABASE_USERNAME='my#app \n'`,
+				"EMPTY=",
+				"DATABASE_HOST=db.internal",
+			}, "\n"),
+			Expected: []variable.Variable{
+				{Name: "DATABASE_HOST", Value: "db.internal", Source: ".env:8"},
+				{Name: "DATABASE_PORT", Value: "5432", Source: ".env:4"},
+				{Name: "DATABASE_PASSWORD", Value: "sssh! \"it's\" secret\n", Source: ".env:5"},
+				{Name: "DATABASE_USERNAME", Value: `my#app \n`, Source: ".env:6"},
+				{Name: "EMPTY", Value: "", Source: ".env:7"},
+			},
+		},
+		{
+			Name:          "missing equal",
+			Content:       "# comment\nDATABASE_HOST",
+			ExpectedError: "line 2: expected KEY=VALUE",
+		},
+		{
+			Name:          "invalid key",
+			Content:       "1HOST=db",
+			ExpectedError: "line 1: invalid key '1HOST': expected letters, digits, '_' and '.' not starting with a digit",
+		},
+		{
+			Name:          "unterminated quote",
+			Content:       "HOST=db\nPASSWORD=\"secret",
+			ExpectedError: "line 2: invalid value of 'PASSWORD': missing closing quote",
+		},
+		{
+			Name:          "trailing content",
+			Content:       "PASSWORD='secret' value",
+			ExpectedError: "line 1: invalid value of 'PASSWORD': unexpected characters after the closing quote",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := variable.ParseEnvFile(strings.NewReader(tc.Content), ".env")
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package variable
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxInterpolationDepth is the maximum length of a chain of references resolved by Interpolate,
+// bounding the expansion of values referencing each other
+const MaxInterpolationDepth = 10
+
+var referencePattern = regexp.MustCompile(`\$\{([^${}]+)\}`)
+
+// Interpolate replaces the `${NAME}` references in the values of the set by the value of the NAME
+// variable, itself interpolated. References to variables which aren't defined are kept as is, and
+// the code variables are neither interpolated nor substituted. It returns an error naming the
+// variables when they reference each other or when a chain of references is longer than
+// MaxInterpolationDepth
+func (s *Set) Interpolate() error {
+	resolver := interpolation{set: s, resolved: make(map[string]string)}
+
+	for _, variable := range s.List() {
+		if variable.Code {
+			continue
+		}
+
+		value, err := resolver.resolve(variable.Name, nil)
+		if err != nil {
+			return err
+		}
+
+		variable.Value = value
+		s.variables[variable.Name] = variable
+	}
+
+	return nil
+}
+
+type interpolation struct {
+	set      *Set
+	resolved map[string]string
+}
+
+func (i interpolation) resolve(name string, chain []string) (string, error) {
+	if value, found := i.resolved[name]; found {
+		return value, nil
+	}
+
+	for index, previous := range chain {
+		if previous == name {
+			return "", fmt.Errorf("can't interpolate variables: cycle between %s", formatChain(append(chain[index:], name)))
+		}
+	}
+
+	chain = append(chain, name)
+	if len(chain) > MaxInterpolationDepth {
+		return "", fmt.Errorf("can't interpolate variables: more than %d nested references from %s", MaxInterpolationDepth, formatChain(chain))
+	}
+
+	var err error
+	value := referencePattern.ReplaceAllStringFunc(i.set.variables[name].Value, func(reference string) string {
+		referenced, found := i.set.variables[referencePattern.FindStringSubmatch(reference)[1]]
+		if err != nil || !found || referenced.Code {
+			return reference
+		}
+
+		var resolved string
+		resolved, err = i.resolve(referenced.Name, chain)
+
+		return resolved
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	i.resolved[name] = value
+
+	return value, nil
+}
+
+func formatChain(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, fmt.Sprintf("'%s'", name))
+	}
+
+	return strings.Join(quoted, " -> ")
+}
@@ -0,0 +1,61 @@
+package variable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// LoadJSONFile reads the variables of a JSON file whose top-level value must be an object. Each key
+// is a variable: string values are used as is while the other values are kept as compact JSON
+// marked as Code
+func LoadJSONFile(path string) ([]Variable, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJSON(content, path)
+}
+
+// ParseJSON reads the variables of a JSON object. See LoadJSONFile
+func ParseJSON(content []byte, source string) ([]Variable, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil || values == nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		return nil, fmt.Errorf("expected a JSON object at the top level")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := make([]Variable, 0, len(names))
+	for _, name := range names {
+		var s string
+		if bytes.HasPrefix(values[name], []byte(`"`)) {
+			if err := json.Unmarshal(values[name], &s); err != nil {
+				return nil, fmt.Errorf("invalid value of '%s': %v", name, err)
+			}
+
+			variables = append(variables, Variable{Name: name, Value: s, Source: source})
+			continue
+		}
+
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, values[name]); err != nil {
+			return nil, fmt.Errorf("invalid value of '%s': %v", name, err)
+		}
+
+		variables = append(variables, Variable{Name: name, Value: compacted.String(), Source: source, Code: true})
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,53 @@
+package variable_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+func TestParseJSON(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name:    "object",
+			Content: `{"HOST": "db.svc", "PORT": 5432, "REPLICAS": {"min": 1, "max": 3}, "ZONES": ["a", "b"], "TLS": null}`,
+			Expected: []variable.Variable{
+				{Name: "HOST", Value: "db.svc", Source: "values.json"},
+				{Name: "PORT", Value: "5432", Source: "values.json", Code: true},
+				{Name: "REPLICAS", Value: `{"min":1,"max":3}`, Source: "values.json", Code: true},
+				{Name: "TLS", Value: "null", Source: "values.json", Code: true},
+				{Name: "ZONES", Value: `["a","b"]`, Source: "values.json", Code: true},
+			},
+		},
+		{Name: "array", Content: `["a", "b"]`, ExpectedError: "expected a JSON object at the top level"},
+		{Name: "null", Content: `null`, ExpectedError: "expected a JSON object at the top level"},
+		{Name: "invalid", Content: `{"HOST": }`, ExpectedError: "invalid JSON: invalid character '}' looking for beginning of value"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := variable.ParseJSON([]byte(tc.Content), "values.json")
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package variable
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// GeneratedAtName is the name of the metadata variable holding the generation time, formatted
+	// as RFC3339 in UTC
+	GeneratedAtName = "_generated_at"
+	// HostnameName is the name of the metadata variable holding the hostname of the machine, e.g.
+	// the name of the pod
+	HostnameName = "_hostname"
+	// MetadataSource is the source of the metadata variables
+	MetadataSource = "metadata"
+)
+
+// Metadata returns the variables describing the generation
+func Metadata(generatedAt time.Time, hostname string) []Variable {
+	return []Variable{
+		{Name: GeneratedAtName, Value: generatedAt.UTC().Format(time.RFC3339), Source: MetadataSource},
+		{Name: HostnameName, Value: hostname, Source: MetadataSource},
+	}
+}
+
+// GenerationTime returns the time given by sourceDateEpoch, a number of seconds since the Unix
+// epoch following the SOURCE_DATE_EPOCH convention of reproducible builds, or now when it's empty
+func GenerationTime(sourceDateEpoch string, now time.Time) (time.Time, error) {
+	if sourceDateEpoch == "" {
+		return now, nil
+	}
+
+	seconds, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH '%s': expected a number of seconds since the Unix epoch", sourceDateEpoch)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
@@ -0,0 +1,60 @@
+package variable_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+func TestMetadata(t *testing.T) {
+	location := time.FixedZone("CEST", 2*60*60)
+
+	expected := []variable.Variable{
+		{Name: "_generated_at", Value: "2021-06-01T10:00:00Z", Source: "metadata"},
+		{Name: "_hostname", Value: "api-5d8f7", Source: "metadata"},
+	}
+
+	actual := variable.Metadata(time.Date(2021, 6, 1, 12, 0, 0, 0, location), "api-5d8f7")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+	}
+}
+
+func TestGenerationTime(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		Name            string
+		SourceDateEpoch string
+		Expected        time.Time
+		ExpectedError   string
+	}{
+		{Name: "now", Expected: now},
+		{Name: "pinned", SourceDateEpoch: "1609459200", Expected: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "invalid", SourceDateEpoch: "2021-01-01", ExpectedError: "invalid SOURCE_DATE_EPOCH '2021-01-01': expected a number of seconds since the Unix epoch"},
+		{Name: "negative", SourceDateEpoch: "-1", ExpectedError: "invalid SOURCE_DATE_EPOCH '-1': expected a number of seconds since the Unix epoch"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := variable.GenerationTime(tc.SourceDateEpoch, now)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.Expected.Equal(actual) {
+				t.Fatalf("invalid time\nexpected:\n%s\nactual:\n%s\n", tc.Expected, actual)
+			}
+		})
+	}
+}
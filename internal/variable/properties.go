@@ -0,0 +1,198 @@
+package variable
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// LoadPropertiesFile reads the variables of a Java properties file. See ParseProperties for the
+// supported syntax
+func LoadPropertiesFile(path string) ([]Variable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseProperties(f, path)
+}
+
+// ParseProperties reads the `key=value`, `key:value` or `key value` lines of a Java properties
+// file, following the format of java.util.Properties. Blank lines and lines starting with `#` or
+// `!` are ignored. A line ending with an odd number of `\` continues on the next one, whose
+// leading white spaces are skipped, a continuation on the last line being an error. Keys and values
+// support the `\t`, `\n`, `\f`, `\r` and `\uXXXX` escapes, UTF-16 surrogate pairs included, and
+// any other escaped character stands for itself, e.g. `\=` in a key. When a key is defined several
+// times the last line wins
+func ParseProperties(r io.Reader, source string) ([]Variable, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(string(content), "\r\n", "\n"), "\r", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var variables []Variable
+	indexes := make(map[string]int)
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+
+		line := strings.TrimLeft(lines[i], propertiesSpaces)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		for isPropertiesContinuation(line) {
+			if i+1 == len(lines) {
+				return nil, fmt.Errorf("line %d: line continuation at the end of the file", i+1)
+			}
+
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], propertiesSpaces)
+		}
+
+		name, value, err := parsePropertiesLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		v := Variable{Name: name, Value: value, Source: fmt.Sprintf("%s:%d", source, lineNumber)}
+		if index, found := indexes[name]; found {
+			variables[index] = v
+			continue
+		}
+
+		indexes[name] = len(variables)
+		variables = append(variables, v)
+	}
+
+	return variables, nil
+}
+
+// propertiesSpaces are the white spaces of the properties format
+const propertiesSpaces = " \t\f"
+
+// isPropertiesContinuation tells whether the line ends with an odd number of `\`, the last one
+// not being escaped
+func isPropertiesContinuation(line string) bool {
+	count := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		count++
+	}
+
+	return count%2 == 1
+}
+
+// parsePropertiesLine splits a logical line into its unescaped key and value. The key ends at the
+// first unescaped `=`, `:` or white space, the separator being optionally surrounded by white
+// spaces
+func parsePropertiesLine(line string) (string, string, error) {
+	end := 0
+	for end < len(line) && !strings.ContainsRune("=:"+propertiesSpaces, rune(line[end])) {
+		if line[end] == '\\' {
+			end++
+		}
+		end++
+	}
+
+	if end > len(line) {
+		end = len(line)
+	}
+
+	rest := strings.TrimLeft(line[end:], propertiesSpaces)
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], propertiesSpaces)
+	}
+
+	name, err := unescapeProperties(line[:end])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid key: %v", err)
+	}
+
+	if name == "" {
+		return "", "", fmt.Errorf("missing key")
+	}
+
+	value, err := unescapeProperties(rest)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid value of '%s': %v", name, err)
+	}
+
+	return name, value, nil
+}
+
+func unescapeProperties(raw string) (string, error) {
+	var buf strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' || i+1 == len(raw) {
+			buf.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		switch escaped := raw[i]; escaped {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'u':
+			r, err := parseUnicodeEscape(raw[i-1:])
+			if err != nil {
+				return "", err
+			}
+			i += 4
+
+			if utf16.IsSurrogate(r) {
+				low, err := parseUnicodeEscape(raw[i+1:])
+				if err != nil || !utf16.IsSurrogate(low) {
+					return "", fmt.Errorf("unpaired surrogate in unicode escape '%s'", raw[i-5:i+1])
+				}
+				i += 6
+
+				if r = utf16.DecodeRune(r, low); r == unicode.ReplacementChar {
+					return "", fmt.Errorf("invalid surrogate pair in unicode escape '%s'", raw[i-11:i+1])
+				}
+			}
+
+			buf.WriteRune(r)
+		default:
+			buf.WriteByte(escaped)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// parseUnicodeEscape parses the `\uXXXX` escape starting the text
+func parseUnicodeEscape(text string) (rune, error) {
+	if !strings.HasPrefix(text, `\u`) {
+		return 0, fmt.Errorf("expected a unicode escape")
+	}
+
+	digits := text[2:]
+	if len(digits) > 4 {
+		digits = digits[:4]
+	}
+
+	code, err := strconv.ParseUint(digits, 16, 16)
+	if err != nil || len(digits) != 4 {
+		return 0, fmt.Errorf("invalid unicode escape '\\u%s': expected 4 hexadecimal digits", digits)
+	}
+
+	return rune(code), nil
+}
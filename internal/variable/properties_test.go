@@ -0,0 +1,97 @@
+package variable_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+func TestParseProperties(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Content       string
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name: "valid",
+			Content: strings.Join([]string{
+				"# database settings",
+				"! legacy comment",
+				"",
+				"db.url = jdbc:postgresql://db.svc:5432/app",
+				"db.user:myapp",
+				"db.hosts   first, \\",
+				"           second, \\",
+				"           third",
+				`path\ with\ spaces\=key = C:\\Program Files\\app`,
+				`greeting=caf\u00e9 \uD83D\uDE00\tend\n`,
+				"empty",
+				"  continued = \\\\",
+				"db.user=override",
+			}, "\r\n"),
+			Expected: []variable.Variable{
+				{Name: "db.url", Value: "jdbc:postgresql://db.svc:5432/app", Source: "app.properties:4"},
+				{Name: "db.user", Value: "override", Source: "app.properties:13"},
+				{Name: "db.hosts", Value: "first, second, third", Source: "app.properties:6"},
+				{Name: "path with spaces=key", Value: `C:\Program Files\app`, Source: "app.properties:9"},
+				{Name: "greeting", Value: "café 😀\tend\n", Source: "app.properties:10"},
+				{Name: "empty", Value: "", Source: "app.properties:11"},
+				{Name: "continued", Value: `\`, Source: "app.properties:12"},
+			},
+		},
+		{
+			Name:     "continued comment",
+			Content:  "key=first \\\n# not a comment\n",
+			Expected: []variable.Variable{{Name: "key", Value: "first # not a comment", Source: "app.properties:1"}},
+		},
+		{
+			Name:          "continuation at the end of the file",
+			Content:       "db.url=jdbc \\\n  :postgresql \\\n",
+			ExpectedError: "line 2: line continuation at the end of the file",
+		},
+		{
+			Name:          "invalid unicode escape",
+			Content:       "key=value\nname=caf\\u00g9",
+			ExpectedError: "line 2: invalid value of 'name': invalid unicode escape '\\u00g9': expected 4 hexadecimal digits",
+		},
+		{
+			Name:          "truncated unicode escape",
+			Content:       "na\\u00",
+			ExpectedError: "line 1: invalid key: invalid unicode escape '\\u00': expected 4 hexadecimal digits",
+		},
+		{
+			Name:          "unpaired surrogate",
+			Content:       "emoji=\\uD83D!",
+			ExpectedError: "line 1: invalid value of 'emoji': unpaired surrogate in unicode escape '\\uD83D'",
+		},
+		{
+			Name:          "missing key",
+			Content:       "= value",
+			ExpectedError: "line 1: missing key",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := variable.ParseProperties(strings.NewReader(tc.Content), "app.properties")
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
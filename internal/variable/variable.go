@@ -0,0 +1,136 @@
+package variable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Variable represents a named value and where it comes from
+type Variable struct {
+	Name   string
+	Value  string
+	Source string
+	// Code indicates the value must be evaluated by the interpreter instead of being used as a string
+	Code bool
+	// JSON indicates a Code value is JSON, so it can be loaded as data by the interpreters that
+	// don't evaluate code, or as its JSON encoding in a string
+	JSON bool
+}
+
+// Conflict represents the way a variable defined by several sources is handled
+type Conflict string
+
+const (
+	// ConflictError fails when a variable is defined by several sources. It's the default behavior
+	ConflictError Conflict = "error"
+	// ConflictLast keeps the value of the last source defining the variable
+	ConflictLast Conflict = "last"
+	// ConflictFirst keeps the value of the first source defining the variable
+	ConflictFirst Conflict = "first"
+)
+
+// ParseConflict returns the Conflict matching the given name
+func ParseConflict(name string) (Conflict, error) {
+	switch conflict := Conflict(name); conflict {
+	case ConflictError, ConflictLast, ConflictFirst:
+		return conflict, nil
+	default:
+		return "", fmt.Errorf("unsupported conflict strategy '%s'", name)
+	}
+}
+
+// Set represents a collection of variables indexed by name
+type Set struct {
+	conflict  Conflict
+	variables map[string]Variable
+}
+
+// NewSet builds an empty set of variables using the conflict strategy to handle a variable
+// defined twice
+func NewSet(conflict Conflict) *Set {
+	return &Set{conflict: conflict, variables: make(map[string]Variable)}
+}
+
+// Add stores the variables in the set. It returns an error naming both sources when a variable
+// is already defined by another source and the conflict strategy is ConflictError
+func (s *Set) Add(variables ...Variable) error {
+	for _, variable := range variables {
+		existing, found := s.variables[variable.Name]
+		if found {
+			switch s.conflict {
+			case ConflictFirst:
+				continue
+			case ConflictLast:
+			default:
+				return fmt.Errorf(
+					"variable '%s' is defined by both '%s' and '%s'",
+					variable.Name,
+					existing.Source,
+					variable.Source,
+				)
+			}
+		}
+
+		s.variables[variable.Name] = variable
+	}
+
+	return nil
+}
+
+// AddFallback stores the variables not already defined in the set, whatever the conflict
+// strategy. It's used by sources having a lower precedence than the ones already added
+func (s *Set) AddFallback(variables ...Variable) {
+	for _, variable := range variables {
+		if _, found := s.variables[variable.Name]; found {
+			continue
+		}
+
+		s.variables[variable.Name] = variable
+	}
+}
+
+// Override stores the variables in the set, replacing the ones already defined whatever the
+// conflict strategy. It's used by sources having a higher precedence than all the others
+func (s *Set) Override(variables ...Variable) {
+	for _, variable := range variables {
+		s.variables[variable.Name] = variable
+	}
+}
+
+// Get returns the variable of the set having the name and whether it has been found
+func (s *Set) Get(name string) (Variable, bool) {
+	variable, found := s.variables[name]
+
+	return variable, found
+}
+
+// List returns all the variables of the set sorted by name
+func (s *Set) List() []Variable {
+	variables := make([]Variable, 0, len(s.variables))
+	for _, variable := range s.variables {
+		variables = append(variables, variable)
+	}
+
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+
+	return variables
+}
+
+// FromEnviron builds the variables from a list of `NAME=VALUE` environment entries (as returned
+// by os.Environ), keeping only the ones whose name starts with the prefix. The prefix is kept in
+// the variable name
+func FromEnviron(environ []string, prefix string) []Variable {
+	var variables []Variable
+
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || !strings.HasPrefix(parts[0], prefix) {
+			continue
+		}
+
+		variables = append(variables, Variable{Name: parts[0], Value: parts[1], Source: "environment"})
+	}
+
+	return variables
+}
@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+const (
+	// AddressEnv is the environment variable holding the address of the Vault server
+	AddressEnv = "VAULT_ADDR"
+	// TokenEnv is the environment variable holding the token used to authenticate to Vault
+	TokenEnv = "VAULT_TOKEN"
+	// NamespaceEnv is the environment variable holding the Vault Enterprise namespace, if any
+	NamespaceEnv = "VAULT_NAMESPACE"
+)
+
+// Client reads secrets from a Vault server using its HTTP API. The token is only sent in the
+// request headers and never appears in the errors
+type Client struct {
+	address   string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// NewClient builds a client for the Vault server at the address, authenticated with the token. The
+// requests must complete within the timeout, there's no limit when 0
+func NewClient(address string, token string, namespace string, timeout time.Duration) *Client {
+	return &Client{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: namespace,
+		http:      &http.Client{Timeout: timeout},
+	}
+}
+
+// NewClientFromEnv builds a client using the AddressEnv, TokenEnv and NamespaceEnv variables of the
+// environment, given as "KEY=value" entries like os.Environ. AddressEnv and TokenEnv must be set
+func NewClientFromEnv(environ []string, timeout time.Duration) (*Client, error) {
+	values := make(map[string]string)
+	for _, entry := range environ {
+		if i := strings.Index(entry, "="); i > 0 {
+			values[entry[:i]] = entry[i+1:]
+		}
+	}
+
+	var missing []string
+	for _, name := range []string{AddressEnv, TokenEnv} {
+		if values[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	return NewClient(values[AddressEnv], values[TokenEnv], values[NamespaceEnv], timeout), nil
+}
+
+// Read returns the key/value pairs of the secret at the path as variables, with the "vault:<path>"
+// source. Both the KV version 1 and version 2 engines are supported: for the latter the path
+// includes the "data" segment (e.g. "secret/data/app"). String values are used as is while the
+// other values are kept as compact JSON marked as Code, like variable.ParseJSON
+func (c *Client) Read(path string) ([]variable.Variable, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, fmt.Errorf("empty secret path")
+	}
+
+	request, err := http.NewRequest(http.MethodGet, c.address+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault address: %v", err)
+	}
+
+	request.Header.Set("X-Vault-Token", c.token)
+	if c.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("can't reach Vault: %v", err)
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can't read Vault response: %v", err)
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("secret '%s' not found", path)
+	case response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("permission denied reading secret '%s': check the token and its policies", path)
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		return nil, fmt.Errorf("can't read secret '%s': unexpected HTTP status '%s'%s", path, response.Status, responseErrors(content))
+	}
+
+	data, err := secretData(content)
+	if err != nil {
+		return nil, fmt.Errorf("can't read secret '%s': %v", path, err)
+	}
+
+	variables, err := variable.ParseJSON(data, "vault:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read secret '%s': %v", path, err)
+	}
+
+	return variables, nil
+}
+
+// secretData extracts the key/value pairs of a secret response, unwrapping the data of the KV
+// version 2 engine which comes along with its metadata
+func secretData(content []byte) ([]byte, error) {
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, fmt.Errorf("invalid Vault response: %v", err)
+	}
+
+	if len(response.Data) == 0 || bytes.Equal(response.Data, []byte("null")) {
+		return nil, fmt.Errorf("the secret has no data")
+	}
+
+	var versioned struct {
+		Data     json.RawMessage `json:"data"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(response.Data, &versioned); err == nil && len(versioned.Metadata) > 0 {
+		if len(versioned.Data) == 0 || bytes.Equal(versioned.Data, []byte("null")) {
+			return nil, fmt.Errorf("the secret has no data, its latest version may be deleted")
+		}
+
+		return versioned.Data, nil
+	}
+
+	return response.Data, nil
+}
+
+// responseErrors formats the messages of a Vault error response, if any
+func responseErrors(content []byte) string {
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+
+	if err := json.Unmarshal(content, &response); err != nil || len(response.Errors) == 0 {
+		return ""
+	}
+
+	return ": " + strings.Join(response.Errors, ", ")
+}
@@ -0,0 +1,128 @@
+package vault_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/vault"
+)
+
+func TestClientRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.valid" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			fmt.Fprint(w, `{"data": {"data": {"DATABASE_PASSWORD": "sssh!", "PORTS": [1, 2]}, "metadata": {"version": 3}}}`)
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"data": {"API_KEY": "abc"}}`)
+		case "/v1/secret/data/deleted":
+			fmt.Fprint(w, `{"data": {"data": null, "metadata": {"version": 2}}}`)
+		case "/v1/secret/data/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"errors": ["internal error"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors": []}`)
+		}
+	}))
+	defer server.Close()
+
+	tcs := []struct {
+		Name          string
+		Token         string
+		Path          string
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name:  "kv v2",
+			Token: "s.valid",
+			Path:  "secret/data/app",
+			Expected: []variable.Variable{
+				{Name: "DATABASE_PASSWORD", Value: "sssh!", Source: "vault:secret/data/app"},
+				{Name: "PORTS", Value: "[1,2]", Source: "vault:secret/data/app", Code: true},
+			},
+		},
+		{
+			Name:  "kv v1",
+			Token: "s.valid",
+			Path:  "/kv/app/",
+			Expected: []variable.Variable{
+				{Name: "API_KEY", Value: "abc", Source: "vault:kv/app"},
+			},
+		},
+		{
+			Name:          "deleted",
+			Token:         "s.valid",
+			Path:          "secret/data/deleted",
+			ExpectedError: "can't read secret 'secret/data/deleted': the secret has no data, its latest version may be deleted",
+		},
+		{
+			Name:          "not found",
+			Token:         "s.valid",
+			Path:          "secret/data/missing",
+			ExpectedError: "secret 'secret/data/missing' not found",
+		},
+		{
+			Name:          "permission denied",
+			Token:         "s.invalid",
+			Path:          "secret/data/app",
+			ExpectedError: "permission denied reading secret 'secret/data/app': check the token and its policies",
+		},
+		{
+			Name:          "server error",
+			Token:         "s.valid",
+			Path:          "secret/data/broken",
+			ExpectedError: "can't read secret 'secret/data/broken': unexpected HTTP status '500 Internal Server Error': internal error",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			client := vault.NewClient(server.URL+"/", tc.Token, "", 0)
+
+			variables, err := client.Read(tc.Path)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				if strings.Contains(err.Error(), tc.Token) {
+					t.Fatalf("the error leaks the token: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, variables) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, variables)
+			}
+		})
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	_, err := vault.NewClientFromEnv([]string{"VAULT_ADDR=http://127.0.0.1:8200", "HOME=/root"}, 0)
+
+	if expected := "missing environment variables: VAULT_TOKEN"; err == nil || err.Error() != expected {
+		t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+	}
+
+	if _, err := vault.NewClientFromEnv([]string{"VAULT_ADDR=http://127.0.0.1:8200", "VAULT_TOKEN=s.valid"}, 0); err != nil {
+		t.Fatal(err)
+	}
+}
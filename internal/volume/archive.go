@@ -0,0 +1,126 @@
+package volume
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+// ArchiveTemplateName is the name, without extension, of the archive entry used as template
+const ArchiveTemplateName = "template"
+
+// Archive represents the template and the variables read from a tar archive
+type Archive struct {
+	// TemplateName is the path of the template entry in the archive, e.g. `template.jsonnet`
+	TemplateName string
+	// Template is the content of the template entry
+	Template string
+	// Variables are the variables defined by the other entries
+	Variables []variable.Variable
+}
+
+// LoadArchive reads a tar archive, compressed with gzip or not. The regular file at the root of the
+// archive named `template` (with any extension) is the template. Every other regular file is a
+// variable named after the base name of the entry, whatever its folder, and whose name and content
+// are transformed like the files of a volume. Folders, links and hidden entries are skipped
+func LoadArchive(r io.Reader, opts Options) (Archive, error) {
+	buffered := bufio.NewReader(r)
+
+	var archiveReader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return Archive{}, fmt.Errorf("can't read gzip archive: %v", err)
+		}
+		defer gzipReader.Close()
+
+		archiveReader = gzipReader
+	}
+
+	l := loader{opts: opts}
+	archive := Archive{}
+	sources := make(map[string]string)
+
+	tarReader := tar.NewReader(archiveReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return Archive{}, fmt.Errorf("can't read tar archive: %v", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entryPath := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		name := path.Base(entryPath)
+		if l.isHidden(name) {
+			continue
+		}
+
+		content, err := readArchiveEntry(tarReader, entryPath, opts.MaxFileSize)
+		if err != nil {
+			return Archive{}, err
+		}
+
+		if entryPath == name && strings.TrimSuffix(name, path.Ext(name)) == ArchiveTemplateName {
+			if archive.TemplateName != "" {
+				return Archive{}, fmt.Errorf("can't read tar archive: entries '%s' and '%s' are both templates", archive.TemplateName, entryPath)
+			}
+
+			archive.TemplateName, archive.Template = entryPath, string(content)
+			continue
+		}
+
+		if opts.StripExt {
+			name = stripExt(name)
+		}
+
+		name = opts.NameTransform.apply(name)
+		if source, found := sources[name]; found {
+			return Archive{}, fmt.Errorf("can't read tar archive: entries '%s' and '%s' define the same variable '%s'", source, entryPath, name)
+		}
+		sources[name] = entryPath
+
+		v, err := opts.variable(name, content, entryPath)
+		if err != nil {
+			return Archive{}, fmt.Errorf("can't read tar archive: %v", err)
+		}
+
+		archive.Variables = append(archive.Variables, v)
+	}
+
+	if archive.TemplateName == "" {
+		return Archive{}, fmt.Errorf("can't read tar archive: no '%s' entry at the root of the archive", ArchiveTemplateName)
+	}
+
+	return archive, nil
+}
+
+func readArchiveEntry(r io.Reader, name string, maxFileSize int64) ([]byte, error) {
+	if maxFileSize > 0 {
+		r = io.LimitReader(r, maxFileSize+1)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, fmt.Errorf("can't read tar archive entry '%s': %v", name, err)
+	}
+
+	if maxFileSize > 0 && int64(buf.Len()) > maxFileSize {
+		return nil, fmt.Errorf("can't load archive entry '%s': it exceeds the maximum size of %d bytes", name, maxFileSize)
+	}
+
+	return buf.Bytes(), nil
+}
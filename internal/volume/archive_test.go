@@ -0,0 +1,131 @@
+package volume_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+type archiveEntry struct {
+	Name    string
+	Content string
+}
+
+func buildArchive(t *testing.T, compress bool, entries ...archiveEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	var gzipWriter *gzip.Writer
+	tarWriter := tar.NewWriter(&buf)
+	if compress {
+		gzipWriter = gzip.NewWriter(&buf)
+		tarWriter = tar.NewWriter(gzipWriter)
+	}
+
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.Name, Mode: 0644, Size: int64(len(entry.Content)), Typeflag: tar.TypeReg}
+		if entry.Content == "" {
+			header.Typeflag = tar.TypeDir
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("can't write archive: %v", err)
+		}
+
+		if _, err := tarWriter.Write([]byte(entry.Content)); err != nil {
+			t.Fatalf("can't write archive: %v", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("can't write archive: %v", err)
+	}
+
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			t.Fatalf("can't write archive: %v", err)
+		}
+	}
+
+	return &buf
+}
+
+func TestLoadArchive(t *testing.T) {
+	entries := []archiveEntry{
+		{Name: "template.jsonnet", Content: "std.extVar('API_PORT')\n"},
+		{Name: "API_PORT", Content: "1337\n"},
+		{Name: "secrets/"},
+		{Name: "secrets/DATABASE_PASSWORD", Content: "sssh\n"},
+		{Name: "secrets/.hidden", Content: "hidden"},
+	}
+
+	for _, compress := range []bool{false, true} {
+		archive, err := volume.LoadArchive(buildArchive(t, compress, entries...), volume.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := "template.jsonnet"; expected != archive.TemplateName {
+			t.Fatalf("invalid template name\nexpected:\n'%s'\nactual:\n'%s'\n", expected, archive.TemplateName)
+		}
+
+		if expected := "std.extVar('API_PORT')\n"; expected != archive.Template {
+			t.Fatalf("invalid template\nexpected:\n'%s'\nactual:\n'%s'\n", expected, archive.Template)
+		}
+
+		r := recorder{}
+		for _, variable := range archive.Variables {
+			r[variable.Name] = variable.Value
+		}
+
+		if expected := (recorder{"API_PORT": "1337", "DATABASE_PASSWORD": "sssh"}); !reflect.DeepEqual(expected, r) {
+			t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", expected, r)
+		}
+	}
+}
+
+func TestLoadArchiveErrors(t *testing.T) {
+	tcs := []struct {
+		Name          string
+		Archive       *bytes.Buffer
+		ExpectedError string
+	}{
+		{
+			Name:          "malformed",
+			Archive:       bytes.NewBufferString("not an archive"),
+			ExpectedError: "can't read tar archive: unexpected EOF",
+		},
+		{
+			Name:          "no template",
+			Archive:       buildArchive(t, false, archiveEntry{Name: "API_PORT", Content: "1337"}, archiveEntry{Name: "config/template.tpl", Content: "{{ . }}"}),
+			ExpectedError: "can't read tar archive: no 'template' entry at the root of the archive",
+		},
+		{
+			Name:          "several templates",
+			Archive:       buildArchive(t, false, archiveEntry{Name: "template.tpl", Content: "a"}, archiveEntry{Name: "./template.jsonnet", Content: "b"}),
+			ExpectedError: "can't read tar archive: entries 'template.tpl' and 'template.jsonnet' are both templates",
+		},
+		{
+			Name:          "same variable",
+			Archive:       buildArchive(t, true, archiveEntry{Name: "a/PORT", Content: "1"}, archiveEntry{Name: "b/PORT", Content: "2"}),
+			ExpectedError: "can't read tar archive: entries 'a/PORT' and 'b/PORT' define the same variable 'PORT'",
+		},
+		{
+			Name:          "too large",
+			Archive:       buildArchive(t, false, archiveEntry{Name: "template.tpl", Content: "0123456789abcdef"}),
+			ExpectedError: "can't load archive entry 'template.tpl': it exceeds the maximum size of 8 bytes",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := volume.LoadArchive(tc.Archive, volume.Options{MaxFileSize: 8})
+			if err == nil || err.Error() != tc.ExpectedError {
+				t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package volume
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Encoding represents the charset of the content of the loaded files, decoded to UTF-8 before it
+// becomes a variable value
+type Encoding string
+
+const (
+	// EncodingUTF8 loads the content of the files as is. It's the default behavior
+	EncodingUTF8 Encoding = "utf-8"
+	// EncodingLatin1 decodes the content of the files from ISO-8859-1
+	EncodingLatin1 Encoding = "latin1"
+	// EncodingWindows1252 decodes the content of the files from Windows-1252, the superset of
+	// ISO-8859-1 used by Windows, e.g. with '€' as 0x80
+	EncodingWindows1252 Encoding = "windows-1252"
+)
+
+// ParseEncoding returns the Encoding matching the given name
+func ParseEncoding(name string) (Encoding, error) {
+	switch encoding := Encoding(name); encoding {
+	case EncodingUTF8, EncodingLatin1, EncodingWindows1252:
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("unsupported encoding '%s'", name)
+	}
+}
+
+// charmap returns the single-byte charset of the encoding, nil for UTF-8
+func (e Encoding) charmap() *charmap.Charmap {
+	switch e {
+	case EncodingLatin1:
+		return charmap.ISO8859_1
+	case EncodingWindows1252:
+		return charmap.Windows1252
+	default:
+		return nil
+	}
+}
+
+// decode returns the UTF-8 content of a file. When binary files are base64 encoded, the ones
+// containing a NUL byte are kept as is to be encoded
+func (o Options) decode(content []byte) ([]byte, error) {
+	charset := o.Encoding.charmap()
+	if charset == nil || (o.Binary == BinaryBase64 && bytes.IndexByte(content, 0) >= 0) {
+		return content, nil
+	}
+
+	decoded := make([]byte, 0, len(content))
+	for i, b := range content {
+		r := charset.DecodeByte(b)
+		if r == utf8.RuneError {
+			return nil, fmt.Errorf("invalid %s byte 0x%02x at offset %d", o.Encoding, b, i)
+		}
+
+		decoded = utf8.AppendRune(decoded, r)
+	}
+
+	return decoded, nil
+}
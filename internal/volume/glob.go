@@ -0,0 +1,46 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandGlobs replaces the volume paths containing glob metacharacters (`*`, `?` or `[`) by the
+// paths they match, sorted alphabetically, using the filepath.Match syntax. A path which exists as
+// is, or a `NAME=file-path` argument, is kept as is. A pattern matching nothing is an error unless
+// allowEmpty is set
+func ExpandGlobs(paths []string, allowEmpty bool) ([]string, error) {
+	expanded := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if !strings.ContainsAny(p, "*?[") {
+			expanded = append(expanded, p)
+			continue
+		}
+
+		if _, err := os.Stat(p); err == nil {
+			expanded = append(expanded, p)
+			continue
+		}
+
+		if name, _, ok := splitNamedPath(p); ok && identifierRegexp.MatchString(name) {
+			expanded = append(expanded, p)
+			continue
+		}
+
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume pattern '%s': %v", p, err)
+		}
+
+		if len(matches) == 0 && !allowEmpty {
+			return nil, fmt.Errorf("volume pattern '%s' doesn't match any path", p)
+		}
+
+		expanded = append(expanded, matches...)
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,85 @@
+package volume_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+func TestExpandGlobs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/shared/API_PORT":    "1337",
+		"worker/shared/QUEUE":    "jobs",
+		"worker/private/TOKEN":   "sssh",
+		"literal[1]/API_ADDRESS": "0.0.0.0",
+	})
+
+	tcs := []struct {
+		Name          string
+		Paths         []string
+		AllowEmpty    bool
+		Expected      []string
+		ExpectedError string
+	}{
+		{
+			Name:     "literal",
+			Paths:    []string{filepath.Join(root, "app", "shared"), "NAME=" + filepath.Join(root, "app", "shared", "API_PORT")},
+			Expected: []string{filepath.Join(root, "app", "shared"), "NAME=" + filepath.Join(root, "app", "shared", "API_PORT")},
+		},
+		{
+			Name:     "folders",
+			Paths:    []string{filepath.Join(root, "*", "shared")},
+			Expected: []string{filepath.Join(root, "app", "shared"), filepath.Join(root, "worker", "shared")},
+		},
+		{
+			Name:     "files",
+			Paths:    []string{filepath.Join(root, "worker", "*", "*")},
+			Expected: []string{filepath.Join(root, "worker", "private", "TOKEN"), filepath.Join(root, "worker", "shared", "QUEUE")},
+		},
+		{
+			Name:     "existing path with metacharacters",
+			Paths:    []string{filepath.Join(root, "literal[1]")},
+			Expected: []string{filepath.Join(root, "literal[1]")},
+		},
+		{
+			Name:     "named glob",
+			Paths:    []string{"NAME=" + filepath.Join(root, "*")},
+			Expected: []string{"NAME=" + filepath.Join(root, "*")},
+		},
+		{
+			Name:          "no match",
+			Paths:         []string{filepath.Join(root, "*", "missing")},
+			ExpectedError: "volume pattern '" + filepath.Join(root, "*", "missing") + "' doesn't match any path",
+		},
+		{
+			Name:       "allowed no match",
+			Paths:      []string{filepath.Join(root, "*", "missing")},
+			AllowEmpty: true,
+			Expected:   []string{},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := volume.ExpandGlobs(tc.Paths, tc.AllowEmpty)
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid paths\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
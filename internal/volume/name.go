@@ -0,0 +1,58 @@
+package volume
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NameTransform represents the way the variable name derived from a file name is rewritten
+type NameTransform string
+
+const (
+	// NameNone keeps the file name as is. It's the default behavior
+	NameNone NameTransform = "none"
+	// NameSnake lower cases the name and replaces any character other than a letter, a digit or
+	// `_` by `_`, e.g. `log-level` becomes `log_level`
+	NameSnake NameTransform = "snake"
+	// NameEnv upper cases the name and replaces any character other than a letter, a digit or `_`
+	// by `_`, e.g. `log-level` becomes `LOG_LEVEL`
+	NameEnv NameTransform = "env"
+)
+
+// ParseNameTransform returns the NameTransform matching the given name
+func ParseNameTransform(name string) (NameTransform, error) {
+	switch transform := NameTransform(name); transform {
+	case NameNone, NameSnake, NameEnv:
+		return transform, nil
+	default:
+		return "", fmt.Errorf("unsupported name transform '%s'", name)
+	}
+}
+
+var nonIdentifierCharRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func (n NameTransform) apply(name string) string {
+	switch n {
+	case NameSnake:
+		return strings.ToLower(nonIdentifierCharRegexp.ReplaceAllString(name, "_"))
+	case NameEnv:
+		return strings.ToUpper(nonIdentifierCharRegexp.ReplaceAllString(name, "_"))
+	default:
+		return name
+	}
+}
+
+func (n NameTransform) enabled() bool {
+	return n != "" && n != NameNone
+}
+
+// stripExt removes the extension of the file name, unless the name is an extension only
+func stripExt(name string) string {
+	if stripped := strings.TrimSuffix(name, path.Ext(name)); stripped != "" {
+		return stripped
+	}
+
+	return name
+}
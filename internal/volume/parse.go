@@ -0,0 +1,98 @@
+package volume
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format represents the structured files parsed into variables holding their content as data
+// instead of text
+type Format string
+
+const (
+	// FormatNone keeps the content of all the files as text. It's the default behavior
+	FormatNone Format = "none"
+	// FormatYAML parses the files with the `.yaml` or `.yml` extension as YAML
+	FormatYAML Format = "yaml"
+	// FormatJSON parses the files with the `.json` extension as JSON
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the Format matching the given name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatNone, FormatYAML, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported parse format '%s'", name)
+	}
+}
+
+// matches tells whether the file must be parsed, from the extension of its path
+func (f Format) matches(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return f == FormatYAML
+	case ".json":
+		return f == FormatJSON
+	default:
+		return false
+	}
+}
+
+// parse returns the compact JSON encoding of the content
+func (f Format) parse(content []byte) (string, error) {
+	if f == FormatJSON {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, content); err != nil {
+			return "", fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		return buf.String(), nil
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return "", fmt.Errorf("invalid YAML: %v", err)
+	}
+
+	encoded, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return "", fmt.Errorf("can't encode YAML as JSON: %v", err)
+	}
+
+	return string(encoded), nil
+}
+
+// jsonValue converts the YAML mappings whose keys aren't all strings so the value can be encoded
+// as JSON
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, v := range value {
+			value[key] = jsonValue(v)
+		}
+
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			converted[fmt.Sprint(key)] = jsonValue(v)
+		}
+
+		return converted
+	case []interface{}:
+		for i, v := range value {
+			value[i] = jsonValue(v)
+		}
+
+		return value
+	default:
+		return value
+	}
+}
@@ -0,0 +1,455 @@
+package volume
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+)
+
+// DefaultSeparator is the separator used to build the variable name of a nested file when none is given
+const DefaultSeparator = "/"
+
+// DefaultMaxFileSize is the maximum size of a loaded file used by the command line: 10MiB
+const DefaultMaxFileSize = 10 << 20
+
+// Trim represents the way the content of a file is trimmed before becoming a variable value
+type Trim string
+
+const (
+	// TrimSpace removes all leading and trailing white spaces. It's the default behavior
+	TrimSpace Trim = "space"
+	// TrimNewline removes only the trailing new lines
+	TrimNewline Trim = "newline"
+	// TrimNone keeps the content of the file as is
+	TrimNone Trim = "none"
+)
+
+// ParseTrim returns the Trim matching the given name
+func ParseTrim(name string) (Trim, error) {
+	switch trim := Trim(name); trim {
+	case TrimSpace, TrimNewline, TrimNone:
+		return trim, nil
+	default:
+		return "", fmt.Errorf("unsupported trim '%s'", name)
+	}
+}
+
+func (t Trim) apply(content string) string {
+	switch t {
+	case TrimNone:
+		return content
+	case TrimNewline:
+		return strings.TrimRight(content, "\r\n")
+	default:
+		return strings.TrimSpace(content)
+	}
+}
+
+// Binary represents the way the content of a binary file is loaded. A file is considered binary
+// when its content isn't valid UTF-8 or contains a null byte
+type Binary string
+
+const (
+	// BinaryRaw loads the content of binary files as is. It's the default behavior
+	BinaryRaw Binary = "raw"
+	// BinaryBase64 loads the content of binary files encoded in standard base64, without trimming it
+	BinaryBase64 Binary = "base64"
+)
+
+// ParseBinary returns the Binary matching the given name
+func ParseBinary(name string) (Binary, error) {
+	switch binary := Binary(name); binary {
+	case BinaryRaw, BinaryBase64:
+		return binary, nil
+	default:
+		return "", fmt.Errorf("unsupported binary mode '%s'", name)
+	}
+}
+
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// Options represents the settings used to load the variables of a volume
+type Options struct {
+	// Recursive loads the files present in sub folders as well
+	Recursive bool
+	// Separator joins the folder names and the file name of a nested file to build its variable name
+	Separator string
+	// Hidden loads the files starting with a `.` as well. Entries starting with `..` are
+	// always skipped
+	Hidden bool
+	// Trim defines how the content of each file is trimmed. Defaults to TrimSpace
+	Trim Trim
+	// Binary defines how the content of binary files is loaded. Defaults to BinaryRaw
+	Binary Binary
+	// Workers is the maximum number of files read concurrently. Defaults to GOMAXPROCS
+	Workers int
+	// MaxFileSize is the maximum size in bytes of a loaded file, larger files are an error. There's
+	// no limit when 0
+	MaxFileSize int64
+	// NameTransform rewrites the variable names derived from the file names. Two files whose names
+	// are the same once transformed are an error. Defaults to NameNone
+	NameTransform NameTransform
+	// StripExt removes the extension of the file names before deriving the variable names (e.g.
+	// `database.yaml` defines `database`). A name made of an extension only (e.g. `.env`) is kept.
+	// Two files whose names are the same once stripped are an error
+	StripExt bool
+	// Parse defines the structured files whose content is parsed and loaded as a Code variable
+	// holding its compact JSON encoding, so the template can read its fields. Defaults to
+	// FormatNone
+	Parse Format
+	// Encoding defines the charset the content of the files is decoded from. Defaults to
+	// EncodingUTF8, keeping the content as is
+	Encoding Encoding
+	// IncludeFiles restricts the files loaded from the folders to the ones whose base name matches
+	// one of the globs, following path.Match (e.g. `*.conf`). All the files are loaded when empty.
+	// The sub folders aren't filtered, only their files, and a root given as a file is always
+	// loaded
+	IncludeFiles []string
+	// ExcludeFiles skips the files of the folders whose base name matches one of the globs, even
+	// when they match IncludeFiles (e.g. `README*`)
+	ExcludeFiles []string
+}
+
+// ValidateFilePatterns ensures the IncludeFiles and ExcludeFiles globs are well-formed
+func (o Options) ValidateFilePatterns() error {
+	for _, pattern := range append(o.IncludeFiles[:len(o.IncludeFiles):len(o.IncludeFiles)], o.ExcludeFiles...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern '%s': %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// loadsFile tells whether the file of a folder is loaded, from its base name
+func (o Options) loadsFile(name string) bool {
+	for _, pattern := range o.ExcludeFiles {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+
+	if len(o.IncludeFiles) == 0 {
+		return true
+	}
+
+	for _, pattern := range o.IncludeFiles {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadAllVariables reads all the files in the root folder (or just the root file if it's
+// a file) and returns all the variables they define.
+//
+// The name of each file define the variable name and its content the value. When loading
+// recursively, the variable name of a nested file is its path relative to the root folder
+// where each path separator is replaced by the configured separator (e.g. `db/host` becomes
+// `db.host` when the separator is `.`)
+//
+// The files are read concurrently by at most opts.Workers goroutines, the variables are still
+// returned in the order the files are found
+//
+// Symbolic links are followed so the Kubernetes atomic writer layout (`KEY -> ..data/KEY`,
+// `..data -> ..<timestamp>`) is supported: entries starting with `..` are always skipped and
+// each key is only read through its visible link
+//
+// The root can also be of the form `NAME=path` to load a single file under an explicit variable
+// name. Only the first `=` separates the name from the path, and a root matching an existing
+// path is always read as a path
+func LoadAllVariables(root string, opts Options) ([]variable.Variable, error) {
+	if opts.Separator == "" {
+		opts.Separator = DefaultSeparator
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	if err := opts.ValidateFilePatterns(); err != nil {
+		return nil, err
+	}
+
+	l := loader{opts: opts, visited: make(map[string]bool), names: make(map[string]string)}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		if name, p, ok := splitNamedPath(root); ok {
+			return l.loadNamedFile(name, p)
+		}
+
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't read %s: no such file or directory", root)
+		}
+
+		return nil, fmt.Errorf("can't read %s: %v", root, err)
+	}
+
+	if !info.IsDir() {
+		if l.isHidden(filepath.Base(root)) {
+			return nil, nil
+		}
+
+		if err := l.addFile(root, l.fileName(filepath.Base(root))); err != nil {
+			return nil, err
+		}
+
+		return l.readFiles()
+	}
+
+	if err := l.loadDir(root, nil); err != nil {
+		return nil, err
+	}
+
+	return l.readFiles()
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func splitNamedPath(root string) (string, string, bool) {
+	parts := strings.SplitN(root, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+type loader struct {
+	opts    Options
+	visited map[string]bool
+	names   map[string]string
+	files   []fileToRead
+}
+
+type fileToRead struct {
+	path string
+	name string
+}
+
+func (l *loader) isHidden(name string) bool {
+	if strings.HasPrefix(name, "..") {
+		// Kubernetes stores the actual content of ConfigMap and Secrets in `..<timestamp>`
+		// folders exposed through the `..data` link
+		return true
+	}
+
+	return !l.opts.Hidden && strings.HasPrefix(name, ".")
+}
+
+func (l *loader) loadNamedFile(name string, p string) ([]variable.Variable, error) {
+	if !identifierRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid variable name '%s': it must start with a letter or '_' and contain only letters, digits and '_'", name)
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't read %s: no such file or directory", p)
+		}
+
+		return nil, fmt.Errorf("can't read %s: %v", p, err)
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("can't load %s as variable '%s': expected a file but got a folder", p, name)
+	}
+
+	// The name is explicitly given so it isn't transformed
+	l.files = append(l.files, fileToRead{path: p, name: name})
+
+	return l.readFiles()
+}
+
+func (l *loader) loadDir(dir string, parents []string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("can't resolve folder %s: %v", dir, err)
+	}
+
+	if l.visited[realDir] {
+		// Protects against symbolic links creating a loop
+		return nil
+	}
+	l.visited[realDir] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("can't read folder %s: %v", dir, err)
+	}
+
+	for _, entry := range entries {
+		if l.isHidden(entry.Name()) {
+			continue
+		}
+
+		p := filepath.Join(dir, entry.Name())
+		names := append(parents[:len(parents):len(parents)], entry.Name())
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("can't read %s: %v", p, err)
+		}
+
+		if info.IsDir() {
+			if !l.opts.Recursive {
+				continue
+			}
+
+			if err := l.loadDir(p, names); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !l.opts.loadsFile(entry.Name()) {
+			continue
+		}
+
+		names[len(names)-1] = l.fileName(entry.Name())
+		if err := l.addFile(p, strings.Join(names, l.opts.Separator)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileName returns the part of the file name used in the variable name
+func (l *loader) fileName(name string) string {
+	if l.opts.StripExt {
+		return stripExt(name)
+	}
+
+	return name
+}
+
+func (l *loader) addFile(p string, name string) error {
+	if l.opts.NameTransform.enabled() || l.opts.StripExt {
+		transformed := l.opts.NameTransform.apply(name)
+		if previous, found := l.names[transformed]; found {
+			return fmt.Errorf("can't load %s as variable '%s': %s defines the same variable once transformed", p, transformed, previous)
+		}
+
+		l.names[transformed] = p
+		name = transformed
+	}
+
+	l.files = append(l.files, fileToRead{path: p, name: name})
+
+	return nil
+}
+
+// readFiles reads all the added files using a bounded pool of workers. When several files can't
+// be read, the error of the first one is returned so the result doesn't depend on the scheduling
+func (l *loader) readFiles() ([]variable.Variable, error) {
+	variables := make([]variable.Variable, len(l.files))
+	errs := make([]error, len(l.files))
+
+	workers := l.opts.Workers
+	if workers > len(l.files) {
+		workers = len(l.files)
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			var buf bytes.Buffer
+			for i := range indexes {
+				variables[i], errs[i] = l.readFile(&buf, l.files[i])
+			}
+		}()
+	}
+
+	for i := range l.files {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return variables, nil
+}
+
+func (l *loader) readFile(buf *bytes.Buffer, f fileToRead) (variable.Variable, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return variable.Variable{}, fmt.Errorf("can't open file %s: %v", f.path, err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if l.opts.MaxFileSize > 0 {
+		// Reads one more byte than allowed to detect the files exceeding the limit without reading
+		// them entirely
+		r = io.LimitReader(file, l.opts.MaxFileSize+1)
+	}
+
+	buf.Reset()
+	if _, err := io.Copy(buf, r); err != nil {
+		return variable.Variable{}, fmt.Errorf("can't read external variable: %s", f.path)
+	}
+
+	if l.opts.MaxFileSize > 0 && int64(buf.Len()) > l.opts.MaxFileSize {
+		return variable.Variable{}, fmt.Errorf("can't load file %s: it exceeds the maximum size of %d bytes", f.path, l.opts.MaxFileSize)
+	}
+
+	return l.opts.variable(f.name, buf.Bytes(), f.path)
+}
+
+// variable builds the variable of a file content, parsed when the file is structured
+func (o Options) variable(name string, content []byte, source string) (variable.Variable, error) {
+	content, err := o.decode(content)
+	if err != nil {
+		return variable.Variable{}, fmt.Errorf("can't decode file %s: %v", source, err)
+	}
+
+	if !o.Parse.matches(source) {
+		return variable.Variable{Name: name, Value: o.value(content), Source: source}, nil
+	}
+
+	value, err := o.Parse.parse(content)
+	if err != nil {
+		return variable.Variable{}, fmt.Errorf("can't parse file %s: %v", source, err)
+	}
+
+	return variable.Variable{Name: name, Value: value, Source: source, Code: true, JSON: true}, nil
+}
+
+// value returns the variable value of a file content, trimmed or base64 encoded
+func (o Options) value(content []byte) string {
+	if o.Binary == BinaryBase64 && isBinary(content) {
+		return base64.StdEncoding.EncodeToString(content)
+	}
+
+	return o.Trim.apply(string(content))
+}
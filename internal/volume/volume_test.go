@@ -0,0 +1,717 @@
+package volume_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/variable"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+type recorder map[string]string
+
+func loadAllVariables(t *testing.T, root string, opts volume.Options) recorder {
+	variables, err := volume.LoadAllVariables(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := recorder{}
+	for _, variable := range variables {
+		if _, found := r[variable.Name]; found {
+			t.Fatalf("variable '%s' loaded twice", variable.Name)
+		}
+
+		r[variable.Name] = variable.Value
+	}
+
+	return r
+}
+
+func writeFiles(t testing.TB, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("can't create folder: %v", err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+}
+
+func TestLoadAllVariablesRecursive(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"API_PORT":       "1337",
+		"db/host":        "localhost",
+		"db/port":        "5432",
+		"db/.hidden/KEY": "hidden",
+	})
+
+	tcs := []struct {
+		Name     string
+		Options  volume.Options
+		Expected recorder
+	}{
+		{
+			Name:     "non-recursive",
+			Options:  volume.Options{},
+			Expected: recorder{"API_PORT": "1337"},
+		},
+		{
+			Name:     "recursive",
+			Options:  volume.Options{Recursive: true},
+			Expected: recorder{"API_PORT": "1337", "db/host": "localhost", "db/port": "5432"},
+		},
+		{
+			Name:     "recursive with separator",
+			Options:  volume.Options{Recursive: true, Separator: "."},
+			Expected: recorder{"API_PORT": "1337", "db.host": "localhost", "db.port": "5432"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual := loadAllVariables(t, root, tc.Options)
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesTrim(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"PASSWORD": "  secret\n",
+		"EMPTY":    " \n\t\n",
+	})
+
+	tcs := []struct {
+		Trim     volume.Trim
+		Expected recorder
+	}{
+		{
+			Trim:     volume.TrimSpace,
+			Expected: recorder{"PASSWORD": "secret", "EMPTY": ""},
+		},
+		{
+			Trim:     volume.TrimNewline,
+			Expected: recorder{"PASSWORD": "  secret", "EMPTY": " \n\t"},
+		},
+		{
+			Trim:     volume.TrimNone,
+			Expected: recorder{"PASSWORD": "  secret\n", "EMPTY": " \n\t\n"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(string(tc.Trim), func(t *testing.T) {
+			actual := loadAllVariables(t, root, volume.Options{Trim: tc.Trim})
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%q\nactual:\n%q\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func symlink(t *testing.T, oldname string, newname string) {
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Fatalf("can't create symbolic link: %v", err)
+	}
+}
+
+func TestLoadAllVariablesProjectedVolume(t *testing.T) {
+	// Reproduces the layout of the Kubernetes atomic writer:
+	//   ..2024_01_02_03_04_05.123456/{API_PORT,db/host}
+	//   ..data -> ..2024_01_02_03_04_05.123456
+	//   API_PORT -> ..data/API_PORT
+	//   db -> ..data/db
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"..2024_01_02_03_04_05.123456/API_PORT": "1337",
+		"..2024_01_02_03_04_05.123456/db/host":  "localhost",
+		".env":                                  "hidden",
+	})
+	symlink(t, "..2024_01_02_03_04_05.123456", filepath.Join(root, "..data"))
+	symlink(t, filepath.Join("..data", "API_PORT"), filepath.Join(root, "API_PORT"))
+	symlink(t, filepath.Join("..data", "db"), filepath.Join(root, "db"))
+
+	tcs := []struct {
+		Name     string
+		Root     string
+		Options  volume.Options
+		Expected recorder
+	}{
+		{
+			Name:     "non-recursive",
+			Root:     root,
+			Options:  volume.Options{},
+			Expected: recorder{"API_PORT": "1337"},
+		},
+		{
+			Name:     "recursive",
+			Root:     root,
+			Options:  volume.Options{Recursive: true},
+			Expected: recorder{"API_PORT": "1337", "db/host": "localhost"},
+		},
+		{
+			Name:     "hidden",
+			Root:     root,
+			Options:  volume.Options{Recursive: true, Hidden: true},
+			Expected: recorder{"API_PORT": "1337", "db/host": "localhost", ".env": "hidden"},
+		},
+		{
+			Name:     "data link as root",
+			Root:     filepath.Join(root, "..data"),
+			Options:  volume.Options{Recursive: true},
+			Expected: recorder{"API_PORT": "1337", "db/host": "localhost"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual := loadAllVariables(t, tc.Root, tc.Options)
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesNamedFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"token":         "abc",
+		"key=value/key": "nested",
+	})
+
+	tcs := []struct {
+		Name          string
+		Root          string
+		Expected      recorder
+		ExpectedError string
+	}{
+		{
+			Name:     "named file",
+			Root:     "API_TOKEN=" + filepath.Join(root, "token"),
+			Expected: recorder{"API_TOKEN": "abc"},
+		},
+		{
+			Name:     "equal sign in path",
+			Root:     "KEY=" + filepath.Join(root, "key=value", "key"),
+			Expected: recorder{"KEY": "nested"},
+		},
+		{
+			Name:     "existing path with equal sign",
+			Root:     filepath.Join(root, "key=value"),
+			Expected: recorder{"key": "nested"},
+		},
+		{
+			Name:          "invalid name",
+			Root:          "API-TOKEN=" + filepath.Join(root, "token"),
+			ExpectedError: "invalid variable name 'API-TOKEN': it must start with a letter or '_' and contain only letters, digits and '_'",
+		},
+		{
+			Name:          "folder",
+			Root:          "KEY=" + filepath.Join(root, "key=value"),
+			ExpectedError: "can't load " + filepath.Join(root, "key=value") + " as variable 'KEY': expected a file but got a folder",
+		},
+		{
+			Name:          "missing path",
+			Root:          filepath.Join(root, "missing"),
+			ExpectedError: "can't read " + filepath.Join(root, "missing") + ": no such file or directory",
+		},
+		{
+			Name:          "missing named file",
+			Root:          "KEY=" + filepath.Join(root, "missing"),
+			ExpectedError: "can't read " + filepath.Join(root, "missing") + ": no such file or directory",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(tc.Root, volume.Options{})
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			actual := loadAllVariables(t, tc.Root, volume.Options{})
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesBinary(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"BLOB": "\x00\x01 gzip\n",
+		"TEXT": "text\n",
+	})
+
+	tcs := []struct {
+		Binary   volume.Binary
+		Expected recorder
+	}{
+		{
+			Binary:   volume.BinaryRaw,
+			Expected: recorder{"BLOB": "\x00\x01 gzip", "TEXT": "text"},
+		},
+		{
+			Binary:   volume.BinaryBase64,
+			Expected: recorder{"BLOB": "AAEgZ3ppcAo=", "TEXT": "text"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(string(tc.Binary), func(t *testing.T) {
+			actual := loadAllVariables(t, root, volume.Options{Binary: tc.Binary})
+
+			if !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%q\nactual:\n%q\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesWorkers(t *testing.T) {
+	root := t.TempDir()
+
+	files := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("db/KEY_%03d", i)] = fmt.Sprintf("value %d", i)
+	}
+	writeFiles(t, root, files)
+
+	opts := volume.Options{Recursive: true, Workers: 1}
+	expected, err := volume.LoadAllVariables(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{0, 4, 200} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			opts.Workers = workers
+
+			actual, err := volume.LoadAllVariables(root, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", expected, actual)
+			}
+		})
+	}
+}
+
+func BenchmarkLoadAllVariables(b *testing.B) {
+	root := b.TempDir()
+
+	files := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		files[fmt.Sprintf("KEY_%03d", i)] = strings.Repeat("x", 1024)
+	}
+	writeFiles(b, root, files)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("%d workers", workers), func(b *testing.B) {
+			opts := volume.Options{Workers: workers}
+
+			for i := 0; i < b.N; i++ {
+				if _, err := volume.LoadAllVariables(root, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesMaxFileSize(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"SMALL": "1234",
+		"LARGE": "12345",
+	})
+
+	tcs := []struct {
+		Name          string
+		MaxFileSize   int64
+		Expected      recorder
+		ExpectedError string
+	}{
+		{Name: "unlimited", MaxFileSize: 0, Expected: recorder{"SMALL": "1234", "LARGE": "12345"}},
+		{Name: "at the limit", MaxFileSize: 5, Expected: recorder{"SMALL": "1234", "LARGE": "12345"}},
+		{Name: "exceeding", MaxFileSize: 4, ExpectedError: fmt.Sprintf("can't load file %s: it exceeds the maximum size of 4 bytes", filepath.Join(root, "LARGE"))},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := volume.Options{MaxFileSize: tc.MaxFileSize}
+
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(root, opts)
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if actual := loadAllVariables(t, root, opts); !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesNameTransform(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"log-level": "debug",
+		"api.port":  "1337",
+		"db/host":   "localhost",
+	})
+
+	collisions := t.TempDir()
+	writeFiles(t, collisions, map[string]string{
+		"a.b": "1",
+		"a-b": "2",
+	})
+
+	tcs := []struct {
+		Name          string
+		Root          string
+		NameTransform volume.NameTransform
+		Expected      recorder
+		ExpectedError string
+	}{
+		{Name: "none", Root: root, NameTransform: volume.NameNone, Expected: recorder{"log-level": "debug", "api.port": "1337", "db/host": "localhost"}},
+		{Name: "snake", Root: root, NameTransform: volume.NameSnake, Expected: recorder{"log_level": "debug", "api_port": "1337", "db_host": "localhost"}},
+		{Name: "env", Root: root, NameTransform: volume.NameEnv, Expected: recorder{"LOG_LEVEL": "debug", "API_PORT": "1337", "DB_HOST": "localhost"}},
+		{Name: "none collision", Root: collisions, NameTransform: volume.NameNone, Expected: recorder{"a.b": "1", "a-b": "2"}},
+		{
+			Name:          "env collision",
+			Root:          collisions,
+			NameTransform: volume.NameEnv,
+			ExpectedError: fmt.Sprintf("can't load %s as variable 'A_B': %s defines the same variable once transformed", filepath.Join(collisions, "a.b"), filepath.Join(collisions, "a-b")),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := volume.Options{Recursive: true, NameTransform: tc.NameTransform}
+
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(tc.Root, opts)
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if actual := loadAllVariables(t, tc.Root, opts); !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesStripExt(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"database.yaml":   "host: localhost",
+		"log-level":       "debug",
+		".env":            "A=1",
+		"certs/ca.pem":    "CA",
+		"certs.d/key.txt": "KEY",
+	})
+
+	collisions := t.TempDir()
+	writeFiles(t, collisions, map[string]string{
+		"a.json": "{}",
+		"a.yaml": "a: 1",
+	})
+
+	tcs := []struct {
+		Name          string
+		Root          string
+		NameTransform volume.NameTransform
+		Expected      recorder
+		ExpectedError string
+	}{
+		{
+			Name:     "strip",
+			Root:     root,
+			Expected: recorder{"database": "host: localhost", "log-level": "debug", ".env": "A=1", "certs/ca": "CA", "certs.d/key": "KEY"},
+		},
+		{
+			Name:          "env",
+			Root:          root,
+			NameTransform: volume.NameEnv,
+			Expected:      recorder{"DATABASE": "host: localhost", "LOG_LEVEL": "debug", "_ENV": "A=1", "CERTS_CA": "CA", "CERTS_D_KEY": "KEY"},
+		},
+		{
+			Name:          "file",
+			Root:          filepath.Join(root, "database.yaml"),
+			Expected:      recorder{"database": "host: localhost"},
+			NameTransform: volume.NameNone,
+		},
+		{
+			Name:          "collision",
+			Root:          collisions,
+			ExpectedError: fmt.Sprintf("can't load %s as variable 'a': %s defines the same variable once transformed", filepath.Join(collisions, "a.yaml"), filepath.Join(collisions, "a.json")),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := volume.Options{Recursive: true, Hidden: true, StripExt: true, NameTransform: tc.NameTransform}
+
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(tc.Root, opts)
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if actual := loadAllVariables(t, tc.Root, opts); !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesEncoding(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"city":  "Montr\xe9al",
+		"price": "10 \x80",
+		"key":   "\x00\xe9",
+	})
+
+	invalid := t.TempDir()
+	writeFiles(t, invalid, map[string]string{"name": "a\x81b"})
+
+	tcs := []struct {
+		Name          string
+		Root          string
+		Encoding      volume.Encoding
+		Binary        volume.Binary
+		Expected      recorder
+		ExpectedError string
+	}{
+		{
+			Name:     "utf-8",
+			Root:     root,
+			Encoding: volume.EncodingUTF8,
+			Expected: recorder{"city": "Montr\xe9al", "price": "10 \x80", "key": "\x00\xe9"},
+		},
+		{
+			Name:     "latin1",
+			Root:     root,
+			Encoding: volume.EncodingLatin1,
+			Expected: recorder{"city": "Montréal", "price": "10 \u0080", "key": "\x00é"},
+		},
+		{
+			Name:     "windows-1252",
+			Root:     root,
+			Encoding: volume.EncodingWindows1252,
+			Binary:   volume.BinaryBase64,
+			Expected: recorder{"city": "Montréal", "price": "10 €", "key": "AOk="},
+		},
+		{
+			Name:          "undefined byte",
+			Root:          invalid,
+			Encoding:      volume.EncodingWindows1252,
+			ExpectedError: fmt.Sprintf("can't decode file %s: invalid windows-1252 byte 0x81 at offset 1", filepath.Join(invalid, "name")),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := volume.Options{Encoding: tc.Encoding, Binary: tc.Binary, Trim: volume.TrimNone}
+
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(tc.Root, opts)
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if actual := loadAllVariables(t, tc.Root, opts); !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadAllVariablesFilePatterns(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"api.conf":       "port=1337",
+		"db.conf":        "host=localhost",
+		"README.md":      "documentation",
+		"README.conf":    "documentation",
+		"LOG_LEVEL":      "info",
+		"nested/db.conf": "port=5432",
+		"conf/notes.md":  "notes",
+	})
+
+	tcs := []struct {
+		Name          string
+		Include       []string
+		Exclude       []string
+		Expected      recorder
+		ExpectedError string
+	}{
+		{
+			Name:     "all",
+			Expected: recorder{"api.conf": "port=1337", "db.conf": "host=localhost", "README.md": "documentation", "README.conf": "documentation", "LOG_LEVEL": "info", "nested/db.conf": "port=5432", "conf/notes.md": "notes"},
+		},
+		{
+			Name:     "include",
+			Include:  []string{"*.conf", "LOG_*"},
+			Expected: recorder{"api.conf": "port=1337", "db.conf": "host=localhost", "README.conf": "documentation", "LOG_LEVEL": "info", "nested/db.conf": "port=5432"},
+		},
+		{
+			Name:     "exclude",
+			Exclude:  []string{"README*", "db.conf"},
+			Expected: recorder{"api.conf": "port=1337", "LOG_LEVEL": "info", "conf/notes.md": "notes"},
+		},
+		{
+			Name:     "exclude wins over include",
+			Include:  []string{"*.conf"},
+			Exclude:  []string{"README*"},
+			Expected: recorder{"api.conf": "port=1337", "db.conf": "host=localhost", "nested/db.conf": "port=5432"},
+		},
+		{
+			Name:          "invalid pattern",
+			Include:       []string{"[a-"},
+			ExpectedError: "invalid file pattern '[a-': syntax error in pattern",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := volume.Options{Recursive: true, IncludeFiles: tc.Include, ExcludeFiles: tc.Exclude}
+
+			if tc.ExpectedError != "" {
+				_, err := volume.LoadAllVariables(root, opts)
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if actual := loadAllVariables(t, root, opts); !reflect.DeepEqual(tc.Expected, actual) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, actual)
+			}
+		})
+	}
+
+	opts := volume.Options{IncludeFiles: []string{"*.conf"}}
+	if actual := loadAllVariables(t, filepath.Join(root, "README.md"), opts); !reflect.DeepEqual(recorder{"README.md": "documentation"}, actual) {
+		t.Fatalf("the root file isn't loaded: %v", actual)
+	}
+}
+
+func TestLoadAllVariablesParse(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"database.yaml": "host: localhost\nport: 5432\n",
+		"ports.json":    "[80, 443]",
+		"name":          "api",
+	})
+
+	broken := t.TempDir()
+	writeFiles(t, broken, map[string]string{"broken.yml": "a: ["})
+
+	tcs := []struct {
+		Name          string
+		Root          string
+		Parse         volume.Format
+		Expected      []variable.Variable
+		ExpectedError string
+	}{
+		{
+			Name:  "none",
+			Root:  root,
+			Parse: volume.FormatNone,
+			Expected: []variable.Variable{
+				{Name: "database.yaml", Value: "host: localhost\nport: 5432", Source: filepath.Join(root, "database.yaml")},
+				{Name: "name", Value: "api", Source: filepath.Join(root, "name")},
+				{Name: "ports.json", Value: "[80, 443]", Source: filepath.Join(root, "ports.json")},
+			},
+		},
+		{
+			Name:  "yaml",
+			Root:  root,
+			Parse: volume.FormatYAML,
+			Expected: []variable.Variable{
+				{Name: "database.yaml", Value: `{"host":"localhost","port":5432}`, Source: filepath.Join(root, "database.yaml"), Code: true, JSON: true},
+				{Name: "name", Value: "api", Source: filepath.Join(root, "name")},
+				{Name: "ports.json", Value: "[80, 443]", Source: filepath.Join(root, "ports.json")},
+			},
+		},
+		{
+			Name:  "json",
+			Root:  root,
+			Parse: volume.FormatJSON,
+			Expected: []variable.Variable{
+				{Name: "database.yaml", Value: "host: localhost\nport: 5432", Source: filepath.Join(root, "database.yaml")},
+				{Name: "name", Value: "api", Source: filepath.Join(root, "name")},
+				{Name: "ports.json", Value: "[80,443]", Source: filepath.Join(root, "ports.json"), Code: true, JSON: true},
+			},
+		},
+		{
+			Name:          "invalid",
+			Root:          broken,
+			Parse:         volume.FormatYAML,
+			ExpectedError: fmt.Sprintf("can't parse file %s: invalid YAML: yaml: line 1: did not find expected node content", filepath.Join(broken, "broken.yml")),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			variables, err := volume.LoadAllVariables(tc.Root, volume.Options{Parse: tc.Parse})
+			if tc.ExpectedError != "" {
+				if err == nil || err.Error() != tc.ExpectedError {
+					t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", tc.ExpectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.Expected, variables) {
+				t.Fatalf("invalid variables\nexpected:\n%v\nactual:\n%v\n", tc.Expected, variables)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	waitMinDelay = 50 * time.Millisecond
+	waitMaxDelay = 2 * time.Second
+)
+
+// Wait blocks until the volume path exists and isn't empty, retrying with an exponential backoff
+// for at most the timeout, e.g. when an init container is still populating it. A folder is empty
+// when it has no entries but the `..` ones of the Kubernetes atomic writer, a file when its size
+// is 0. Only these "not yet present" conditions are retried: any other error, like a permission
+// denied, is returned right away. The root can be of the form `NAME=path` like LoadAllVariables
+func Wait(root string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := waitMinDelay
+
+	for {
+		ready, err := isPresent(root)
+		if err != nil || ready {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%s is still missing or empty after %s", root, timeout)
+		}
+
+		if delay > remaining {
+			delay = remaining
+		}
+
+		time.Sleep(delay)
+
+		if delay *= 2; delay > waitMaxDelay {
+			delay = waitMaxDelay
+		}
+	}
+}
+
+func isPresent(root string) (bool, error) {
+	p := root
+
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		if _, named, ok := splitNamedPath(root); ok {
+			p = named
+			info, err = os.Stat(p)
+		}
+	}
+
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("can't read %s: %v", p, err)
+	}
+
+	if !info.IsDir() {
+		return info.Size() > 0, nil
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return false, fmt.Errorf("can't read folder %s: %v", p, err)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "..") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,84 @@
+package volume_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/volume"
+)
+
+func TestWait(t *testing.T) {
+	root := t.TempDir()
+	mounted := filepath.Join(root, "mounted")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+
+		// The atomic writer folder alone doesn't make the volume present
+		if err := os.MkdirAll(filepath.Join(mounted, "..data"), 0755); err != nil {
+			panic(err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if err := ioutil.WriteFile(filepath.Join(mounted, "API_PORT"), []byte("1337"), 0644); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := volume.Wait(mounted, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(mounted, "API_PORT")); err != nil {
+		t.Fatalf("returned before the volume was populated: %v", err)
+	}
+
+	if err := volume.Wait("API_PORT="+filepath.Join(mounted, "API_PORT"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "EMPTY"), nil, 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	for _, p := range []string{filepath.Join(root, "missing"), filepath.Join(root, "EMPTY")} {
+		start := time.Now()
+
+		err := volume.Wait(p, 200*time.Millisecond)
+		if expected := fmt.Sprintf("%s is still missing or empty after 200ms", p); err == nil || err.Error() != expected {
+			t.Fatalf("invalid error\nexpected:\n%s\nactual:\n%v\n", expected, err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+			t.Fatalf("invalid wait duration: %s", elapsed)
+		}
+	}
+}
+
+func TestWaitError(t *testing.T) {
+	root := t.TempDir()
+	p := filepath.Join(root, "file")
+	if err := ioutil.WriteFile(p, []byte("content"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	// A path inside a file fails with ENOTDIR, which isn't retried
+	nested := filepath.Join(p, "nested")
+	start := time.Now()
+
+	if err := volume.Wait(nested, 5*time.Second); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the error was retried for %s", elapsed)
+	}
+}
@@ -0,0 +1,148 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is the time to wait after the last change before calling the change handler
+const DefaultDebounce = 500 * time.Millisecond
+
+// Watch calls onChange each time one of the paths changes, until the context is cancelled. For a
+// folder, any change of one of its entries or sub folders is reported. For a file, only the changes
+// of the file itself or of the Kubernetes '..data' symbolic link of its folder are reported, so a
+// file written next to it (e.g. an output) doesn't trigger a change.
+//
+// Files and folders are watched through their parent folder so the watch survives the atomic
+// replacement of a file and the swap of the '..data' symbolic link done by Kubernetes to update the
+// ConfigMap and Secret volumes. Changes happening within the debounce duration are reported once
+func Watch(ctx context.Context, paths []string, debounce time.Duration, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("can't create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	w := &pathWatcher{watcher: watcher, files: make(map[string]bool), folders: make(map[string]bool)}
+	if err := w.arm(paths); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if !w.relevant(event) {
+				continue
+			}
+
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			return fmt.Errorf("can't watch changes: %v", err)
+		case <-timer.C:
+			// New sub folders may have been created, and a replaced folder has to be watched again
+			if err := w.arm(paths); err != nil {
+				return err
+			}
+
+			onChange()
+		}
+	}
+}
+
+type pathWatcher struct {
+	watcher *fsnotify.Watcher
+	files   map[string]bool
+	folders map[string]bool
+}
+
+func (w *pathWatcher) arm(paths []string) error {
+	for _, path := range paths {
+		path = filepath.Clean(path)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("can't watch '%s': %v", path, err)
+		}
+
+		if !info.IsDir() {
+			w.files[path] = true
+			if err := w.add(filepath.Dir(path)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() {
+				return nil
+			}
+
+			w.folders[p] = true
+
+			return w.add(p)
+		})
+		if err != nil {
+			return fmt.Errorf("can't watch '%s': %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *pathWatcher) add(path string) error {
+	if err := w.watcher.Add(path); err != nil {
+		return fmt.Errorf("can't watch '%s': %v", path, err)
+	}
+
+	return nil
+}
+
+func (w *pathWatcher) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	name := filepath.Clean(event.Name)
+	dir := filepath.Dir(name)
+
+	if w.files[name] || w.folders[name] || w.folders[dir] {
+		return true
+	}
+
+	if !strings.HasPrefix(filepath.Base(name), "..") {
+		return false
+	}
+
+	for file := range w.files {
+		if filepath.Dir(file) == dir {
+			return true
+		}
+	}
+
+	return false
+}
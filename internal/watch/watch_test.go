@@ -0,0 +1,125 @@
+package watch_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal/watch"
+)
+
+const debounce = 50 * time.Millisecond
+
+// atomicWrite mimics the way Kubernetes updates a ConfigMap volume: the content is written to a
+// new timestamped folder then the '..data' symbolic link is swapped to point to it
+func atomicWrite(t *testing.T, root string, version string, content string) {
+	data := filepath.Join(root, "..2021_"+version)
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(data, "API_PORT"), []byte(content), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	tmp := filepath.Join(root, "..data_tmp")
+	if err := os.Symlink(filepath.Base(data), tmp); err != nil {
+		t.Fatalf("can't create symlink: %v", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(root, "..data")); err != nil {
+		t.Fatalf("can't swap symlink: %v", err)
+	}
+}
+
+func startWatch(t *testing.T, paths []string) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	done := make(chan error)
+
+	go func() { done <- watch.Watch(ctx, paths, debounce, func() { changes <- struct{}{} }) }()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	// Let the watcher start before changing anything
+	time.Sleep(debounce)
+
+	return changes
+}
+
+func expectChanges(t *testing.T, changes <-chan struct{}, expected int) {
+	t.Helper()
+
+	timeout := time.After(20 * debounce)
+	for i := 0; i < expected; i++ {
+		select {
+		case <-changes:
+		case <-timeout:
+			t.Fatalf("expected %d change(s) but got %d", expected, i)
+		}
+	}
+
+	select {
+	case <-changes:
+		t.Fatalf("expected %d change(s) but got more", expected)
+	case <-time.After(4 * debounce):
+	}
+}
+
+func TestWatchAtomicWriter(t *testing.T) {
+	root := t.TempDir()
+	atomicWrite(t, root, "v1", "1337")
+	if err := os.Symlink(filepath.Join("..data", "API_PORT"), filepath.Join(root, "API_PORT")); err != nil {
+		t.Fatalf("can't create symlink: %v", err)
+	}
+
+	changes := startWatch(t, []string{root})
+
+	atomicWrite(t, root, "v2", "8080")
+	expectChanges(t, changes, 1)
+
+	// The watch must still be armed after the first swap
+	atomicWrite(t, root, "v3", "9090")
+	expectChanges(t, changes, 1)
+}
+
+func TestWatchFile(t *testing.T) {
+	root := t.TempDir()
+	input := filepath.Join(root, "config.jsonnet")
+	if err := ioutil.WriteFile(input, []byte("{}"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+
+	changes := startWatch(t, []string{input})
+
+	// A file written next to the input, like the output, isn't a change
+	if err := ioutil.WriteFile(filepath.Join(root, "config.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+	expectChanges(t, changes, 0)
+
+	// Bursty updates are reported once, including an atomic replacement of the file
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(input, []byte("{ a: 1 }"), 0644); err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+	}
+	expectChanges(t, changes, 1)
+
+	tmp := filepath.Join(root, ".config.jsonnet.tmp")
+	if err := ioutil.WriteFile(tmp, []byte("{ a: 2 }"), 0644); err != nil {
+		t.Fatalf("can't write file: %v", err)
+	}
+	if err := os.Rename(tmp, input); err != nil {
+		t.Fatalf("can't replace file: %v", err)
+	}
+	expectChanges(t, changes, 1)
+}
@@ -0,0 +1,129 @@
+// Package cfgenerator generates configuration files from a template and variables read from
+// volume folders, like the ConfigMap and Secret volumes mounted by Kubernetes.
+//
+// Each file of a volume folder defines a variable named after the file and whose value is the
+// content of the file. The template is then evaluated by an Interpreter (e.g. JSONNET or Go
+// Template) having access to all these variables.
+package cfgenerator
+
+import (
+	"io"
+
+	"github.com/fewlinesco/k8s-cfgenerator/internal"
+	"github.com/fewlinesco/k8s-cfgenerator/internal/interpreter"
+)
+
+// Interpreter represents something able to aggregate variables and render templates
+type Interpreter = interpreter.Interpreter
+
+// CodeInterpreter represents an interpreter able to store variables whose value is code evaluated
+// by the interpreter itself, required by Options.CodeVolumes
+type CodeInterpreter = interpreter.CodeInterpreter
+
+// Options represents the settings used to generate the content. The zero value uses the same
+// defaults as the command line
+type Options = internal.Options
+
+// BuilderFunc represents a function that initialize a new Interpreter
+type BuilderFunc = interpreter.BuilderFunc
+
+// Result represents the generated content along with the variables used and unused by the template.
+// The variables are only reported by the interpreters implementing TrackingInterpreter: jsonnet
+// (literal std.extVar names), plain and html (fields and var calls), envsubst and jsonc
+// (references)
+type Result = internal.Result
+
+// ValidationError reports that the variables or the generated content don't satisfy the
+// requirements of the Options (Required, Schema, ParseOutput), as opposed to an error reading the
+// sources or evaluating the template
+type ValidationError = internal.ValidationError
+
+// DataInterpreter represents an interpreter able to store variables whose value is structured
+// data read field by field by the template. The plain and html interpreters implement it
+type DataInterpreter = interpreter.DataInterpreter
+
+// TrackingInterpreter represents an interpreter able to report the variables referenced by the last
+// evaluated template
+type TrackingInterpreter = interpreter.TrackingInterpreter
+
+// StreamingInterpreter represents an interpreter able to write the content to a writer as the
+// template is evaluated. The plain and html interpreters implement it
+type StreamingInterpreter = interpreter.StreamingInterpreter
+
+// TextInterpreter represents an interpreter producing plain text instead of JSON, so several
+// templates can be concatenated and the content isn't converted to the output formats. The plain,
+// html and envsubst interpreters implement it
+type TextInterpreter = interpreter.TextInterpreter
+
+// SyntaxInterpreter represents an interpreter able to check the syntax of a template without
+// evaluating it. All the built-in interpreters implement it
+type SyntaxInterpreter = interpreter.SyntaxInterpreter
+
+// OptionsInterpreter represents an interpreter accepting its settings as a map of named options.
+// The plain, html, envsubst and jsonnet interpreters implement it
+type OptionsInterpreter = interpreter.OptionsInterpreter
+
+// Register makes an interpreter available by the provided name to Get and Names. It panics if the
+// builder is nil or if an interpreter is already registered with the same name
+func Register(name string, builderFunc BuilderFunc) {
+	interpreter.Register(name, builderFunc)
+}
+
+// Get builds a new interpreter from its name and return a boolean indicating wether the interpreter
+// has been found
+func Get(name string) (Interpreter, bool) {
+	return interpreter.Get(name)
+}
+
+// Names returns the names of all the registered interpreters, sorted alphabetically
+func Names() []string {
+	return interpreter.Names()
+}
+
+// Generate reads all the volumes to collect the variables and execute the template using the
+// default options
+func Generate(runtime Interpreter, input io.Reader, volumes []string) (string, error) {
+	return GenerateWithOptions(runtime, input, volumes, Options{})
+}
+
+// GenerateWithOptions reads all the volumes to collect the variables and execute the template
+func GenerateWithOptions(runtime Interpreter, input io.Reader, volumes []string, opts Options) (string, error) {
+	return internal.Generate(runtime, input, volumes, opts)
+}
+
+// GenerateResult reads all the volumes to collect the variables and execute the template, and
+// reports which variables the template uses
+func GenerateResult(runtime Interpreter, input io.Reader, volumes []string, opts Options) (Result, error) {
+	return internal.GenerateResult(runtime, input, volumes, opts)
+}
+
+// GenerateTo reads all the volumes to collect the variables and execute the template, writing the
+// content to the writer as it's evaluated when the interpreter is a StreamingInterpreter and the
+// options allow it
+func GenerateTo(runtime Interpreter, input io.Reader, volumes []string, w io.Writer, opts Options) (Result, error) {
+	return internal.GenerateTo(runtime, input, volumes, w, opts)
+}
+
+// CanStream returns whether GenerateTo writes the content as the template is evaluated with the
+// interpreter and the options
+func CanStream(runtime Interpreter, opts Options) bool {
+	return internal.CanStream(runtime, opts)
+}
+
+// GenerateMulti reads all the volumes to collect the variables and execute the template which must
+// produce an object mapping file names to their content, like the '-m' mode of the jsonnet command
+// line
+func GenerateMulti(runtime Interpreter, input io.Reader, volumes []string, opts Options) (map[string]string, error) {
+	return internal.GenerateMulti(runtime, input, volumes, opts)
+}
+
+// GenerateSplit reads all the volumes to collect the variables and execute the template which must
+// produce an object, and returns each top-level value encoded in its own file named after its key
+func GenerateSplit(runtime Interpreter, input io.Reader, volumes []string, opts Options) (map[string]string, error) {
+	return internal.GenerateSplit(runtime, input, volumes, opts)
+}
+
+// IsValidationError returns whether the error returned by a generation is a ValidationError
+func IsValidationError(err error) bool {
+	return internal.IsValidationError(err)
+}
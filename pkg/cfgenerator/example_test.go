@@ -0,0 +1,61 @@
+package cfgenerator_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fewlinesco/k8s-cfgenerator/pkg/cfgenerator"
+)
+
+func ExampleGenerate() {
+	runtime, found := cfgenerator.Get("plain")
+	if !found {
+		panic("plain interpreter not registered")
+	}
+
+	template := strings.NewReader("listen {{ .API_PORT }};")
+	volumes := []string{"../../cmd/cfgenerator/examples/plain/volumes/config"}
+
+	content, err := cfgenerator.Generate(runtime, template, volumes)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(content)
+	// Output: listen 1337;
+}
+
+type upper struct {
+	vars map[string]string
+}
+
+func (u *upper) AddVar(name string, value string) {
+	u.vars[name] = value
+}
+
+func (u *upper) Evaluate(tpl string) (string, error) {
+	return strings.ToUpper(os.Expand(tpl, func(name string) string { return u.vars[name] })), nil
+}
+
+func ExampleRegister() {
+	cfgenerator.Register("upper", func() cfgenerator.Interpreter {
+		return &upper{vars: make(map[string]string)}
+	})
+
+	runtime, found := cfgenerator.Get("upper")
+	if !found {
+		panic("upper interpreter not registered")
+	}
+
+	template := strings.NewReader("listen ${API_PORT} as ${DATABASE_USERNAME}")
+	volumes := []string{"../../cmd/cfgenerator/examples/plain/volumes/config"}
+
+	content, err := cfgenerator.Generate(runtime, template, volumes)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(content)
+	// Output: LISTEN 1337 AS MYAPP
+}